@@ -0,0 +1,117 @@
+package admission
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"main.go/common"
+)
+
+// servingCertificate returns the serving certificate of the webhook and its CA bundle.
+// A self-signed certificate for the collector service is generated if no certificate files are set.
+func servingCertificate(config *common.AdmissionWebhookConfig) (certificate tls.Certificate, caBundle []byte, err error) {
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		certificate, err = common.LoadTLSCertificate(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return certificate, nil, err
+		}
+		if config.CABundleFile != "" {
+			caBundle, err = os.ReadFile(config.CABundleFile)
+			if err != nil {
+				return certificate, nil, fmt.Errorf("failed to read CA bundle file %s: %w", config.CABundleFile, err)
+			}
+		}
+		return certificate, caBundle, nil
+	}
+
+	if config.ServiceName == "" || config.ServiceNamespace == "" {
+		return certificate, nil, fmt.Errorf("serviceName and serviceNamespace are required to generate a serving certificate")
+	}
+	serviceHost := fmt.Sprintf("%s.%s.svc", config.ServiceName, config.ServiceNamespace)
+	return common.GenerateSelfSignedCertificate([]string{serviceHost, serviceHost + ".cluster.local"}, certificateValidity)
+}
+
+// webhookConfiguration returns the always allow, fail open, ValidatingWebhookConfiguration of the watched resources
+func webhookConfiguration(config *common.AdmissionWebhookConfig, caBundle []byte, watchedResources []schema.GroupVersionResource) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	name := config.WebhookName
+	if name == "" {
+		name = common.DefaultAdmissionWebhookName
+	}
+	path := config.Path
+	if path == "" {
+		path = common.DefaultAdmissionWebhookPath
+	}
+	port := config.ServicePort
+	if port == 0 {
+		port = 443
+	}
+	timeoutSeconds := config.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = common.DefaultAdmissionWebhookTimeout
+	}
+
+	var rules []admissionregistrationv1.RuleWithOperations
+	for _, resource := range watchedResources {
+		rules = append(rules, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{resource.Group},
+				APIVersions: []string{resource.Version},
+				Resources:   []string{resource.Resource},
+			},
+		})
+	}
+
+	// The webhook never denies requests and is ignored when it is unavailable
+	failurePolicy := admissionregistrationv1.Ignore
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	matchPolicy := admissionregistrationv1.Equivalent
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: name,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: config.ServiceNamespace,
+					Name:      config.ServiceName,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: caBundle,
+			},
+			Rules:                   rules,
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			MatchPolicy:             &matchPolicy,
+			TimeoutSeconds:          &timeoutSeconds,
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+}
+
+// RegisterWebhook creates or updates the ValidatingWebhookConfiguration of the admission webhook
+func RegisterWebhook(client kubernetes.Interface, config *common.AdmissionWebhookConfig, caBundle []byte, watchedResources []schema.GroupVersionResource) error {
+	if len(caBundle) == 0 {
+		return fmt.Errorf("caBundleFile is required to register a webhook with a custom serving certificate")
+	}
+	webhooksClient := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	webhook := webhookConfiguration(config, caBundle, watchedResources)
+
+	_, err := webhooksClient.Create(context.Background(), webhook, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		existing, getErr := webhooksClient.Get(context.Background(), webhook.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		webhook.ResourceVersion = existing.ResourceVersion
+		_, err = webhooksClient.Update(context.Background(), webhook, metav1.UpdateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package admission
+
+import (
+	"context"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"main.go/common"
+)
+
+// TestServingCertificate tests generating a self-signed serving certificate for the collector service
+func TestServingCertificate(t *testing.T) {
+	config := &common.AdmissionWebhookConfig{ServiceName: "k8s-events", ServiceNamespace: "monitoring"}
+	certificate, caBundle, err := servingCertificate(config)
+	if err != nil {
+		t.Fatalf("Failed to generate serving certificate: %v", err)
+	}
+	if len(certificate.Certificate) == 0 || len(caBundle) == 0 {
+		t.Error("Expected a certificate and CA bundle")
+	}
+
+	if _, _, err = servingCertificate(&common.AdmissionWebhookConfig{}); err == nil {
+		t.Error("Expected an error without a service to generate a certificate for")
+	}
+}
+
+// TestRegisterWebhook tests creating and updating the ValidatingWebhookConfiguration
+func TestRegisterWebhook(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	config := &common.AdmissionWebhookConfig{ServiceName: "k8s-events", ServiceNamespace: "monitoring"}
+	watchedResources := []schema.GroupVersionResource{{Group: "apps", Version: "v1", Resource: "deployments"}}
+
+	for _, caBundle := range []string{"ca-1", "ca-2"} {
+		if err := RegisterWebhook(client, config, []byte(caBundle), watchedResources); err != nil {
+			t.Fatalf("Failed to register webhook: %v", err)
+		}
+	}
+
+	webhookConfiguration, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), common.DefaultAdmissionWebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get webhook configuration: %v", err)
+	}
+	webhook := webhookConfiguration.Webhooks[0]
+	if string(webhook.ClientConfig.CABundle) != "ca-2" {
+		t.Errorf("Expected the updated CA bundle, got %s", webhook.ClientConfig.CABundle)
+	}
+	if *webhook.FailurePolicy != admissionregistrationv1.Ignore || *webhook.ClientConfig.Service.Path != common.DefaultAdmissionWebhookPath {
+		t.Errorf("Expected a fail open webhook on the default path, got %+v", webhook)
+	}
+	if len(webhook.Rules) != 1 || webhook.Rules[0].Resources[0] != "deployments" || len(webhook.Rules[0].Operations) != 3 {
+		t.Errorf("Unexpected webhook rules: %+v", webhook.Rules)
+	}
+
+	if err = RegisterWebhook(client, config, nil, watchedResources); err == nil {
+		t.Error("Expected an error without a CA bundle")
+	}
+}
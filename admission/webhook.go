@@ -0,0 +1,267 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"main.go/common"
+	"main.go/resources"
+)
+
+// maxRequestBodyBytes limits the size of an admission review
+const maxRequestBodyBytes = 8 << 20
+
+// certificateValidity is the validity of the generated self-signed serving certificate, it is regenerated on startup
+const certificateValidity = 365 * 24 * time.Hour
+
+// operationEventTypes maps the admission operations to the informer event types they cause
+var operationEventTypes = map[admissionv1.Operation]string{
+	admissionv1.Create: common.EventTypeAdded,
+	admissionv1.Update: common.EventTypeModified,
+	// Deleting an object with finalizers updates it, so deletes match any event type
+	admissionv1.Delete: "",
+}
+
+// attempt is an admitted change that waits for its informer event
+type attempt struct {
+	event    map[string]interface{}
+	admitted time.Time
+}
+
+// attempts are the admitted changes by admission request UID
+var attempts = map[string]attempt{}
+var attemptsMux sync.Mutex
+
+// reportEvent structures and sends the events of the admission webhook
+var reportEvent = func(event map[string]interface{}) {
+	resources.StructResourceLog(event)
+}
+
+// decodeObject decodes a raw admission object, returning nil for empty objects
+func decodeObject(raw []byte) (obj map[string]interface{}, err error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	err = json.Unmarshal(raw, &obj)
+	return obj, err
+}
+
+// isNoOpUpdate checks whether an update doesn't change the object, such updates cause no informer events
+func isNoOpUpdate(oldObject, newObject map[string]interface{}) bool {
+	strip := func(obj map[string]interface{}) map[string]interface{} {
+		stripped := map[string]interface{}{}
+		for key, value := range obj {
+			stripped[key] = value
+		}
+		if metadata, ok := obj[common.Metadata].(map[string]interface{}); ok {
+			strippedMetadata := map[string]interface{}{}
+			for key, value := range metadata {
+				if key != common.ManagedFields && key != common.ResourceVersion && key != "generation" {
+					strippedMetadata[key] = value
+				}
+			}
+			stripped[common.Metadata] = strippedMetadata
+		}
+		return stripped
+	}
+	return reflect.DeepEqual(strip(oldObject), strip(newObject))
+}
+
+// requestIdentity returns the identity of the user of an admission request
+func requestIdentity(request *admissionv1.AdmissionRequest) common.RequestIdentity {
+	return common.RequestIdentity{
+		Source:      common.RequestSourceAdmission,
+		Username:    request.UserInfo.Username,
+		Groups:      request.UserInfo.Groups,
+		Verb:        strings.ToLower(string(request.Operation)),
+		RequestID:   string(request.UID),
+		RequestTime: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// handleRequest records an admission request. Admitted changes are buffered to be attributed to their informer events,
+// and dry run changes are reported if enabled.
+func handleRequest(request *admissionv1.AdmissionRequest, buffer *common.AttributionBuffer, reportDryRun bool) error {
+	eventType, isChange := operationEventTypes[request.Operation]
+	if !isChange || request.SubResource != "" {
+		return nil
+	}
+	newObject, err := decodeObject(request.Object.Raw)
+	if err != nil {
+		return fmt.Errorf("failed to decode object: %w", err)
+	}
+	oldObject, err := decodeObject(request.OldObject.Raw)
+	if err != nil {
+		return fmt.Errorf("failed to decode old object: %w", err)
+	}
+	if request.Operation == admissionv1.Delete {
+		// The deleted object is the new object of delete events
+		newObject, oldObject = oldObject, nil
+	}
+	if request.Operation == admissionv1.Update && isNoOpUpdate(oldObject, newObject) {
+		return nil
+	}
+
+	identity := requestIdentity(request)
+	event := map[string]interface{}{
+		"newObject":   newObject,
+		"operation":   string(request.Operation),
+		"requestedBy": identity,
+	}
+	if oldObject != nil {
+		event["oldObject"] = oldObject
+	}
+
+	if request.DryRun != nil && *request.DryRun {
+		if reportDryRun {
+			event["eventType"] = common.EventTypeDryRun
+			go reportEvent(event)
+		}
+		return nil
+	}
+	// Objects created with a generated name can't be matched to their informer events
+	if request.Name == "" {
+		return nil
+	}
+
+	ref := common.ObjectReference{
+		Resource:  request.Resource.Resource,
+		Namespace: request.Namespace,
+		Name:      request.Name,
+		EventType: eventType,
+	}
+	// Created objects have no UID yet
+	ref.UID, _, _ = unstructured.NestedString(newObject, common.Metadata, "uid")
+
+	event["eventType"] = common.EventTypeRejected
+	attemptsMux.Lock()
+	attempts[identity.RequestID] = attempt{event: event, admitted: time.Now()}
+	attemptsMux.Unlock()
+	buffer.Add(ref, identity)
+	return nil
+}
+
+// reportRejected reports the admitted changes that expired without an informer event, as they were rejected
+// by another admission controller or failed to be persisted. Expired changes are pruned while new admission requests
+// are buffered, so they are reported in the background to keep the event pipeline off the admission path.
+func reportRejected(ref common.ObjectReference, identity common.RequestIdentity) {
+	if identity.Source != common.RequestSourceAdmission {
+		return
+	}
+	attemptsMux.Lock()
+	rejected, ok := attempts[identity.RequestID]
+	delete(attempts, identity.RequestID)
+	attemptsMux.Unlock()
+	if ok {
+		go reportEvent(rejected.event)
+	}
+}
+
+// pruneAttempts removes the attempts that were matched to their informer events
+func pruneAttempts(maxAge time.Duration) {
+	attemptsMux.Lock()
+	defer attemptsMux.Unlock()
+	for requestID, admittedAttempt := range attempts {
+		if time.Since(admittedAttempt.admitted) > maxAge {
+			delete(attempts, requestID)
+		}
+	}
+}
+
+// NewHandler returns the HTTP handler of admission reviews. It always allows the request, even if it fails to record it.
+func NewHandler(buffer *common.AttributionBuffer, reportDryRun bool) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var review admissionv1.AdmissionReview
+		err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestBodyBytes)).Decode(&review)
+		if err != nil || review.Request == nil {
+			// The webhook fails open, so the API server admits the request anyway
+			log.Printf("[ERROR] Failed to decode admission review.\nERROR:\n%v", err)
+			http.Error(writer, "failed to decode admission review", http.StatusBadRequest)
+			return
+		}
+
+		if err = handleRequest(review.Request, buffer, reportDryRun); err != nil {
+			log.Printf("[ERROR] Failed to record admission request: %s.\nERROR:\n%v", review.Request.UID, err)
+		}
+
+		response := admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Response: &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true},
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(writer).Encode(response); err != nil {
+			log.Printf("[ERROR] Failed to write admission response.\nERROR:\n%v", err)
+		}
+	})
+}
+
+// StartAdmissionWebhook starts the admission webhook, if it is enabled in the configuration,
+// and registers it for the watched resources if registration is enabled
+func StartAdmissionWebhook(watchedResources []schema.GroupVersionResource) {
+	config := common.Config.AdmissionWebhook
+	if config == nil {
+		return
+	}
+
+	address, path := config.Address, config.Path
+	if address == "" {
+		address = common.DefaultAdmissionWebhookAddress
+	}
+	if path == "" {
+		path = common.DefaultAdmissionWebhookPath
+	}
+	certificate, caBundle, err := servingCertificate(config)
+	if err != nil {
+		log.Fatalf("\n[FATAL] Failed to start admission webhook.\nERROR: %v\n", err)
+	}
+
+	buffer := common.ConfigureAttributions(config.BufferWindow.Duration, config.MatchWait.Duration)
+	buffer.SetExpiredHandler(reportRejected)
+	mux := http.NewServeMux()
+	mux.Handle(path, NewHandler(buffer, config.ReportDryRun))
+	server, err := common.NewTLSServer(address, mux, certificate, "")
+	if err != nil {
+		log.Fatalf("\n[FATAL] Failed to start admission webhook.\nERROR: %v\n", err)
+	}
+	common.ServeTLS("admission webhook", server)
+
+	// Periodically expire the admitted changes that were not matched to informer events
+	go func() {
+		window := config.BufferWindow.Duration
+		if window <= 0 {
+			window = common.DefaultAttributionBufferWindow
+		}
+		for range time.Tick(window / 2) {
+			buffer.Prune()
+			pruneAttempts(2 * window)
+		}
+	}()
+
+	if config.Register {
+		if common.K8sClient == nil {
+			common.CreateClusterClient()
+		}
+		if common.K8sClient == nil {
+			log.Printf("[ERROR] Failed to register admission webhook, Kubernetes client is not configured.")
+			return
+		}
+		if err = RegisterWebhook(common.K8sClient, config, caBundle, watchedResources); err != nil {
+			log.Printf("[ERROR] Failed to register admission webhook.\nERROR:\n%v", err)
+		}
+	}
+}
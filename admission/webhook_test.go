@@ -0,0 +1,180 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"main.go/common"
+)
+
+// captureReportedEvents replaces the event reporter with one that records the reported events
+func captureReportedEvents(t *testing.T) (reported func() []map[string]interface{}) {
+	var mux sync.Mutex
+	var events []map[string]interface{}
+	originalReportEvent := reportEvent
+	reportEvent = func(event map[string]interface{}) {
+		mux.Lock()
+		defer mux.Unlock()
+		events = append(events, event)
+	}
+	t.Cleanup(func() { reportEvent = originalReportEvent })
+	return func() []map[string]interface{} {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]map[string]interface{}{}, events...)
+	}
+}
+
+// getTestAdmissionRequest returns an admission request of a deployment change, a negative replicas count omits the object
+func getTestAdmissionRequest(uid string, operation admissionv1.Operation, oldReplicas, newReplicas int, dryRun bool) *admissionv1.AdmissionRequest {
+	deployment := func(replicas int) runtime.RawExtension {
+		if replicas < 0 {
+			return runtime.RawExtension{}
+		}
+		raw, _ := json.Marshal(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "default", "uid": "uid-1", "resourceVersion": "42"},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		})
+		return runtime.RawExtension{Raw: raw}
+	}
+	return &admissionv1.AdmissionRequest{
+		UID:       types.UID(uid),
+		Resource:  metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Name:      "web",
+		Namespace: "default",
+		Operation: operation,
+		UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: []string{"developers"}},
+		Object:    deployment(newReplicas),
+		OldObject: deployment(oldReplicas),
+		DryRun:    &dryRun,
+	}
+}
+
+// TestHandlerAllows tests that admission reviews are always allowed
+func TestHandlerAllows(t *testing.T) {
+	captureReportedEvents(t)
+	buffer := common.NewAttributionBuffer(time.Minute)
+	review := admissionv1.AdmissionReview{Request: getTestAdmissionRequest("request-1", admissionv1.Update, 1, 2, false)}
+	body, _ := json.Marshal(review)
+
+	recorder := httptest.NewRecorder()
+	NewHandler(buffer, false).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	var response admissionv1.AdmissionReview
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode admission response: %v", err)
+	}
+	if response.Response == nil || !response.Response.Allowed || response.Response.UID != "request-1" {
+		t.Errorf("Expected an allowed response of request-1, got %+v", response.Response)
+	}
+
+	identity, ok := buffer.Match(common.ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "43", EventType: common.EventTypeModified}, 0)
+	if !ok || identity.Username != "alice" || identity.Source != common.RequestSourceAdmission || identity.Verb != "update" {
+		t.Errorf("Expected the identity of alice to match, got %+v %v", identity, ok)
+	}
+
+	// Invalid reviews are rejected by the webhook, and admitted by the fail open API server
+	recorder = httptest.NewRecorder()
+	NewHandler(buffer, false).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{"))))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid review, got %d", recorder.Code)
+	}
+}
+
+// TestHandleRequestRejected tests that admitted changes without informer events are reported as rejected
+func TestHandleRequestRejected(t *testing.T) {
+	reported := captureReportedEvents(t)
+	buffer := common.NewAttributionBuffer(20 * time.Millisecond)
+	buffer.SetExpiredHandler(reportRejected)
+
+	if err := handleRequest(getTestAdmissionRequest("request-2", admissionv1.Update, 1, 2, false), buffer, false); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	if err := handleRequest(getTestAdmissionRequest("request-3", admissionv1.Delete, 2, -1, false), buffer, false); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	// The delete is matched by its informer event
+	if _, ok := buffer.Match(common.ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", EventType: common.EventTypeDeleted}, 0); !ok {
+		t.Fatal("Expected the delete to match")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	buffer.Prune()
+	time.Sleep(20 * time.Millisecond)
+	events := reported()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 rejected event, got %d", len(events))
+	}
+	if events[0]["eventType"] != common.EventTypeRejected || events[0]["operation"] != "UPDATE" {
+		t.Errorf("Expected a rejected update, got %v", events[0])
+	}
+	if identity := events[0]["requestedBy"].(common.RequestIdentity); identity.RequestID != "request-2" {
+		t.Errorf("Expected the identity of request-2, got %+v", identity)
+	}
+}
+
+// TestHandleRequestRejectedInBackground tests that admission requests don't wait for the reports of the expired changes
+// they prune
+func TestHandleRequestRejectedInBackground(t *testing.T) {
+	release := make(chan struct{})
+	originalReportEvent := reportEvent
+	reportEvent = func(event map[string]interface{}) { <-release }
+	t.Cleanup(func() {
+		close(release)
+		reportEvent = originalReportEvent
+	})
+	buffer := common.NewAttributionBuffer(10 * time.Millisecond)
+	buffer.SetExpiredHandler(reportRejected)
+
+	if err := handleRequest(getTestAdmissionRequest("request-7", admissionv1.Update, 1, 2, false), buffer, false); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		_ = handleRequest(getTestAdmissionRequest("request-8", admissionv1.Update, 2, 3, false), buffer, false)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the admission request not to wait for the rejected event report")
+	}
+}
+
+// TestHandleRequestDryRun tests that dry run changes are only reported when enabled, and no-op updates are ignored
+func TestHandleRequestDryRun(t *testing.T) {
+	reported := captureReportedEvents(t)
+	buffer := common.NewAttributionBuffer(time.Minute)
+
+	if err := handleRequest(getTestAdmissionRequest("request-4", admissionv1.Update, 1, 2, true), buffer, false); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	if err := handleRequest(getTestAdmissionRequest("request-5", admissionv1.Create, -1, 1, true), buffer, true); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	if err := handleRequest(getTestAdmissionRequest("request-6", admissionv1.Update, 1, 1, false), buffer, true); err != nil {
+		t.Fatalf("Failed to handle request: %v", err)
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("Expected dry run and no-op requests not to be buffered, got %d", buffer.Len())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	events := reported()
+	if len(events) != 1 || events[0]["eventType"] != common.EventTypeDryRun || events[0]["operation"] != "CREATE" {
+		t.Errorf("Expected a single dry run create event, got %v", events)
+	}
+}
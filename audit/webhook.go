@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"main.go/common"
+)
+
+// maxRequestBodyBytes limits the size of an audit event batch
+const maxRequestBodyBytes = 32 << 20
+
+// auditEventList is the part of an audit.k8s.io/v1 EventList used to attribute changes
+type auditEventList struct {
+	Kind       string       `json:"kind"`
+	APIVersion string       `json:"apiVersion"`
+	Items      []auditEvent `json:"items"`
+}
+
+// userInfo is the user of an audit event
+type userInfo struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// objectReference is the object of an audit event
+type objectReference struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	UID         string `json:"uid,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// responseStatus is the response status of an audit event
+type responseStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+// responseObject is the metadata of the response object, logged at the RequestResponse audit level
+type responseObject struct {
+	Metadata struct {
+		UID             string `json:"uid,omitempty"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+}
+
+// auditEvent is the part of an audit.k8s.io/v1 Event used to attribute changes
+type auditEvent struct {
+	AuditID                  string           `json:"auditID"`
+	Stage                    string           `json:"stage"`
+	Verb                     string           `json:"verb"`
+	User                     userInfo         `json:"user"`
+	ImpersonatedUser         *userInfo        `json:"impersonatedUser,omitempty"`
+	SourceIPs                []string         `json:"sourceIPs,omitempty"`
+	UserAgent                string           `json:"userAgent,omitempty"`
+	ObjectRef                *objectReference `json:"objectRef,omitempty"`
+	ResponseStatus           *responseStatus  `json:"responseStatus,omitempty"`
+	ResponseObject           *responseObject  `json:"responseObject,omitempty"`
+	RequestReceivedTimestamp string           `json:"requestReceivedTimestamp,omitempty"`
+}
+
+// verbEventTypes maps the audited verbs that change resources to the informer event types they cause
+var verbEventTypes = map[string]string{
+	"create": common.EventTypeAdded,
+	"update": common.EventTypeModified,
+	"patch":  common.EventTypeModified,
+	"delete": common.EventTypeDeleted,
+}
+
+// requestRecord returns the object reference and identity of an audit event,
+// if the event is a completed successful change of a watched resource
+func requestRecord(event auditEvent, isWatched func(group, resource string) bool) (ref common.ObjectReference, identity common.RequestIdentity, ok bool) {
+	eventType, isChange := verbEventTypes[event.Verb]
+	if event.Stage != "ResponseComplete" || !isChange || event.ObjectRef == nil {
+		return ref, identity, false
+	}
+	// Subresource changes, such as status updates, are not reported as resource changes
+	if event.ObjectRef.Subresource != "" || event.ObjectRef.Name == "" {
+		return ref, identity, false
+	}
+	if event.ResponseStatus != nil && (event.ResponseStatus.Code < 200 || event.ResponseStatus.Code >= 300) {
+		return ref, identity, false
+	}
+	if isWatched != nil && !isWatched(event.ObjectRef.APIGroup, event.ObjectRef.Resource) {
+		return ref, identity, false
+	}
+
+	ref = common.ObjectReference{
+		Resource:  event.ObjectRef.Resource,
+		Namespace: event.ObjectRef.Namespace,
+		Name:      event.ObjectRef.Name,
+		UID:       event.ObjectRef.UID,
+		EventType: eventType,
+	}
+	if event.ResponseObject != nil {
+		if ref.UID == "" {
+			ref.UID = event.ResponseObject.Metadata.UID
+		}
+		if eventType != common.EventTypeDeleted {
+			ref.ResourceVersion = event.ResponseObject.Metadata.ResourceVersion
+		}
+	}
+
+	identity = common.RequestIdentity{
+		Source:      common.RequestSourceAudit,
+		Username:    event.User.Username,
+		Groups:      event.User.Groups,
+		UserAgent:   event.UserAgent,
+		SourceIPs:   event.SourceIPs,
+		Verb:        event.Verb,
+		RequestID:   event.AuditID,
+		RequestTime: event.RequestReceivedTimestamp,
+	}
+	// Impersonated requests are attributed to the impersonated user
+	if event.ImpersonatedUser != nil && event.ImpersonatedUser.Username != "" {
+		identity.Username = event.ImpersonatedUser.Username
+		identity.Groups = event.ImpersonatedUser.Groups
+		identity.ImpersonatedBy = event.User.Username
+	}
+	return ref, identity, true
+}
+
+// NewHandler returns the HTTP handler of audit event batches, that buffers the identities of watched resource changes
+func NewHandler(buffer *common.AttributionBuffer, isWatched func(group, resource string) bool) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var eventList auditEventList
+		err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestBodyBytes)).Decode(&eventList)
+		if err != nil {
+			log.Printf("[ERROR] Failed to decode audit event batch.\nERROR:\n%v", err)
+			http.Error(writer, fmt.Sprintf("failed to decode audit event batch: %v", err), http.StatusBadRequest)
+			return
+		}
+		if eventList.Kind != "EventList" || eventList.APIVersion != "audit.k8s.io/v1" {
+			http.Error(writer, fmt.Sprintf("unsupported audit event batch: %s %s", eventList.APIVersion, eventList.Kind), http.StatusBadRequest)
+			return
+		}
+
+		for _, event := range eventList.Items {
+			if ref, identity, ok := requestRecord(event, isWatched); ok {
+				buffer.Add(ref, identity)
+			}
+		}
+		writer.WriteHeader(http.StatusOK)
+	})
+}
+
+// StartAuditWebhook starts the audit webhook receiver, if it is enabled in the configuration
+func StartAuditWebhook(isWatched func(group, resource string) bool) {
+	config := common.Config.AuditWebhook
+	if config == nil {
+		return
+	}
+
+	address, path := config.Address, config.Path
+	if address == "" {
+		address = common.DefaultAuditWebhookAddress
+	}
+	if path == "" {
+		path = common.DefaultAuditWebhookPath
+	}
+	certificate, err := common.LoadTLSCertificate(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		// If the receiver is enabled without a valid certificate, log the error and exit
+		log.Fatalf("\n[FATAL] Failed to start audit webhook receiver.\nERROR: %v\n", err)
+	}
+
+	buffer := common.ConfigureAttributions(config.BufferWindow.Duration, config.MatchWait.Duration)
+	mux := http.NewServeMux()
+	mux.Handle(path, NewHandler(buffer, isWatched))
+	server, err := common.NewTLSServer(address, mux, certificate, config.ClientCAFile)
+	if err != nil {
+		log.Fatalf("\n[FATAL] Failed to start audit webhook receiver.\nERROR: %v\n", err)
+	}
+	common.ServeTLS("audit webhook receiver", server)
+}
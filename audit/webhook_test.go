@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"main.go/common"
+)
+
+// testEventList is an audit event batch with a successful update, a failed update, a status update and a read
+const testEventList = `{
+  "kind": "EventList",
+  "apiVersion": "audit.k8s.io/v1",
+  "items": [
+    {
+      "auditID": "audit-1", "stage": "ResponseComplete", "verb": "patch",
+      "user": {"username": "kubernetes-admin", "groups": ["system:masters"]},
+      "impersonatedUser": {"username": "alice", "groups": ["developers"]},
+      "sourceIPs": ["10.0.0.1"], "userAgent": "kubectl/v1.30.0",
+      "objectRef": {"resource": "deployments", "namespace": "default", "name": "web", "apiGroup": "apps"},
+      "responseStatus": {"code": 200},
+      "responseObject": {"metadata": {"uid": "uid-1", "resourceVersion": "42"}},
+      "requestReceivedTimestamp": "2024-01-01T00:00:00.000000Z"
+    },
+    {
+      "auditID": "audit-2", "stage": "ResponseComplete", "verb": "update",
+      "user": {"username": "bob"},
+      "objectRef": {"resource": "deployments", "namespace": "default", "name": "api", "apiGroup": "apps"},
+      "responseStatus": {"code": 409}
+    },
+    {
+      "auditID": "audit-3", "stage": "ResponseComplete", "verb": "update",
+      "user": {"username": "system:serviceaccount:kube-system:deployment-controller"},
+      "objectRef": {"resource": "deployments", "namespace": "default", "name": "web", "apiGroup": "apps", "subresource": "status"},
+      "responseStatus": {"code": 200}
+    },
+    {
+      "auditID": "audit-4", "stage": "ResponseComplete", "verb": "get",
+      "user": {"username": "bob"},
+      "objectRef": {"resource": "secrets", "namespace": "default", "name": "token"},
+      "responseStatus": {"code": 200}
+    },
+    {
+      "auditID": "audit-5", "stage": "ResponseComplete", "verb": "create",
+      "user": {"username": "bob"},
+      "objectRef": {"resource": "pods", "namespace": "default", "name": "web-1"},
+      "responseStatus": {"code": 201}
+    }
+  ]
+}`
+
+// isTestWatched watches deployments and secrets
+func isTestWatched(group, resource string) bool {
+	return (group == "apps" && resource == "deployments") || (group == "" && resource == "secrets")
+}
+
+// TestHandler tests that only successful changes of watched resources are buffered
+func TestHandler(t *testing.T) {
+	buffer := common.NewAttributionBuffer(time.Minute)
+	recorder := httptest.NewRecorder()
+	NewHandler(buffer, isTestWatched).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/audit", strings.NewReader(testEventList)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	if buffer.Len() != 1 {
+		t.Fatalf("Expected 1 buffered record, got %d", buffer.Len())
+	}
+
+	identity, ok := buffer.Match(common.ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "42", EventType: common.EventTypeModified}, 0)
+	if !ok {
+		t.Fatal("Expected the deployment update to match")
+	}
+	if identity.Username != "alice" || identity.ImpersonatedBy != "kubernetes-admin" || identity.Groups[0] != "developers" {
+		t.Errorf("Expected the impersonated user, got %+v", identity)
+	}
+	if identity.Source != "audit" || identity.UserAgent != "kubectl/v1.30.0" || identity.SourceIPs[0] != "10.0.0.1" || identity.RequestID != "audit-1" {
+		t.Errorf("Unexpected request identity: %+v", identity)
+	}
+}
+
+// TestHandlerInvalidBatch tests that invalid requests are rejected
+func TestHandlerInvalidBatch(t *testing.T) {
+	buffer := common.NewAttributionBuffer(time.Minute)
+	for _, testCase := range []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "{", http.StatusBadRequest},
+		{http.MethodPost, `{"kind": "PodList", "apiVersion": "v1"}`, http.StatusBadRequest},
+	} {
+		recorder := httptest.NewRecorder()
+		NewHandler(buffer, nil).ServeHTTP(recorder, httptest.NewRequest(testCase.method, "/audit", strings.NewReader(testCase.body)))
+		if recorder.Code != testCase.code {
+			t.Errorf("Expected status %d for %s %q, got %d", testCase.code, testCase.method, testCase.body, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package common
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources of request identities
+const (
+	RequestSourceAudit     = "audit"
+	RequestSourceAdmission = "admission"
+)
+
+// RequestIdentity describes the user that requested a change of a resource
+type RequestIdentity struct {
+	Source         string   `json:"source,omitempty"`
+	Username       string   `json:"username,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+	UserAgent      string   `json:"userAgent,omitempty"`
+	SourceIPs      []string `json:"sourceIPs,omitempty"`
+	ImpersonatedBy string   `json:"impersonatedBy,omitempty"`
+	Verb           string   `json:"verb,omitempty"`
+	RequestID      string   `json:"requestID,omitempty"`
+	RequestTime    string   `json:"requestTime,omitempty"`
+}
+
+// ObjectReference identifies the resource of a change request or event
+type ObjectReference struct {
+	Resource        string
+	Namespace       string
+	Name            string
+	UID             string
+	ResourceVersion string
+	EventType       string
+	// ChangeTime is the time of the change of an event resource, used to choose between requests of the same resource
+	ChangeTime time.Time
+}
+
+// attributionRecord is a buffered request identity waiting to be matched to an informer event
+type attributionRecord struct {
+	ref      ObjectReference
+	identity RequestIdentity
+	received time.Time
+}
+
+// AttributionBuffer buffers request identities for a short window, until they are matched to informer events
+type AttributionBuffer struct {
+	window  time.Duration
+	mux     sync.Mutex
+	records []attributionRecord
+	added   chan struct{}
+	now     func() time.Time
+	expired func(ref ObjectReference, identity RequestIdentity)
+}
+
+// Attributions is the buffer of request identities, it is nil when no request identity source is configured
+var Attributions *AttributionBuffer
+
+// AttributionMatchWait is how long an event waits for the identity of its request to be buffered
+var AttributionMatchWait = DefaultAttributionMatchWait
+
+// NewAttributionBuffer creates a buffer that keeps unmatched request identities for the given window
+func NewAttributionBuffer(window time.Duration) *AttributionBuffer {
+	return &AttributionBuffer{window: window, added: make(chan struct{}), now: time.Now}
+}
+
+// ConfigureAttributions creates the request identities buffer, if it wasn't created yet.
+// A positive match wait replaces the current wait.
+func ConfigureAttributions(window, matchWait time.Duration) *AttributionBuffer {
+	if window <= 0 {
+		window = DefaultAttributionBufferWindow
+	}
+	if Attributions == nil {
+		Attributions = NewAttributionBuffer(window)
+	}
+	if matchWait > 0 {
+		AttributionMatchWait = matchWait
+	}
+	return Attributions
+}
+
+// SetExpiredHandler sets a handler that is called with the identities that expired without being matched
+func (buffer *AttributionBuffer) SetExpiredHandler(handler func(ref ObjectReference, identity RequestIdentity)) {
+	buffer.mux.Lock()
+	defer buffer.mux.Unlock()
+	buffer.expired = handler
+}
+
+// Add buffers the identity of a request for the referenced resource
+func (buffer *AttributionBuffer) Add(ref ObjectReference, identity RequestIdentity) {
+	buffer.mux.Lock()
+	expiredRecords := buffer.pruneLocked()
+	buffer.records = append(buffer.records, attributionRecord{ref: ref, identity: identity, received: buffer.now()})
+
+	// Wake up the pending matches
+	close(buffer.added)
+	buffer.added = make(chan struct{})
+	buffer.mux.Unlock()
+	buffer.notifyExpired(expiredRecords)
+}
+
+// Len returns the number of buffered request identities
+func (buffer *AttributionBuffer) Len() int {
+	buffer.Prune()
+	buffer.mux.Lock()
+	defer buffer.mux.Unlock()
+	return len(buffer.records)
+}
+
+// Prune removes the identities older than the buffer window, calling the expired handler for them
+func (buffer *AttributionBuffer) Prune() {
+	buffer.mux.Lock()
+	expiredRecords := buffer.pruneLocked()
+	buffer.mux.Unlock()
+	buffer.notifyExpired(expiredRecords)
+}
+
+// Match returns the identity of the request that caused an informer event, waiting up to the given duration
+// for the request to be buffered. Matched identities are removed from the buffer.
+func (buffer *AttributionBuffer) Match(ref ObjectReference, wait time.Duration) (identity RequestIdentity, ok bool) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	for {
+		buffer.mux.Lock()
+		expiredRecords := buffer.pruneLocked()
+		identity, ok = buffer.matchLocked(ref)
+		added := buffer.added
+		buffer.mux.Unlock()
+		buffer.notifyExpired(expiredRecords)
+		if ok {
+			return identity, true
+		}
+
+		select {
+		case <-added:
+		case <-deadline.C:
+			return identity, false
+		}
+	}
+}
+
+// requestTime returns the time of the request of a record, if it is known
+func (record attributionRecord) requestTime() (requestTime time.Time, ok bool) {
+	requestTime, err := time.Parse(time.RFC3339Nano, record.identity.RequestTime)
+	return requestTime, err == nil
+}
+
+// isRequestedBefore checks whether a request was received at or before a change.
+// Object timestamps have a precision of seconds, so requests within the second of such a change time precede it.
+func isRequestedBefore(requestTime, changeTime time.Time) bool {
+	if changeTime.Nanosecond() == 0 {
+		requestTime = requestTime.Truncate(time.Second)
+	}
+	return !requestTime.After(changeTime)
+}
+
+// matchLocked finds and removes the best matching record, the buffer lock must be held.
+// Records of the same resource are matched by the request closest to, and not after, the change time of the event,
+// falling back to the latest record without a request time. Records of requests after the change are never matched,
+// and are kept for the next changes. Other matching records requested before the change, such as records of the same
+// request from other sources or of superseded requests, are removed as well.
+func (buffer *AttributionBuffer) matchLocked(ref ObjectReference) (identity RequestIdentity, ok bool) {
+	changeTime := ref.ChangeTime
+	if changeTime.IsZero() {
+		changeTime = buffer.now()
+	}
+	matchIndex, closestIndex := -1, -1
+	var closestTime time.Time
+	for index, record := range buffer.records {
+		isMatch, isExact := referencesMatch(record.ref, ref)
+		if isExact {
+			// An exact UID and resource version match is the strongest possible match
+			matchIndex, closestIndex = index, -1
+			break
+		}
+		if !isMatch {
+			continue
+		}
+		requestTime, hasRequestTime := record.requestTime()
+		if !hasRequestTime {
+			matchIndex = index
+		} else if isRequestedBefore(requestTime, changeTime) && !requestTime.Before(closestTime) {
+			closestIndex, closestTime = index, requestTime
+		}
+	}
+	if closestIndex >= 0 {
+		matchIndex = closestIndex
+	}
+	if matchIndex < 0 {
+		return identity, false
+	}
+
+	identity = buffer.records[matchIndex].identity
+	var kept []attributionRecord
+	for index, record := range buffer.records {
+		if index == matchIndex {
+			continue
+		}
+		if isMatch, _ := referencesMatch(record.ref, ref); isMatch {
+			if requestTime, ok := record.requestTime(); !ok || isRequestedBefore(requestTime, changeTime) {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	buffer.records = kept
+	return identity, true
+}
+
+// referencesMatch checks whether a buffered request reference matches an event reference.
+// Fields that are missing in either reference are not compared, except for the namespace and name.
+func referencesMatch(recordRef, ref ObjectReference) (isMatch bool, isExact bool) {
+	if ref.UID != "" && ref.ResourceVersion != "" && recordRef.UID == ref.UID && recordRef.ResourceVersion == ref.ResourceVersion {
+		return true, true
+	}
+	if recordRef.Name != ref.Name || recordRef.Namespace != ref.Namespace {
+		return false, false
+	}
+	for _, fields := range [][2]string{
+		{recordRef.Resource, ref.Resource},
+		{recordRef.EventType, ref.EventType},
+		{recordRef.UID, ref.UID},
+		{recordRef.ResourceVersion, ref.ResourceVersion},
+	} {
+		if fields[0] != "" && fields[1] != "" && fields[0] != fields[1] {
+			return false, false
+		}
+	}
+	return true, false
+}
+
+// pruneLocked removes and returns the records older than the buffer window, the buffer lock must be held
+func (buffer *AttributionBuffer) pruneLocked() (expiredRecords []attributionRecord) {
+	var kept []attributionRecord
+	for _, record := range buffer.records {
+		if buffer.now().Sub(record.received) <= buffer.window {
+			kept = append(kept, record)
+		} else {
+			expiredRecords = append(expiredRecords, record)
+		}
+	}
+	buffer.records = kept
+	return expiredRecords
+}
+
+// notifyExpired calls the expired handler with the expired records, the buffer lock must not be held
+func (buffer *AttributionBuffer) notifyExpired(expiredRecords []attributionRecord) {
+	buffer.mux.Lock()
+	handler := buffer.expired
+	buffer.mux.Unlock()
+	if handler == nil {
+		return
+	}
+	for _, record := range expiredRecords {
+		handler(record.ref, record.identity)
+	}
+}
+
+// KindToResource returns the lowercase plural resource name of a resource kind, for example "Deployment" to "deployments"
+func KindToResource(kind string) string {
+	resource := strings.ToLower(kind)
+	switch {
+	case resource == "":
+		return ""
+	case strings.HasSuffix(resource, "s"), strings.HasSuffix(resource, "x"), strings.HasSuffix(resource, "ch"), strings.HasSuffix(resource, "sh"):
+		return resource + "es"
+	case len(resource) > 1 && strings.HasSuffix(resource, "y") && !strings.ContainsAny(resource[len(resource)-2:len(resource)-1], "aeiou"):
+		return resource[:len(resource)-1] + "ies"
+	}
+	return resource + "s"
+}
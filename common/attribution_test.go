@@ -0,0 +1,157 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAttributionBufferMatch tests matching buffered request identities to informer events
+func TestAttributionBufferMatch(t *testing.T) {
+	buffer := NewAttributionBuffer(time.Minute)
+	buffer.Add(ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", EventType: EventTypeModified}, RequestIdentity{Username: "alice"})
+	buffer.Add(ObjectReference{Resource: "configmaps", Namespace: "default", Name: "web", EventType: EventTypeModified}, RequestIdentity{Username: "bob"})
+	buffer.Add(ObjectReference{Resource: "deployments", Namespace: "default", Name: "api", UID: "uid-1", ResourceVersion: "42", EventType: EventTypeModified}, RequestIdentity{Username: "carol"})
+
+	identity, ok := buffer.Match(ObjectReference{Resource: "configmaps", Namespace: "default", Name: "web", UID: "uid-2", ResourceVersion: "7", EventType: EventTypeModified}, 0)
+	if !ok || identity.Username != "bob" {
+		t.Errorf("Expected the config map request of bob, got %v %v", identity, ok)
+	}
+	identity, ok = buffer.Match(ObjectReference{Resource: "deployments", Namespace: "default", Name: "api", UID: "uid-1", ResourceVersion: "42", EventType: EventTypeModified}, 0)
+	if !ok || identity.Username != "carol" {
+		t.Errorf("Expected the exact match of carol, got %v %v", identity, ok)
+	}
+
+	// A different resource version doesn't match
+	if identity, ok = buffer.Match(ObjectReference{Resource: "deployments", Namespace: "default", Name: "api", ResourceVersion: "43", EventType: EventTypeModified}, 0); ok {
+		t.Errorf("Expected no match, got %v", identity)
+	}
+	// A different event type doesn't match
+	if identity, ok = buffer.Match(ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", EventType: EventTypeDeleted}, 0); ok {
+		t.Errorf("Expected no match, got %v", identity)
+	}
+	if buffer.Len() != 1 {
+		t.Errorf("Expected matched identities to be removed, %d left", buffer.Len())
+	}
+}
+
+// TestAttributionBufferMatchTime tests that changes of two users to the same resource are matched to their requests by
+// time, regardless of the order they were buffered in
+func TestAttributionBufferMatchTime(t *testing.T) {
+	ref := ObjectReference{Resource: "configmaps", Namespace: "default", Name: "settings", EventType: EventTypeModified}
+	alice := RequestIdentity{Username: "alice", RequestTime: "2024-01-01T10:00:00.200000Z"}
+	bob := RequestIdentity{Username: "bob", RequestTime: "2024-01-01T10:00:01.500000Z"}
+	for _, identities := range [][]RequestIdentity{{alice, bob}, {bob, alice}} {
+		buffer := NewAttributionBuffer(time.Minute)
+		for _, identity := range identities {
+			buffer.Add(ref, identity)
+		}
+
+		// The change times of objects have a precision of seconds
+		ref.ChangeTime = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+		if identity, ok := buffer.Match(ref, 0); !ok || identity.Username != "alice" {
+			t.Errorf("Expected the first change to be requested by alice, got %v %v", identity, ok)
+		}
+		ref.ChangeTime = time.Date(2024, 1, 1, 10, 0, 1, 0, time.UTC)
+		if identity, ok := buffer.Match(ref, 0); !ok || identity.Username != "bob" {
+			t.Errorf("Expected the second change to be requested by bob, got %v %v", identity, ok)
+		}
+		if buffer.Len() != 0 {
+			t.Errorf("Expected the matched identities to be removed, %d left", buffer.Len())
+		}
+	}
+}
+
+// TestAttributionBufferMatchLaterRequest tests that a request after the change time isn't matched, and is kept for the
+// next change, so a change without an audited request isn't attributed to the user of a later change
+func TestAttributionBufferMatchLaterRequest(t *testing.T) {
+	buffer := NewAttributionBuffer(time.Minute)
+	ref := ObjectReference{Resource: "configmaps", Namespace: "default", Name: "settings", EventType: EventTypeModified}
+	buffer.Add(ref, RequestIdentity{Username: "bob", RequestTime: "2024-01-01T10:00:01.500000Z"})
+
+	ref.ChangeTime = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if identity, ok := buffer.Match(ref, 0); ok {
+		t.Errorf("Expected the first change not to be attributed to a later request, got %v", identity)
+	}
+	ref.ChangeTime = time.Date(2024, 1, 1, 10, 0, 1, 0, time.UTC)
+	if identity, ok := buffer.Match(ref, 0); !ok || identity.Username != "bob" {
+		t.Errorf("Expected the second change to be requested by bob, got %v %v", identity, ok)
+	}
+
+	// Records without a request time are matched to any change
+	buffer.Add(ref, RequestIdentity{Username: "alice"})
+	if identity, ok := buffer.Match(ref, 0); !ok || identity.Username != "alice" {
+		t.Errorf("Expected the request of alice without a request time, got %v %v", identity, ok)
+	}
+}
+
+// TestAttributionBufferWait tests that matches wait for identities buffered after the event
+func TestAttributionBufferWait(t *testing.T) {
+	buffer := NewAttributionBuffer(time.Minute)
+	ref := ObjectReference{Resource: "secrets", Namespace: "default", Name: "token", EventType: EventTypeAdded}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		buffer.Add(ref, RequestIdentity{Username: "alice"})
+	}()
+	identity, ok := buffer.Match(ref, 5*time.Second)
+	if !ok || identity.Username != "alice" {
+		t.Errorf("Expected the late request of alice, got %v %v", identity, ok)
+	}
+}
+
+// TestAttributionBufferWindow tests that identities older than the buffer window are dropped
+func TestAttributionBufferWindow(t *testing.T) {
+	now := time.Now()
+	buffer := NewAttributionBuffer(time.Minute)
+	buffer.now = func() time.Time { return now }
+	ref := ObjectReference{Resource: "secrets", Namespace: "default", Name: "token"}
+	buffer.Add(ref, RequestIdentity{Username: "alice"})
+
+	now = now.Add(2 * time.Minute)
+	if identity, ok := buffer.Match(ref, 0); ok {
+		t.Errorf("Expected the expired identity to be dropped, got %v", identity)
+	}
+}
+
+// TestAttributionBufferExpired tests that expired identities are passed to the expired handler,
+// and that matched changes don't expire from other sources
+func TestAttributionBufferExpired(t *testing.T) {
+	now := time.Now()
+	buffer := NewAttributionBuffer(time.Minute)
+	buffer.now = func() time.Time { return now }
+	var expired []RequestIdentity
+	buffer.SetExpiredHandler(func(ref ObjectReference, identity RequestIdentity) {
+		expired = append(expired, identity)
+	})
+
+	matchedRef := ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", EventType: EventTypeModified}
+	buffer.Add(matchedRef, RequestIdentity{Source: RequestSourceAdmission, Username: "alice"})
+	buffer.Add(ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "42", EventType: EventTypeModified}, RequestIdentity{Source: RequestSourceAudit, Username: "alice"})
+	buffer.Add(ObjectReference{Resource: "secrets", Namespace: "default", Name: "token"}, RequestIdentity{Username: "bob"})
+
+	identity, ok := buffer.Match(ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "42", EventType: EventTypeModified}, 0)
+	if !ok || identity.Source != RequestSourceAudit {
+		t.Errorf("Expected the exact audit match, got %+v %v", identity, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	buffer.Prune()
+	if len(expired) != 1 || expired[0].Username != "bob" {
+		t.Errorf("Expected only the identity of bob to expire, got %v", expired)
+	}
+}
+
+// TestKindToResource tests converting resource kinds to resource names
+func TestKindToResource(t *testing.T) {
+	for kind, expected := range map[string]string{
+		"Deployment":    "deployments",
+		"ConfigMap":     "configmaps",
+		"Ingress":       "ingresses",
+		"NetworkPolicy": "networkpolicies",
+		"Gateway":       "gateways",
+		"":              "",
+	} {
+		if resource := KindToResource(kind); resource != expected {
+			t.Errorf("Expected %s resource %s, got %s", kind, expected, resource)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -9,6 +10,7 @@ import (
 
 var K8sClient *kubernetes.Clientset
 var DynamicClient *dynamic.DynamicClient
+var DiscoveryClient discovery.DiscoveryInterface
 var clusterConfig *rest.Config
 var err error
 
@@ -50,3 +52,17 @@ func ConfigureClusterDynamicClient() (DynamicClient *dynamic.DynamicClient) {
 
 	return DynamicClient
 }
+
+// ConfigureClusterDiscoveryClient configures an in-cluster discovery client, used to find the served optional resources
+func ConfigureClusterDiscoveryClient() {
+	if clusterConfig == nil {
+		log.Printf("Failed to configure discovery Kubernetes client, cluster configuration is missing.\n")
+		return
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(clusterConfig)
+	if err != nil {
+		log.Printf("Failed to configure discovery Kubernetes client.\nError:\n%v\n", err)
+		return
+	}
+	DiscoveryClient = discoveryClient
+}
@@ -0,0 +1,287 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloudEvents content modes
+const (
+	CloudEventsModeStructured = "structured"
+	CloudEventsModeBinary     = "binary"
+	CloudEventsModeBatch      = "batch"
+)
+
+// CloudEvents type prefix and media types
+const (
+	cloudEventsSpecVersion    = "1.0"
+	cloudEventsTypePrefix     = "io.logz.k8s."
+	cloudEventsStructuredType = "application/cloudevents+json; charset=UTF-8"
+	cloudEventsBatchType      = "application/cloudevents-batch+json; charset=UTF-8"
+)
+
+// CloudEvent is an event log as a CloudEvent of the 1.0 specification, in the JSON event format
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// CloudEventsSink delivers event logs as CloudEvents over HTTP, in the structured, binary or batch content mode
+type CloudEventsSink struct {
+	name           string
+	client         *http.Client
+	endpoint       string
+	mode           string
+	cluster        string
+	headers        map[string]string
+	maxBatchSize   int
+	maxElapsedTime time.Duration
+	batchMux       sync.Mutex
+	batch          []CloudEvent
+	mux            sync.Mutex
+	lastErr        error
+}
+
+// init registers the CloudEvents sink type
+func init() {
+	RegisterSinkType(SinkTypeCloudEvents, NewCloudEventsSink)
+}
+
+// NewCloudEventsSink creates a CloudEvents HTTP sink
+func NewCloudEventsSink(config SinkConfig) (Sink, error) {
+	if config.CloudEvents == nil || config.CloudEvents.Endpoint == "" {
+		return nil, fmt.Errorf("no endpoint is configured for the CloudEvents sink")
+	}
+	sinkConfig := *config.CloudEvents
+	sink := &CloudEventsSink{
+		name:           config.Name,
+		endpoint:       sinkConfig.Endpoint,
+		mode:           sinkConfig.Mode,
+		cluster:        sinkConfig.Cluster,
+		headers:        sinkConfig.Headers,
+		maxBatchSize:   sinkConfig.MaxBatchSize,
+		maxElapsedTime: sinkConfig.MaxElapsedTime.Duration,
+	}
+	if sink.mode == "" {
+		sink.mode = CloudEventsModeStructured
+	}
+	if !slices.Contains([]string{CloudEventsModeStructured, CloudEventsModeBinary, CloudEventsModeBatch}, sink.mode) {
+		return nil, fmt.Errorf("unknown CloudEvents content mode: %s", sink.mode)
+	}
+	if sink.cluster == "" {
+		sink.cluster = os.Getenv("ENV_ID")
+	}
+	if sink.cluster == "" {
+		sink.cluster = DefaultCloudEventsCluster
+	}
+	if sink.maxBatchSize <= 0 {
+		sink.maxBatchSize = DefaultCloudEventsMaxBatchSize
+	}
+	if sink.maxElapsedTime <= 0 {
+		sink.maxElapsedTime = DefaultSinkMaxElapsedTime
+	}
+	timeout := sinkConfig.Timeout.Duration
+	if timeout <= 0 {
+		timeout = DefaultSinkRequestTimeout
+	}
+	sink.client = &http.Client{Timeout: timeout}
+	return sink, nil
+}
+
+// Send delivers an event log as a CloudEvent, or adds it to the batch in the batch mode
+func (sink *CloudEventsSink) Send(event SinkEvent) error {
+	cloudEvent := NewCloudEvent(event, sink.cluster, time.Now())
+	if sink.mode != CloudEventsModeBatch {
+		err := retryWithBackoff(sink.name, sink.maxElapsedTime, func() error {
+			return sink.post(cloudEvent)
+		})
+		if err != nil {
+			err = fmt.Errorf("dropped CloudEvent: %s: %w", cloudEvent.ID, err)
+		}
+		sink.setLastErr(err)
+		return err
+	}
+
+	sink.batchMux.Lock()
+	sink.batch = append(sink.batch, cloudEvent)
+	isFull := len(sink.batch) >= sink.maxBatchSize
+	sink.batchMux.Unlock()
+	if isFull {
+		return sink.Flush()
+	}
+	return nil
+}
+
+// Flush delivers the batched CloudEvents in the batch mode
+func (sink *CloudEventsSink) Flush() error {
+	sink.batchMux.Lock()
+	defer sink.batchMux.Unlock()
+	if len(sink.batch) == 0 {
+		return nil
+	}
+	batch := sink.batch
+	sink.batch = nil
+
+	err := retryWithBackoff(sink.name, sink.maxElapsedTime, func() error {
+		return sink.post(batch...)
+	})
+	if err != nil {
+		err = fmt.Errorf("dropped %d CloudEvents: %w", len(batch), err)
+	}
+	sink.setLastErr(err)
+	return err
+}
+
+// Close delivers the batched CloudEvents
+func (sink *CloudEventsSink) Close() error {
+	return sink.Flush()
+}
+
+// Health returns the error of the last request
+func (sink *CloudEventsSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// setLastErr records the error of the last request
+func (sink *CloudEventsSink) setLastErr(err error) {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.lastErr = err
+}
+
+// post sends CloudEvents in the content mode of the sink
+func (sink *CloudEventsSink) post(cloudEvents ...CloudEvent) error {
+	var body []byte
+	var err error
+	headers := http.Header{}
+	switch sink.mode {
+	case CloudEventsModeBatch:
+		body, err = json.Marshal(cloudEvents)
+		headers.Set("Content-Type", cloudEventsBatchType)
+	case CloudEventsModeBinary:
+		body, headers = cloudEventBinaryHeaders(cloudEvents[0])
+	default:
+		body, err = json.Marshal(cloudEvents[0])
+		headers.Set("Content-Type", cloudEventsStructuredType)
+	}
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, sink.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header = headers
+	for key, value := range sink.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return newNetworkSinkError(err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	return newHTTPSinkError(response, strings.TrimSpace(string(responseBody)))
+}
+
+// cloudEventBinaryHeaders returns the data of a CloudEvent as the request body of the binary mode,
+// with its attributes as ce- headers
+func cloudEventBinaryHeaders(cloudEvent CloudEvent) (body []byte, headers http.Header) {
+	headers = http.Header{}
+	headers.Set("Content-Type", cloudEvent.DataContentType)
+	attributes := map[string]string{
+		"ce-specversion": cloudEvent.SpecVersion,
+		"ce-id":          cloudEvent.ID,
+		"ce-source":      cloudEvent.Source,
+		"ce-type":        cloudEvent.Type,
+		"ce-subject":     cloudEvent.Subject,
+		"ce-time":        cloudEvent.Time,
+	}
+	for header, value := range attributes {
+		if value != "" {
+			headers.Set(header, encodeCloudEventsHeader(value))
+		}
+	}
+	return cloudEvent.Data, headers
+}
+
+// encodeCloudEventsHeader percent-encodes the spaces, double quotes, percent signs and non printable ASCII
+// characters of a header value, as in the CloudEvents HTTP protocol binding
+func encodeCloudEventsHeader(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if b <= ' ' || b > '~' || b == '"' || b == '%' {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		} else {
+			encoded.WriteByte(b)
+		}
+	}
+	return encoded.String()
+}
+
+// NewCloudEvent wraps an event log as a CloudEvent. The type is derived from the event type, the source is the cluster
+// and resource of the object, the subject is its namespace and name, and the id is its uid and resource version.
+func NewCloudEvent(event SinkEvent, cluster string, now time.Time) CloudEvent {
+	cloudEvent := CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Source:          "/clusters/" + cluster,
+		Time:            now.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Data:            event.JSON,
+	}
+
+	eventType := event.EventType()
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	switch {
+	case eventType == EventTypeHelmRelease:
+		cloudEvent.Type = cloudEventsTypePrefix + "helm.release"
+		cloudEvent.Source += "/helm/releases"
+	case eventType != "":
+		cloudEvent.Type = cloudEventsTypePrefix + "resource." + strings.ToLower(eventType)
+		cloudEvent.Source += cloudEventResourcePath(newObject)
+	default:
+		cloudEvent.Type = cloudEventsTypePrefix + "log"
+	}
+	if name := event.Name(); name != "" {
+		cloudEvent.Subject = name
+		if namespace := event.Namespace(); namespace != "" {
+			cloudEvent.Subject = namespace + "/" + name
+		}
+	}
+
+	cloudEvent.ID = event.ID()
+	return cloudEvent
+}
+
+// cloudEventResourcePath returns the API path of the resource of an object, for example /apis/apps/v1/deployments
+func cloudEventResourcePath(object map[string]interface{}) string {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return ""
+	}
+	if !strings.Contains(apiVersion, "/") {
+		return "/api/" + apiVersion + "/" + KindToResource(kind)
+	}
+	return "/apis/" + apiVersion + "/" + KindToResource(kind)
+}
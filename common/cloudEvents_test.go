@@ -0,0 +1,199 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receivedCloudEvents decodes the CloudEvents of the requests that a test receiver accepted, by their content type
+func receivedCloudEvents(requests []testHTTPRequest) (cloudEvents []CloudEvent) {
+	for _, request := range requests {
+		if request.queued {
+			continue
+		}
+		contentType := request.headers.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "application/cloudevents-batch+json"):
+			var batch []CloudEvent
+			json.Unmarshal(request.body, &batch)
+			cloudEvents = append(cloudEvents, batch...)
+		case strings.HasPrefix(contentType, "application/cloudevents+json"):
+			var cloudEvent CloudEvent
+			json.Unmarshal(request.body, &cloudEvent)
+			cloudEvents = append(cloudEvents, cloudEvent)
+		default:
+			header := func(name string) string {
+				value, _ := url.PathUnescape(request.headers.Get(name))
+				return value
+			}
+			cloudEvents = append(cloudEvents, CloudEvent{
+				SpecVersion:     header("ce-specversion"),
+				ID:              header("ce-id"),
+				Source:          header("ce-source"),
+				Type:            header("ce-type"),
+				Subject:         header("ce-subject"),
+				Time:            header("ce-time"),
+				DataContentType: contentType,
+				Data:            request.body,
+			})
+		}
+	}
+	return cloudEvents
+}
+
+// Objects of the CloudEvents test event logs, of the apps and core API groups
+var (
+	cloudEventsAppsObject = map[string]interface{}{"newObject": map[string]interface{}{"apiVersion": "apps/v1", Metadata: map[string]interface{}{ResourceVersion: "42"}}}
+	cloudEventsCoreObject = testObjectVersion("42")
+)
+
+// TestNewCloudEvent tests wrapping event logs as CloudEvents
+func TestNewCloudEvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	helmFields := map[string]interface{}{
+		"message":     "[EVENT] Helm release: web in namespace: default was upgraded.",
+		"eventType":   EventTypeHelmRelease,
+		"helmRelease": map[string]interface{}{"name": "web", "namespace": "default", "revision": float64(3)},
+	}
+	tests := []struct {
+		name     string
+		event    SinkEvent
+		expected CloudEvent
+	}{
+		{
+			name:     "modified deployment",
+			event:    getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", cloudEventsAppsObject),
+			expected: CloudEvent{ID: "web-uid-42", Source: "/clusters/prod/apis/apps/v1/deployments", Type: "io.logz.k8s.resource.modified", Subject: "default/web"},
+		},
+		{
+			name:     "deleted core resource",
+			event:    getTestSinkEvent(EventTypeDeleted, "ConfigMap", "settings", "default", cloudEventsCoreObject),
+			expected: CloudEvent{ID: "settings-uid-42", Source: "/clusters/prod/api/v1/configmaps", Type: "io.logz.k8s.resource.deleted", Subject: "default/settings"},
+		},
+		{
+			name:     "helm release",
+			event:    SinkEvent{Fields: helmFields},
+			expected: CloudEvent{Source: "/clusters/prod/helm/releases", Type: "io.logz.k8s.helm.release", Subject: "default/web"},
+		},
+		{
+			name:     "collector log",
+			event:    SinkEvent{Fields: map[string]interface{}{"message": "Collector started"}},
+			expected: CloudEvent{Source: "/clusters/prod", Type: "io.logz.k8s.log"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cloudEvent := NewCloudEvent(test.event, "prod", now)
+			if cloudEvent.SpecVersion != "1.0" || cloudEvent.Time != "2024-05-01T12:00:00Z" || cloudEvent.DataContentType != "application/json" || cloudEvent.ID == "" {
+				t.Errorf("Unexpected CloudEvent attributes: %+v", cloudEvent)
+			}
+			if test.expected.ID != "" && cloudEvent.ID != test.expected.ID {
+				t.Errorf("Expected id %s, got %s", test.expected.ID, cloudEvent.ID)
+			}
+			if cloudEvent.Source != test.expected.Source || cloudEvent.Type != test.expected.Type || cloudEvent.Subject != test.expected.Subject {
+				t.Errorf("Expected %+v, got %+v", test.expected, cloudEvent)
+			}
+		})
+	}
+
+	// Events derived from the same object version have distinct ids
+	statusChanged := getTestSinkEvent(EventTypeStatusChanged, "Deployment", "web", "default", cloudEventsAppsObject)
+	otherStatusChanged := getTestSinkEvent(EventTypeStatusChanged, "Deployment", "web", "default", cloudEventsAppsObject)
+	otherStatusChanged.Fields["message"] = "[EVENT] Status of resource: web changed: Available to False."
+	id, otherID := NewCloudEvent(statusChanged, "prod", now).ID, NewCloudEvent(otherStatusChanged, "prod", now).ID
+	if !strings.HasPrefix(id, "web-uid-42-") || id == otherID || id != NewCloudEvent(statusChanged, "prod", now).ID {
+		t.Errorf("Expected stable distinct ids of derived events, got %s and %s", id, otherID)
+	}
+}
+
+// TestEncodeCloudEventsHeader tests percent-encoding header values of the binary mode
+func TestEncodeCloudEventsHeader(t *testing.T) {
+	if encoded := encodeCloudEventsHeader(`default/web 100% "ready" ü`); encoded != `default/web%20100%25%20%22ready%22%20%C3%BC` {
+		t.Errorf("Unexpected encoded header: %s", encoded)
+	}
+}
+
+// TestCloudEventsSink tests delivering CloudEvents in the structured, binary and batch content modes
+func TestCloudEventsSink(t *testing.T) {
+	withSinkBackoff(t)
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		mode             string
+		expectedRequests int
+	}{
+		{mode: CloudEventsModeStructured, expectedRequests: 4},
+		{mode: CloudEventsModeBinary, expectedRequests: 4},
+		{mode: CloudEventsModeBatch, expectedRequests: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			receiver := newTestHTTPReceiver(t, nil, http.StatusServiceUnavailable)
+			sink, err := NewCloudEventsSink(SinkConfig{Name: "cloudevents-test", CloudEvents: &CloudEventsSinkConfig{
+				Endpoint:     strings.Replace(receiver.server.URL, "://", "://user:secret-token@", 1),
+				Mode:         test.mode,
+				Cluster:      "prod",
+				MaxBatchSize: 2,
+			}})
+			if err != nil {
+				t.Fatalf("Failed to create CloudEvents sink: %v", err)
+			}
+
+			events := []SinkEvent{
+				getTestSinkEvent(EventTypeAdded, "Deployment", "web", "default", cloudEventsAppsObject),
+				getTestSinkEvent(EventTypeModified, "ConfigMap", "web config", "default", cloudEventsCoreObject),
+				getTestSinkEvent(EventTypeDeleted, "Pod", "web-1", "default", cloudEventsCoreObject),
+			}
+			for _, event := range events {
+				if err = sink.Send(event); err != nil {
+					t.Fatalf("Failed to send event log: %v", err)
+				}
+			}
+			if err = sink.Close(); err != nil {
+				t.Fatalf("Failed to close CloudEvents sink: %v", err)
+			}
+
+			requests := receiver.received()
+			cloudEvents := receivedCloudEvents(requests)
+			if len(requests) != test.expectedRequests || len(cloudEvents) != len(events) {
+				t.Fatalf("Expected %d CloudEvents in %d requests, got %d in %d", len(events), test.expectedRequests, len(cloudEvents), len(requests))
+			}
+			for eventIndex, cloudEvent := range cloudEvents {
+				expected := NewCloudEvent(events[eventIndex], "prod", time.Now())
+				if cloudEvent.ID != expected.ID || cloudEvent.Source != expected.Source || cloudEvent.Type != expected.Type || cloudEvent.Subject != expected.Subject || cloudEvent.SpecVersion != "1.0" {
+					t.Errorf("Expected %+v, got %+v", expected, cloudEvent)
+				}
+				var data map[string]interface{}
+				if err = json.Unmarshal(cloudEvent.Data, &data); err != nil || data["eventType"] != events[eventIndex].EventType() {
+					t.Errorf("Expected the event log as data, got %s", cloudEvent.Data)
+				}
+			}
+		})
+	}
+
+	// Retries are logged with the name of the sink, without its endpoint and credentials
+	if !strings.Contains(output.String(), "sink: cloudevents-test") || strings.Contains(output.String(), "secret-token") {
+		t.Errorf("Expected the retries to be logged with the sink name only, got:\n%s", output.String())
+	}
+
+	receiver := newTestHTTPReceiver(t, nil, http.StatusBadRequest)
+	sink, _ := NewCloudEventsSink(SinkConfig{CloudEvents: &CloudEventsSinkConfig{Endpoint: receiver.server.URL}})
+	if err := sink.Send(getTestSinkEvent(EventTypeAdded, "Deployment", "web", "default", cloudEventsAppsObject)); err == nil || sink.Health() == nil {
+		t.Error("Expected an error of a rejected CloudEvent")
+	}
+	if _, err := NewCloudEventsSink(SinkConfig{CloudEvents: &CloudEventsSinkConfig{Endpoint: receiver.server.URL, Mode: "stream"}}); err == nil {
+		t.Error("Expected an error of an unknown content mode")
+	}
+	if _, err := NewCloudEventsSink(SinkConfig{}); err == nil {
+		t.Error("Expected an error without an endpoint")
+	}
+}
@@ -0,0 +1,385 @@
+package common
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+// Configuration holds the optional collector configuration, loaded from the file set in CONFIG_PATH
+type Configuration struct {
+	// IgnoreRules are additional rules for updates that should not be reported
+	IgnoreRules []IgnoreRule `json:"ignoreRules,omitempty"`
+	// IgnoreRulesReportInterval is how often the number of events suppressed by each ignore rule is reported
+	IgnoreRulesReportInterval metav1.Duration `json:"ignoreRulesReportInterval,omitempty"`
+	// StatusTransitions are the resource kinds that report status condition transitions
+	StatusTransitions []StatusTransitionRule `json:"statusTransitions,omitempty"`
+	// AuditWebhook enables the audit webhook receiver that attributes changes to the requesting users
+	AuditWebhook *AuditWebhookConfig `json:"auditWebhook,omitempty"`
+	// AdmissionWebhook enables the always allow validating admission webhook
+	AdmissionWebhook *AdmissionWebhookConfig `json:"admissionWebhook,omitempty"`
+	// GitOps configures the lookup of the Argo CD and Flux objects that applied a resource
+	GitOps GitOpsConfig `json:"gitops,omitempty"`
+	// DeployMarkers are annotation and label key patterns such as "ci.example.com/*", lifted into the deployMarkers field of events
+	DeployMarkers []string `json:"deployMarkers,omitempty"`
+	// AppLabels are custom label keys such as "example.com/team", added to the app field of events with the standard recommended labels
+	AppLabels []string `json:"appLabels,omitempty"`
+	// Metrics enables the Prometheus metrics endpoint
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	// Health enables the liveness and readiness probe endpoints
+	Health *HealthConfig `json:"health,omitempty"`
+	// Sinks are the destinations of the event logs, event logs are sent to Logz.io if no sinks are configured
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+}
+
+// IgnoreRule describes fields, annotations and labels to ignore when comparing updated resources
+type IgnoreRule struct {
+	// Name identifies the rule in the suppression report
+	Name string `json:"name,omitempty"`
+	// Kinds limits the rule to the given resource kinds, an empty list or "*" applies to all kinds
+	Kinds []string `json:"kinds,omitempty"`
+	// Paths are field paths such as "spec.template.metadata.annotations['kubectl.kubernetes.io/restartedAt']"
+	Paths []string `json:"paths,omitempty"`
+	// Annotations are annotation key patterns such as "cert-manager.io/*"
+	Annotations []string `json:"annotations,omitempty"`
+	// Labels are label key patterns such as "argocd.argoproj.io/*"
+	Labels []string `json:"labels,omitempty"`
+}
+
+// MetricsConfig configures the HTTP endpoint of the Prometheus metrics
+type MetricsConfig struct {
+	// Address is the listen address of the endpoint, defaults to ":9090"
+	Address string `json:"address,omitempty"`
+	// Path is the HTTP path of the endpoint, defaults to "/metrics"
+	Path string `json:"path,omitempty"`
+}
+
+// HealthConfig configures the HTTP endpoints of the liveness and readiness probes
+type HealthConfig struct {
+	// Address is the listen address of the /healthz and /readyz endpoints, defaults to ":8080"
+	Address string `json:"address,omitempty"`
+	// SinkFailureThreshold is how long a sink fails before the collector isn't ready, defaults to 5 minutes
+	SinkFailureThreshold metav1.Duration `json:"sinkFailureThreshold,omitempty"`
+	// WatchStallThreshold is how long the watch loop of an informer makes no request before the collector isn't live, defaults to 15 minutes
+	WatchStallThreshold metav1.Duration `json:"watchStallThreshold,omitempty"`
+}
+
+// StatusTransitionRule enables STATUS_CHANGED events for a resource kind
+type StatusTransitionRule struct {
+	// Kind is the resource kind, for example "Deployment"
+	Kind string `json:"kind"`
+	// ConditionTypes limits the reported transitions to the given condition types, an empty list reports all conditions
+	ConditionTypes []string `json:"conditionTypes,omitempty"`
+}
+
+// AuditWebhookConfig configures the HTTPS endpoint of the API server audit webhook backend
+type AuditWebhookConfig struct {
+	// Address is the listen address of the endpoint, defaults to ":8443"
+	Address string `json:"address,omitempty"`
+	// Path is the HTTP path of the endpoint, defaults to "/audit"
+	Path string `json:"path,omitempty"`
+	// TLSCertFile and TLSKeyFile are the serving certificate and key of the endpoint
+	TLSCertFile string `json:"tlsCertFile"`
+	TLSKeyFile  string `json:"tlsKeyFile"`
+	// ClientCAFile optionally requires API server client certificates signed by the given CA
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	// BufferWindow is how long unmatched audit records are kept, defaults to 30 seconds
+	BufferWindow metav1.Duration `json:"bufferWindow,omitempty"`
+	// MatchWait is how long an event waits for its audit record, defaults to 5 seconds. Events without a matching
+	// record wait the full duration before they are sent.
+	MatchWait metav1.Duration `json:"matchWait,omitempty"`
+}
+
+// AdmissionWebhookConfig configures the validating admission webhook, that sees change attempts before they are applied
+type AdmissionWebhookConfig struct {
+	// Address is the listen address of the webhook, defaults to ":9443"
+	Address string `json:"address,omitempty"`
+	// Path is the HTTP path of the webhook, defaults to "/validate"
+	Path string `json:"path,omitempty"`
+	// TLSCertFile and TLSKeyFile are the serving certificate and key, a self-signed certificate is generated if not set
+	TLSCertFile string `json:"tlsCertFile,omitempty"`
+	TLSKeyFile  string `json:"tlsKeyFile,omitempty"`
+	// CABundleFile is the CA of the serving certificate files, used to register the webhook
+	CABundleFile string `json:"caBundleFile,omitempty"`
+	// Register creates or updates the ValidatingWebhookConfiguration of the webhook on startup
+	Register bool `json:"register,omitempty"`
+	// WebhookName is the name of the ValidatingWebhookConfiguration and its webhook, defaults to "k8s-events.logz.io"
+	WebhookName string `json:"webhookName,omitempty"`
+	// ServiceName, ServiceNamespace and ServicePort are the service of the collector, used to register the webhook
+	ServiceName      string `json:"serviceName,omitempty"`
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
+	ServicePort      int32  `json:"servicePort,omitempty"`
+	// TimeoutSeconds is the registered webhook timeout, defaults to 5 seconds
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// BufferWindow is how long an admitted change waits for its informer event before it is reported as rejected
+	BufferWindow metav1.Duration `json:"bufferWindow,omitempty"`
+	// MatchWait is how long an event waits for its request identity, defaults to 5 seconds. Events without a matching
+	// request wait the full duration before they are sent.
+	MatchWait metav1.Duration `json:"matchWait,omitempty"`
+	// ReportDryRun sends DRY_RUN events for dry run requests
+	ReportDryRun bool `json:"reportDryRun,omitempty"`
+}
+
+// GitOpsConfig configures the GitOps metadata of events
+type GitOpsConfig struct {
+	// Disabled turns off the GitOps metadata lookups
+	Disabled bool `json:"disabled,omitempty"`
+	// ArgoCDNamespace is the namespace of the Argo CD Applications, defaults to "argocd"
+	ArgoCDNamespace string `json:"argoCDNamespace,omitempty"`
+	// ArgoCDInstanceLabel is the Argo CD application instance label key, defaults to "app.kubernetes.io/instance"
+	ArgoCDInstanceLabel string `json:"argoCDInstanceLabel,omitempty"`
+	// CacheTTL is how long looked up GitOps objects are cached, defaults to 30 seconds
+	CacheTTL metav1.Duration `json:"cacheTTL,omitempty"`
+	// CacheSize is the maximal number of cached GitOps objects, the least recently used objects are evicted first.
+	// Defaults to 1000.
+	CacheSize int `json:"cacheSize,omitempty"`
+}
+
+// SinkConfig configures a destination of the event logs
+type SinkConfig struct {
+	// Name identifies the sink in logs and health reports, defaults to the type and index of the sink
+	Name string `json:"name,omitempty"`
+	// Type is the registered sink type, for example "logzio"
+	Type string `json:"type"`
+	// Filter limits the event logs sent to the sink
+	Filter SinkFilter `json:"filter,omitempty"`
+	// QueueSize is the number of event logs queued for the sink before new event logs are dropped, defaults to 1000
+	QueueSize int `json:"queueSize,omitempty"`
+	// Logzio configures a sink of type "logzio"
+	Logzio *LogzioSinkConfig `json:"logzio,omitempty"`
+	// OTLP configures a sink of type "otlp"
+	OTLP *OTLPSinkConfig `json:"otlp,omitempty"`
+	// CloudEvents configures a sink of type "cloudevents"
+	CloudEvents *CloudEventsSinkConfig `json:"cloudEvents,omitempty"`
+	// Webhook configures a sink of type "webhook"
+	Webhook *WebhookSinkConfig `json:"webhook,omitempty"`
+	// File configures a sink of type "file"
+	File *FileSinkConfig `json:"file,omitempty"`
+	// Syslog configures a sink of type "syslog"
+	Syslog *SyslogSinkConfig `json:"syslog,omitempty"`
+	// Elasticsearch configures a sink of type "elasticsearch"
+	Elasticsearch *ElasticsearchSinkConfig `json:"elasticsearch,omitempty"`
+}
+
+// SinkFilter limits the event logs sent to a sink, empty lists match all event logs
+type SinkFilter struct {
+	// EventTypes are the event types to send, for example "MODIFIED" or "HELM_RELEASE"
+	EventTypes []string `json:"eventTypes,omitempty"`
+	// Kinds are the resource kinds to send
+	Kinds []string `json:"kinds,omitempty"`
+	// Namespaces are the resource namespaces to send
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// LogzioSinkConfig configures a Logz.io sink
+type LogzioSinkConfig struct {
+	// Token is the shipping token, defaults to the LOGZIO_TOKEN environment variable
+	Token string `json:"token,omitempty"`
+	// Listener is the listener URL, defaults to the LOGZIO_LISTENER environment variable or the us-east-1 listener
+	Listener string `json:"listener,omitempty"`
+}
+
+// OTLPSinkConfig configures an OpenTelemetry OTLP/HTTP logs exporter
+type OTLPSinkConfig struct {
+	// Endpoint is the URL of the OTLP/HTTP logs endpoint, defaults to "http://localhost:4318/v1/logs"
+	Endpoint string `json:"endpoint,omitempty"`
+	// Encoding is the request encoding, "protobuf" or "json", defaults to "protobuf"
+	Encoding string `json:"encoding,omitempty"`
+	// Compression is the request compression, "gzip" or "none", defaults to "none"
+	Compression string `json:"compression,omitempty"`
+	// Headers are sent with each export request, for example authorization headers
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is the timeout of each export request, defaults to 10 seconds
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// MaxBatchSize is the maximal number of log records in an export request, defaults to 512
+	MaxBatchSize int `json:"maxBatchSize,omitempty"`
+	// MaxElapsedTime is how long a failed export request is retried before its log records are dropped, defaults to 1 minute
+	MaxElapsedTime metav1.Duration `json:"maxElapsedTime,omitempty"`
+}
+
+// CloudEventsSinkConfig configures a sink that delivers event logs as CloudEvents over HTTP
+type CloudEventsSinkConfig struct {
+	// Endpoint is the URL the CloudEvents are posted to
+	Endpoint string `json:"endpoint"`
+	// Mode is the content mode, "structured", "binary" or "batch", defaults to "structured"
+	Mode string `json:"mode,omitempty"`
+	// Cluster is the cluster name in the CloudEvents sources, defaults to the ENV_ID environment variable or "kubernetes"
+	Cluster string `json:"cluster,omitempty"`
+	// Headers are sent with each request, for example authorization headers
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is the timeout of each request, defaults to 10 seconds
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// MaxBatchSize is the maximal number of CloudEvents in a request of the batch mode, defaults to 100
+	MaxBatchSize int `json:"maxBatchSize,omitempty"`
+	// MaxElapsedTime is how long a failed request is retried before its CloudEvents are dropped, defaults to 1 minute
+	MaxElapsedTime metav1.Duration `json:"maxElapsedTime,omitempty"`
+}
+
+// WebhookSinkConfig configures a sink that posts event logs to webhook routes, such as chat and ticketing tools
+type WebhookSinkConfig struct {
+	// Routes are the webhooks the event logs are posted to, each event log is posted to every route whose filter it passes
+	Routes []WebhookRouteConfig `json:"routes"`
+	// Timeout is the timeout of each request, defaults to 10 seconds
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// MaxElapsedTime is how long a failed request is retried before its event log is dropped, defaults to 1 minute
+	MaxElapsedTime metav1.Duration `json:"maxElapsedTime,omitempty"`
+}
+
+// WebhookRouteConfig configures a webhook route
+type WebhookRouteConfig struct {
+	// Name identifies the route in logs, defaults to the index of the route
+	Name string `json:"name,omitempty"`
+	// URL is the webhook URL
+	URL string `json:"url"`
+	// Method is the request method, defaults to "POST"
+	Method string `json:"method,omitempty"`
+	// Filter limits the event logs posted to the route
+	Filter SinkFilter `json:"filter,omitempty"`
+	// Template is the Go text/template of the request body, defaults to a JSON object of the event message as "text"
+	Template string `json:"template,omitempty"`
+	// ContentType is the content type of the request body, defaults to "application/json"
+	ContentType string `json:"contentType,omitempty"`
+	// Headers are sent with each request, environment variables in their values are expanded
+	Headers map[string]string `json:"headers,omitempty"`
+	// BasicAuth sets the basic authentication credentials of the requests
+	BasicAuth *WebhookBasicAuth `json:"basicAuth,omitempty"`
+	// BearerToken sets the bearer token of the requests, environment variables in it are expanded
+	BearerToken string `json:"bearerToken,omitempty"`
+	// RateLimit limits the event logs posted to the route, event logs over the limit are dropped
+	RateLimit *WebhookRateLimit `json:"rateLimit,omitempty"`
+}
+
+// WebhookBasicAuth are the basic authentication credentials of a webhook route, environment variables in them are expanded
+type WebhookBasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// WebhookRateLimit allows bursts of up to Events event logs, refilled at Events per Interval
+type WebhookRateLimit struct {
+	Events   int             `json:"events"`
+	Interval metav1.Duration `json:"interval"`
+}
+
+// FileSinkConfig configures a sink that writes NDJSON event logs to rotating local files
+type FileSinkConfig struct {
+	// Directory is the directory of the files, for example a mounted volume
+	Directory string `json:"directory"`
+	// BaseName is the name of the files, before their timestamp and extension, defaults to "k8s-events"
+	BaseName string `json:"baseName,omitempty"`
+	// MaxFileBytes is the size at which the file is rotated, defaults to 100 MiB
+	MaxFileBytes int64 `json:"maxFileBytes,omitempty"`
+	// RotateInterval is the age at which the file is rotated, defaults to 24 hours
+	RotateInterval metav1.Duration `json:"rotateInterval,omitempty"`
+	// Compress turns on gzip compression of the rotated files
+	Compress bool `json:"compress,omitempty"`
+	// MaxFiles is the number of rotated files retained, 0 retains all files
+	MaxFiles int `json:"maxFiles,omitempty"`
+	// MaxTotalBytes is the total size of the rotated files retained, 0 retains all files
+	MaxTotalBytes int64 `json:"maxTotalBytes,omitempty"`
+	// SyncInterval is how often the written event logs are synced to disk, defaults to 5 seconds
+	SyncInterval metav1.Duration `json:"syncInterval,omitempty"`
+}
+
+// SyslogSinkConfig configures a sink that sends event logs as RFC 5424 syslog messages
+type SyslogSinkConfig struct {
+	// Network is "udp", "tcp" or "tls", defaults to "udp"
+	Network string `json:"network,omitempty"`
+	// Address is the host and port of the syslog server
+	Address string `json:"address"`
+	// Facility is the facility name of the messages, for example "local0" or "auth", defaults to "local0"
+	Facility string `json:"facility,omitempty"`
+	// AppName is the app name of the messages, defaults to "logzio-k8s-events"
+	AppName string `json:"appName,omitempty"`
+	// Hostname is the hostname of the messages, defaults to the hostname of the collector
+	Hostname string `json:"hostname,omitempty"`
+	// StructuredDataID is the id of the structured data element of the event resource, defaults to "k8s@32473"
+	StructuredDataID string `json:"structuredDataID,omitempty"`
+	// Body is the message body, "message" for the event message or "json" for the JSON event log, defaults to "message"
+	Body string `json:"body,omitempty"`
+	// Severities map event types and change categories to severity names, overriding the default severities
+	Severities map[string]string `json:"severities,omitempty"`
+	// Timeout is the timeout of connecting and writing, defaults to 10 seconds
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// TLS configures the "tls" network
+	TLS *SyslogTLSConfig `json:"tls,omitempty"`
+}
+
+// SyslogTLSConfig configures the TLS connection to a syslog server
+type SyslogTLSConfig struct {
+	// CAFile is a PEM file of the CA certificates that verify the server, defaults to the system CAs
+	CAFile string `json:"caFile,omitempty"`
+	// ServerName is the name verified in the server certificate, defaults to the host of the address
+	ServerName string `json:"serverName,omitempty"`
+	// CertFile and KeyFile are the client certificate and key, for servers that authenticate clients
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// ElasticsearchSinkConfig configures a sink that indexes event logs in Elasticsearch or OpenSearch with bulk requests
+type ElasticsearchSinkConfig struct {
+	// Endpoint is the URL of the cluster, for example "https://elasticsearch:9200"
+	Endpoint string `json:"endpoint"`
+	// Index is the index pattern, with {date}, {kind}, {namespace} and {eventType} placeholders, defaults to "k8s-events-{date}"
+	Index string `json:"index,omitempty"`
+	// DateFormat is the Go time layout of the {date} placeholder, defaults to "2006.01.02"
+	DateFormat string `json:"dateFormat,omitempty"`
+	// Username and Password authenticate with basic authentication, environment variables are expanded
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// APIKey is the encoded API key of the cluster, environment variables are expanded
+	APIKey string `json:"apiKey,omitempty"`
+	// Headers are sent with each request, environment variables are expanded
+	Headers map[string]string `json:"headers,omitempty"`
+	// IndexTemplate installs an index template of the event logs in the indices of the index pattern
+	IndexTemplate *ElasticsearchIndexTemplateConfig `json:"indexTemplate,omitempty"`
+	// Timeout is the timeout of each request, defaults to 10 seconds
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// MaxBatchSize is the maximal number of documents in a bulk request, defaults to 500
+	MaxBatchSize int `json:"maxBatchSize,omitempty"`
+	// MaxElapsedTime is how long failed and throttled documents are retried before they are dropped, defaults to 1 minute
+	MaxElapsedTime metav1.Duration `json:"maxElapsedTime,omitempty"`
+}
+
+// ElasticsearchIndexTemplateConfig configures the index template of the Elasticsearch sink
+type ElasticsearchIndexTemplateConfig struct {
+	// Name is the name of the index template, defaults to "k8s-events"
+	Name string `json:"name,omitempty"`
+	// Priority is the priority of the index template over other templates matching the indices
+	Priority int `json:"priority,omitempty"`
+	// Shards is the number of primary shards of the indices, defaults to the cluster default
+	Shards int `json:"shards,omitempty"`
+	// Replicas is the number of replicas of the indices, defaults to the cluster default
+	Replicas *int `json:"replicas,omitempty"`
+}
+
+var Config Configuration
+
+// LoadConfig loads the collector configuration file set in the CONFIG_PATH environment variable
+func LoadConfig() {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		// The configuration file is optional
+		return
+	}
+
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		// If the configuration file can't be read, log the error and exit
+		log.Fatalf("\n[FATAL] Failed to read configuration file: %s.\nERROR: %v\n", configPath, err)
+	}
+
+	Config, err = ParseConfig(configData)
+	if err != nil {
+		// If the configuration file is invalid, log the error and exit
+		log.Fatalf("\n[FATAL] Failed to parse configuration file: %s.\nERROR: %v\n", configPath, err)
+	}
+	log.Printf("Loaded configuration file: %s", configPath)
+}
+
+// ParseConfig parses a YAML or JSON collector configuration
+func ParseConfig(configData []byte) (config Configuration, err error) {
+	err = yaml.UnmarshalStrict(configData, &config)
+	return config, err
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseConfig tests parsing of a YAML collector configuration
+func TestParseConfig(t *testing.T) {
+	configData := []byte(`
+ignoreRulesReportInterval: 5m
+ignoreRules:
+  - name: restarts
+    kinds: ["Deployment", "StatefulSet"]
+    paths:
+      - "spec.template.metadata.annotations['kubectl.kubernetes.io/restartedAt']"
+  - annotations: ["cert-manager.io/*"]
+    labels: ["argocd.argoproj.io/*"]
+deployMarkers: ["ci.example.com/*"]
+appLabels: ["example.com/team"]
+`)
+	config, err := ParseConfig(configData)
+	if err != nil {
+		t.Fatalf("Failed to parse configuration: %v", err)
+	}
+
+	if len(config.IgnoreRules) != 2 {
+		t.Fatalf("Expected 2 ignore rules, got %d", len(config.IgnoreRules))
+	}
+	if config.IgnoreRules[0].Name != "restarts" || len(config.IgnoreRules[0].Kinds) != 2 || len(config.IgnoreRules[0].Paths) != 1 {
+		t.Errorf("Unexpected first ignore rule: %+v", config.IgnoreRules[0])
+	}
+	if config.IgnoreRules[1].Annotations[0] != "cert-manager.io/*" || config.IgnoreRules[1].Labels[0] != "argocd.argoproj.io/*" {
+		t.Errorf("Unexpected second ignore rule: %+v", config.IgnoreRules[1])
+	}
+	if len(config.DeployMarkers) != 1 || config.DeployMarkers[0] != "ci.example.com/*" {
+		t.Errorf("Unexpected deploy markers: %v", config.DeployMarkers)
+	}
+	if len(config.AppLabels) != 1 || config.AppLabels[0] != "example.com/team" {
+		t.Errorf("Unexpected app labels: %v", config.AppLabels)
+	}
+	if config.IgnoreRulesReportInterval.Duration != 5*time.Minute {
+		t.Errorf("Expected report interval of 5m, got %v", config.IgnoreRulesReportInterval.Duration)
+	}
+}
+
+// TestParseConfigUnknownField tests that unknown configuration fields are rejected
+func TestParseConfigUnknownField(t *testing.T) {
+	_, err := ParseConfig([]byte("ignoreRule: []"))
+	if err == nil {
+		t.Errorf("Expected an error for an unknown configuration field")
+	}
+}
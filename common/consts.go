@@ -1,20 +1,119 @@
 package common
 
+import "time"
+
 const (
 	EventTypeDeleted  = "DELETED"
 	EventTypeModified = "MODIFIED"
 	EventTypeAdded    = "ADDED"
+	// EventTypeStatusChanged is sent for status condition transitions of the kinds set in the statusTransitions configuration
+	EventTypeStatusChanged = "STATUS_CHANGED"
+	// EventTypeRejected is sent for admitted changes that were rejected after the admission webhook
+	EventTypeRejected = "REJECTED"
+	// EventTypeDryRun is sent for dry run changes seen by the admission webhook
+	EventTypeDryRun = "DRY_RUN"
+	// EventTypeHelmRelease is sent for completed Helm release install, upgrade, rollback and uninstall actions
+	EventTypeHelmRelease = "HELM_RELEASE"
+	// EventTypeGitOpsTransition is sent for sync and health transitions of Argo CD and Flux objects
+	EventTypeGitOpsTransition = "GITOPS_TRANSITION"
+	// EventTypeProgressiveDelivery is sent for step, weight, analysis, promotion and abort transitions of Argo Rollouts and Flagger canaries
+	EventTypeProgressiveDelivery = "PROGRESSIVE_DELIVERY"
+)
+
+// Sink types of the sinks configuration
+const (
+	SinkTypeLogzio        = "logzio"
+	SinkTypeOTLP          = "otlp"
+	SinkTypeCloudEvents   = "cloudevents"
+	SinkTypeWebhook       = "webhook"
+	SinkTypeFile          = "file"
+	SinkTypeSyslog        = "syslog"
+	SinkTypeElasticsearch = "elasticsearch"
 )
 
 const (
-	DefaultListener = "https://listener.logz.io:8071"
-	DefaultLogType  = "logzio-k8s-events"
+	DefaultListener                  = "https://listener.logz.io:8071"
+	DefaultLogType                   = "logzio-k8s-events"
+	DefaultIgnoreRulesReportInterval = 10 * time.Minute
+	DefaultAuditWebhookAddress       = ":8443"
+	DefaultAuditWebhookPath          = "/audit"
+	DefaultAdmissionWebhookAddress   = ":9443"
+	DefaultAdmissionWebhookPath      = "/validate"
+	DefaultMetricsAddress            = ":9090"
+	DefaultMetricsPath               = "/metrics"
+	DefaultHealthAddress             = ":8080"
+	LivenessPath                     = "/healthz"
+	ReadinessPath                    = "/readyz"
+	DefaultAdmissionWebhookName      = "k8s-events.logz.io"
+	DefaultAdmissionWebhookTimeout   = 5
+	DefaultArgoCDNamespace           = "argocd"
+	DefaultArgoCDInstanceLabel       = "app.kubernetes.io/instance"
+	DefaultGitOpsCacheTTL            = 30 * time.Second
+	// DefaultGitOpsCacheSize is the maximal number of cached GitOps objects
+	DefaultGitOpsCacheSize = 1000
+	// DefaultSinkFailureThreshold is how long a sink fails before the collector isn't ready
+	DefaultSinkFailureThreshold = 5 * time.Minute
+	// DefaultWatchStallThreshold is how long the watch loop of an informer makes no request before the collector isn't live,
+	// watches are reopened every 5 to 10 minutes
+	DefaultWatchStallThreshold = 15 * time.Minute
+	// DefaultSinkQueueSize is the number of event logs queued for each sink before new event logs are dropped
+	DefaultSinkQueueSize = 1000
+	// DefaultSinkCloseTimeout is how long the sinks are given to send their queued event logs on shutdown
+	DefaultSinkCloseTimeout = 10 * time.Second
+	// DefaultOTLPEndpoint is the OTLP/HTTP logs endpoint of a local OpenTelemetry Collector
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/logs"
+	// DefaultOTLPMaxBatchSize is the maximal number of log records in an OTLP export request
+	DefaultOTLPMaxBatchSize = 512
+	// DefaultCloudEventsMaxBatchSize is the maximal number of CloudEvents in a request of the batch mode
+	DefaultCloudEventsMaxBatchSize = 100
+	// DefaultCloudEventsCluster is the cluster name in the CloudEvents sources, if ENV_ID isn't set
+	DefaultCloudEventsCluster = "kubernetes"
+	// DefaultWebhookTemplate is the request body template of webhook routes, compatible with Slack and Teams incoming webhooks
+	DefaultWebhookTemplate = `{"text": {{ json .Message }}}`
+	// DefaultFileSinkBaseName is the name of the files of the file sink, before their timestamp and extension
+	DefaultFileSinkBaseName = "k8s-events"
+	// DefaultFileSinkMaxFileBytes is the size at which the file of the file sink is rotated
+	DefaultFileSinkMaxFileBytes = 100 << 20
+	// DefaultFileSinkRotateInterval is the age at which the file of the file sink is rotated
+	DefaultFileSinkRotateInterval = 24 * time.Hour
+	// DefaultFileSinkSyncInterval is how often the file sink syncs the written event logs to disk
+	DefaultFileSinkSyncInterval = 5 * time.Second
+	// DefaultSyslogFacility is the facility of the syslog messages
+	DefaultSyslogFacility = "local0"
+	// DefaultSyslogStructuredDataID is the id of the structured data element of the event resource, in the documentation enterprise number
+	DefaultSyslogStructuredDataID = "k8s@32473"
+	// DefaultElasticsearchIndex is the index pattern of the Elasticsearch sink, with an index per day
+	DefaultElasticsearchIndex = "k8s-events-{date}"
+	// DefaultElasticsearchDateFormat is the Go time layout of the {date} placeholder of index patterns
+	DefaultElasticsearchDateFormat = "2006.01.02"
+	// DefaultElasticsearchMaxBatchSize is the maximal number of documents in a bulk request
+	DefaultElasticsearchMaxBatchSize = 500
+	// DefaultElasticsearchIndexTemplateName is the name of the index template of the Elasticsearch sink
+	DefaultElasticsearchIndexTemplateName = "k8s-events"
+	// DefaultSinkRequestTimeout is the timeout of each request of the HTTP sinks
+	DefaultSinkRequestTimeout = 10 * time.Second
+	// DefaultSinkMaxElapsedTime is how long a failed request of the HTTP sinks is retried
+	DefaultSinkMaxElapsedTime = time.Minute
+	// DefaultAttributionBufferWindow is how long unmatched request identities are buffered
+	DefaultAttributionBufferWindow = 30 * time.Second
+	// DefaultAttributionMatchWait is how long an event waits for the identity of its request
+	DefaultAttributionMatchWait = 5 * time.Second
+	// FieldValueLengthLimit is the maximal length of a field value in Logz.io
+	FieldValueLengthLimit = 32700
+	// DiffContextLines is the number of context lines in unified diffs
+	DiffContextLines = 3
 )
 const (
 	Metadata           = "metadata"
 	ManagedFields      = "managedFields"
 	ResourceVersion    = "resourceVersion"
 	Annotations        = "annotations"
+	Labels             = "labels"
 	DeploymentRevision = "deployment.kubernetes.io/revision"
 	Status             = "status"
+	Conditions         = "conditions"
+	// ConditionStatusUnknown is the status of unknown and removed status conditions
+	ConditionStatusUnknown = "Unknown"
+	// HelmReleaseSecretType is the type of the Secrets that store Helm 3 releases
+	HelmReleaseSecretType = "helm.sh/release.v1"
 )
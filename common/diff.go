@@ -0,0 +1,307 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEditDistance limits the number of changed lines matched by the diff, larger changes are diffed by line position
+const maxEditDistance = 1500
+
+// diffLineTruncatedNote ends a diff line that was cut to fit the limit
+const diffLineTruncatedNote = "... truncated\n"
+
+// diffLine is a single line of a line diff
+type diffLine struct {
+	op      byte // ' ' for context lines, '-' for removed lines and '+' for added lines
+	text    string
+	oldLine int // The old line number, or the number of the preceding old line for added lines
+	newLine int // The new line number, or the number of the preceding new line for removed lines
+}
+
+// diffHunk is a group of changed lines and their context
+type diffHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []diffLine
+}
+
+// header returns the unified diff header of the hunk
+func (hunk diffHunk) header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.oldStart, hunk.oldCount, hunk.newStart, hunk.newCount)
+}
+
+// splitLines splits a text into lines, without a trailing empty line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the line diff of two texts
+func diffLines(oldLines, newLines []string) (lines []diffLine) {
+	// Lines shared by the start and end of both texts are always context lines
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix && oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, diffLine{op: ' ', text: oldLines[i], oldLine: i + 1, newLine: i + 1})
+	}
+	lines = append(lines, diffMiddle(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		oldIndex, newIndex := len(oldLines)-suffix+i, len(newLines)-suffix+i
+		lines = append(lines, diffLine{op: ' ', text: oldLines[oldIndex], oldLine: oldIndex + 1, newLine: newIndex + 1})
+	}
+	return lines
+}
+
+// diffMiddle returns the line diff of the changed middle part of two texts, using the Myers shortest edit script
+func diffMiddle(oldLines, newLines []string, oldOffset, newOffset int) (lines []diffLine) {
+	n, m := len(oldLines), len(newLines)
+	// trace[d] holds the furthest old line index reached on each diagonal k in [-d, d] with d edits
+	var trace [][]int
+	found := false
+	for d := 0; d <= maxEditDistance && !found; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if d == 0 {
+				x = 0
+			} else if k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]) {
+				x = trace[d-1][k+1+d-1]
+			} else {
+				x = trace[d-1][k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && oldLines[x] == newLines[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		trace = append(trace, v)
+	}
+	if !found {
+		// Too many changes to match, diff the lines by their position
+		return diffByPosition(oldLines, newLines, oldOffset, newOffset)
+	}
+
+	// Backtrack the edit script from the end of both texts
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prevK := k - 1
+			if k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]) {
+				prevK = k + 1
+			}
+			prevX = trace[d-1][prevK+d-1]
+			prevY = prevX - prevK
+		}
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{op: ' ', text: oldLines[x], oldLine: oldOffset + x + 1, newLine: newOffset + y + 1})
+		}
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, diffLine{op: '+', text: newLines[prevY], oldLine: oldOffset + prevX, newLine: newOffset + prevY + 1})
+			} else {
+				lines = append(lines, diffLine{op: '-', text: oldLines[prevX], oldLine: oldOffset + prevX + 1, newLine: newOffset + prevY})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// The edit script was built backwards
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// diffByPosition returns the line diff of two texts by comparing the lines in the same positions
+func diffByPosition(oldLines, newLines []string, oldOffset, newOffset int) (lines []diffLine) {
+	for i := 0; i < len(oldLines) || i < len(newLines); i++ {
+		oldPosition, newPosition := oldOffset+min(i, len(oldLines)), newOffset+min(i, len(newLines))
+		if i < len(oldLines) && i < len(newLines) && oldLines[i] == newLines[i] {
+			lines = append(lines, diffLine{op: ' ', text: oldLines[i], oldLine: oldPosition + 1, newLine: newPosition + 1})
+			continue
+		}
+		if i < len(oldLines) {
+			lines = append(lines, diffLine{op: '-', text: oldLines[i], oldLine: oldPosition + 1, newLine: newPosition})
+		}
+		if i < len(newLines) {
+			lines = append(lines, diffLine{op: '+', text: newLines[i], oldLine: oldOffset + min(i+1, len(oldLines)), newLine: newPosition + 1})
+		}
+	}
+	return lines
+}
+
+// diffHunks groups the changed lines of a line diff into hunks with the given number of context lines
+func diffHunks(lines []diffLine, contextLines int) (hunks []diffHunk) {
+	var hunk *diffHunk
+	lastChange := -1
+	for index, line := range lines {
+		if line.op == ' ' {
+			continue
+		}
+		start := index - contextLines
+		if start < 0 {
+			start = 0
+		}
+		if hunk != nil && start <= lastChange+contextLines+1 {
+			// Close enough to the previous change to extend the current hunk
+			hunk.lines = append(hunk.lines, lines[lastChange+1:index+1]...)
+		} else {
+			if hunk != nil {
+				hunks = append(hunks, closeHunk(*hunk, lines, lastChange, contextLines))
+			}
+			hunk = &diffHunk{lines: append([]diffLine{}, lines[start:index+1]...)}
+		}
+		lastChange = index
+	}
+	if hunk != nil {
+		hunks = append(hunks, closeHunk(*hunk, lines, lastChange, contextLines))
+	}
+	return hunks
+}
+
+// closeHunk adds the trailing context lines to a hunk and calculates its line ranges
+func closeHunk(hunk diffHunk, lines []diffLine, lastChange int, contextLines int) diffHunk {
+	end := lastChange + contextLines + 1
+	if end > len(lines) {
+		end = len(lines)
+	}
+	hunk.lines = append(hunk.lines, lines[lastChange+1:end]...)
+
+	// Hunks without old or new lines start at the preceding line
+	hunk.oldStart, hunk.newStart = hunk.lines[0].oldLine, hunk.lines[0].newLine
+	for _, line := range hunk.lines {
+		if line.op != '+' {
+			if hunk.oldCount == 0 {
+				hunk.oldStart = line.oldLine
+			}
+			hunk.oldCount++
+		}
+		if line.op != '-' {
+			if hunk.newCount == 0 {
+				hunk.newStart = line.newLine
+			}
+			hunk.newCount++
+		}
+	}
+	return hunk
+}
+
+// UnifiedDiff returns the unified diff of two texts with the given number of context lines.
+// It returns an empty string if the texts are identical.
+func UnifiedDiff(oldName, newName, oldText, newText string, contextLines int) (diff string) {
+	hunks := diffHunks(diffLines(splitLines(oldText), splitLines(newText)), contextLines)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var diffBuilder strings.Builder
+	diffBuilder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	for _, hunk := range hunks {
+		writeHunk(&diffBuilder, hunk)
+	}
+	return diffBuilder.String()
+}
+
+// writeHunk writes a hunk in unified diff format
+func writeHunk(diffBuilder *strings.Builder, hunk diffHunk) {
+	diffBuilder.WriteString(hunk.header() + "\n")
+	for _, line := range hunk.lines {
+		diffBuilder.WriteByte(line.op)
+		diffBuilder.WriteString(line.text + "\n")
+	}
+}
+
+// TruncatedUnifiedDiff returns the unified diff of two texts, truncated around the changed hunks to fit the limit.
+// The number of context lines is reduced first, then the hunks that don't fit are dropped, and a hunk that doesn't fit
+// on its own is cut after its first lines, and the line it is cut in ends with a truncation note. A note with the
+// number of truncated hunks is added to truncated diffs.
+func TruncatedUnifiedDiff(oldName, newName, oldText, newText string, contextLines int, limit int) (diff string) {
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+	var hunks []diffHunk
+	for ; contextLines >= 0; contextLines-- {
+		hunks = diffHunks(lines, contextLines)
+		if diffLength(oldName, newName, hunks) <= limit {
+			break
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var diffBuilder strings.Builder
+	diffBuilder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	// Keep room for the truncation note
+	noteLimit := limit - 64
+	for hunkIndex, hunk := range hunks {
+		var hunkBuilder strings.Builder
+		writeHunk(&hunkBuilder, hunk)
+		if diffBuilder.Len()+hunkBuilder.Len() <= limit && (hunkIndex == len(hunks)-1 || diffBuilder.Len()+hunkBuilder.Len() <= noteLimit) {
+			diffBuilder.WriteString(hunkBuilder.String())
+			continue
+		}
+
+		truncatedHunks := len(hunks) - hunkIndex
+		if hunkIndex == 0 {
+			// The first hunk doesn't fit on its own, keep its first lines
+			remaining := noteLimit - diffBuilder.Len()
+			if remaining > 0 {
+				hunkText := hunkBuilder.String()
+				if len(hunkText) > remaining {
+					hunkText = truncateDiffLines(hunkText, remaining)
+				}
+				diffBuilder.WriteString(hunkText)
+			}
+		}
+		diffBuilder.WriteString(fmt.Sprintf("... %d hunks truncated\n", truncatedHunks))
+		break
+	}
+	return diffBuilder.String()
+}
+
+// truncateDiffLines truncates diff lines to a length. The lines that fit are kept whole, and the next line is cut to
+// the remaining length and ends with a truncation note.
+func truncateDiffLines(text string, length int) string {
+	cut := strings.LastIndex(text[:length], "\n") + 1
+	lineLength := length - cut - len(diffLineTruncatedNote)
+	// Keep more than the line prefix of the cut line
+	if lineLength <= 1 {
+		return text[:cut]
+	}
+	for !utf8.RuneStart(text[cut+lineLength]) {
+		lineLength--
+	}
+	return text[:cut+lineLength] + diffLineTruncatedNote
+}
+
+// diffLength returns the length of the unified diff of the hunks
+func diffLength(oldName, newName string, hunks []diffHunk) (length int) {
+	length = len(oldName) + len(newName) + 10
+	for _, hunk := range hunks {
+		length += len(hunk.header()) + 1
+		for _, line := range hunk.lines {
+			length += len(line.text) + 2
+		}
+	}
+	return length
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestUnifiedDiff tests the unified diff of changed, added and removed lines
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "user nginx;\nworker_processes 1;\nerror_log /var/log/nginx/error.log;\npid /run/nginx.pid;\n"
+	newText := "user nginx;\nworker_processes 4;\nerror_log /var/log/nginx/error.log;\npid /run/nginx.pid;\ninclude /etc/nginx/modules/*.conf;\n"
+
+	diff := UnifiedDiff("nginx.conf", "nginx.conf", oldText, newText, 1)
+	expected := `--- nginx.conf
++++ nginx.conf
+@@ -1,4 +1,5 @@
+ user nginx;
+-worker_processes 1;
++worker_processes 4;
+ error_log /var/log/nginx/error.log;
+ pid /run/nginx.pid;
++include /etc/nginx/modules/*.conf;
+`
+	if diff != expected {
+		t.Errorf("Unexpected unified diff, expected:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	diff = UnifiedDiff("nginx.conf", "nginx.conf", oldText, newText, 0)
+	expected = `--- nginx.conf
++++ nginx.conf
+@@ -2,1 +2,1 @@
+-worker_processes 1;
++worker_processes 4;
+@@ -4,0 +5,1 @@
++include /etc/nginx/modules/*.conf;
+`
+	if diff != expected {
+		t.Errorf("Unexpected unified diff without context, expected:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	if diff = UnifiedDiff("a", "b", oldText, oldText, 3); diff != "" {
+		t.Errorf("Expected an empty diff for identical texts, got:\n%s", diff)
+	}
+}
+
+// TestTruncatedUnifiedDiff tests that large diffs are truncated around the changed hunks
+func TestTruncatedUnifiedDiff(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 5000; i++ {
+		line := fmt.Sprintf("setting_%d = %s", i, strings.Repeat("x", 20))
+		oldLines = append(oldLines, line)
+		newLines = append(newLines, line)
+	}
+	// Change a line near the end of the text, where a head truncation would lose it
+	newLines[4500] = "setting_4500 = changed"
+	oldText, newText := strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+	if len(oldText) < FieldValueLengthLimit {
+		t.Fatalf("Expected a text over the field value limit")
+	}
+
+	diff := TruncatedUnifiedDiff("application.yaml", "application.yaml", oldText, newText, DiffContextLines, FieldValueLengthLimit-1)
+	if !strings.Contains(diff, "+setting_4500 = changed") || !strings.Contains(diff, "-setting_4500 = "+strings.Repeat("x", 20)) {
+		t.Errorf("Expected the changed line in the diff, got:\n%s", diff)
+	}
+
+	// Change every other line, so the diff itself is over the limit
+	for i := 0; i < len(newLines); i += 2 {
+		newLines[i] = fmt.Sprintf("setting_%d = changed", i)
+	}
+	diff = TruncatedUnifiedDiff("application.yaml", "application.yaml", oldText, strings.Join(newLines, "\n"), DiffContextLines, 2000)
+	if len(diff) > 2000 {
+		t.Errorf("Expected the diff to fit the limit, got %d characters", len(diff))
+	}
+	if !strings.Contains(diff, "+setting_0 = changed") || !strings.HasSuffix(diff, "hunks truncated\n") {
+		t.Errorf("Expected the first hunks and a truncation note, got:\n%s", diff)
+	}
+}
+
+// TestTruncatedUnifiedDiffLongLine tests that a changed line over the limit is cut with a truncation note
+func TestTruncatedUnifiedDiffLongLine(t *testing.T) {
+	oldText := "replicas: 1\ncertificate: " + strings.Repeat("é", 2500) + "\nport: 80\n"
+	newText := "replicas: 1\ncertificate: " + strings.Repeat("a", 5000) + "\nport: 80\n"
+
+	diff := TruncatedUnifiedDiff("config.yaml", "config.yaml", oldText, newText, DiffContextLines, 1000)
+	if len(diff) > 1000 || !utf8.ValidString(diff) {
+		t.Errorf("Expected a valid diff within the limit, got %d characters", len(diff))
+	}
+	if !strings.HasPrefix(diff, "--- config.yaml\n+++ config.yaml\n@@ -2,1 +2,1 @@\n-certificate: éé") || !strings.HasSuffix(diff, "é... truncated\n... 1 hunks truncated\n") {
+		t.Errorf("Expected the changed line cut with a truncation note, got:\n%s", diff)
+	}
+}
+
+// TestDiffLinesReconstruction tests that the line diff of random texts reconstructs both texts with consistent line numbers
+func TestDiffLinesReconstruction(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() (lines []string) {
+		for i := random.Intn(40); i > 0; i-- {
+			lines = append(lines, fmt.Sprintf("line %d", random.Intn(8)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 200; i++ {
+		oldLines, newLines := randomLines(), randomLines()
+		lines := diffLines(oldLines, newLines)
+		if i%2 == 1 {
+			lines = diffByPosition(oldLines, newLines, 0, 0)
+		}
+		var gotOld, gotNew []string
+		for _, line := range lines {
+			if line.op != '+' {
+				gotOld = append(gotOld, line.text)
+				if line.oldLine != len(gotOld) {
+					t.Fatalf("Unexpected old line number %d for line %d", line.oldLine, len(gotOld))
+				}
+			}
+			if line.op != '-' {
+				gotNew = append(gotNew, line.text)
+				if line.newLine != len(gotNew) {
+					t.Fatalf("Unexpected new line number %d for line %d", line.newLine, len(gotNew))
+				}
+			}
+		}
+		if strings.Join(gotOld, "\n") != strings.Join(oldLines, "\n") || strings.Join(gotNew, "\n") != strings.Join(newLines, "\n") {
+			t.Fatalf("The line diff doesn't reconstruct the texts:\nold: %v\nnew: %v", oldLines, newLines)
+		}
+	}
+}
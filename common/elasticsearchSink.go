@@ -0,0 +1,381 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Index pattern placeholders, replaced by the values of each event log
+const (
+	ElasticsearchIndexDate      = "{date}"
+	ElasticsearchIndexKind      = "{kind}"
+	ElasticsearchIndexNamespace = "{namespace}"
+	ElasticsearchIndexEventType = "{eventType}"
+)
+
+// elasticsearchIndexPlaceholders matches the placeholders of index patterns
+var elasticsearchIndexPlaceholders = regexp.MustCompile(`\{(date|kind|namespace|eventType)\}`)
+
+// elasticsearchInvalidIndexCharacters matches the characters that index names can't contain, and the braces of unknown placeholders
+var elasticsearchInvalidIndexCharacters = regexp.MustCompile(`[\\/*?"<>|\s,#:{}]+`)
+
+// elasticsearchKeywordMaxCharacters is the length of the longest string indexed as a keyword, longer strings
+// are stored without being indexed, as the terms of keywords are limited to 32766 bytes
+const elasticsearchKeywordMaxCharacters = 8191
+
+// elasticsearchDocument is a JSON event log and the index and id it is indexed with
+type elasticsearchDocument struct {
+	index  string
+	id     string
+	source []byte
+}
+
+// elasticsearchBulkResponse is the response of a bulk request, with an item of each action in the order of the request
+type elasticsearchBulkResponse struct {
+	Errors bool                                     `json:"errors"`
+	Items  []map[string]elasticsearchBulkItemResult `json:"items"`
+}
+
+// elasticsearchBulkItemResult is the result of an action of a bulk request
+type elasticsearchBulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// ElasticsearchSink indexes event logs in Elasticsearch or OpenSearch with batched bulk requests
+type ElasticsearchSink struct {
+	name           string
+	client         *http.Client
+	endpoint       string
+	index          string
+	dateFormat     string
+	headers        map[string]string
+	username       string
+	password       string
+	indexTemplate  *ElasticsearchIndexTemplateConfig
+	maxBatchSize   int
+	maxElapsedTime time.Duration
+	batchMux       sync.Mutex
+	batch          []elasticsearchDocument
+	isInstalled    bool
+	mux            sync.Mutex
+	lastErr        error
+}
+
+// init registers the Elasticsearch sink type
+func init() {
+	RegisterSinkType(SinkTypeElasticsearch, NewElasticsearchSink)
+}
+
+// NewElasticsearchSink creates an Elasticsearch sink, the index template is installed with the first bulk request
+func NewElasticsearchSink(config SinkConfig) (Sink, error) {
+	if config.Elasticsearch == nil || config.Elasticsearch.Endpoint == "" {
+		return nil, fmt.Errorf("no endpoint is configured for the Elasticsearch sink")
+	}
+	sinkConfig := *config.Elasticsearch
+	sink := &ElasticsearchSink{
+		name:           config.Name,
+		endpoint:       strings.TrimSuffix(sinkConfig.Endpoint, "/"),
+		index:          sinkConfig.Index,
+		dateFormat:     sinkConfig.DateFormat,
+		headers:        map[string]string{},
+		username:       os.ExpandEnv(sinkConfig.Username),
+		password:       os.ExpandEnv(sinkConfig.Password),
+		indexTemplate:  sinkConfig.IndexTemplate,
+		maxBatchSize:   sinkConfig.MaxBatchSize,
+		maxElapsedTime: sinkConfig.MaxElapsedTime.Duration,
+	}
+	if sink.index == "" {
+		sink.index = DefaultElasticsearchIndex
+	}
+	if literal := elasticsearchIndexPlaceholders.ReplaceAllString(sink.index, ""); literal != strings.ToLower(literal) || elasticsearchInvalidIndexCharacters.MatchString(literal) {
+		return nil, fmt.Errorf("invalid Elasticsearch index pattern: %s, index names are lowercase without spaces or any of: \\/*?\"<>|,#:{}", sink.index)
+	}
+	if sink.dateFormat == "" {
+		sink.dateFormat = DefaultElasticsearchDateFormat
+	}
+	if sink.maxBatchSize <= 0 {
+		sink.maxBatchSize = DefaultElasticsearchMaxBatchSize
+	}
+	if sink.maxElapsedTime <= 0 {
+		sink.maxElapsedTime = DefaultSinkMaxElapsedTime
+	}
+	if sink.indexTemplate != nil && sink.indexTemplate.Name == "" {
+		indexTemplate := *sink.indexTemplate
+		indexTemplate.Name = DefaultElasticsearchIndexTemplateName
+		sink.indexTemplate = &indexTemplate
+	}
+	timeout := sinkConfig.Timeout.Duration
+	if timeout <= 0 {
+		timeout = DefaultSinkRequestTimeout
+	}
+	sink.client = &http.Client{Timeout: timeout}
+
+	for key, value := range sinkConfig.Headers {
+		sink.headers[key] = os.ExpandEnv(value)
+	}
+	if apiKey := os.ExpandEnv(sinkConfig.APIKey); apiKey != "" {
+		sink.headers["Authorization"] = "ApiKey " + apiKey
+	}
+	return sink, nil
+}
+
+// Send adds an event log to the batch, and indexes the batch when it is full
+func (sink *ElasticsearchSink) Send(event SinkEvent) error {
+	now := time.Now()
+	fields := make(map[string]interface{}, len(event.Fields)+1)
+	for key, value := range event.Fields {
+		fields[key] = value
+	}
+	fields["@timestamp"] = now.UTC().Format(time.RFC3339Nano)
+	source, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	sink.batchMux.Lock()
+	sink.batch = append(sink.batch, elasticsearchDocument{index: sink.indexName(event, now), id: event.ID(), source: source})
+	isFull := len(sink.batch) >= sink.maxBatchSize
+	sink.batchMux.Unlock()
+	if isFull {
+		return sink.Flush()
+	}
+	return nil
+}
+
+// Flush installs the index template if it isn't installed, and indexes the batched event logs. Throttled documents are
+// retried with backoff, and documents rejected by the cluster, for example by mapping conflicts, are dropped.
+func (sink *ElasticsearchSink) Flush() error {
+	sink.batchMux.Lock()
+	defer sink.batchMux.Unlock()
+	if len(sink.batch) == 0 {
+		return nil
+	}
+	pending := sink.batch
+	sink.batch = nil
+
+	if sink.indexTemplate != nil && !sink.isInstalled {
+		// The event logs are indexed even if the template isn't installed, and its installation is retried with the next batch
+		if err := sink.installIndexTemplate(); err != nil {
+			log.Printf("[ERROR] Failed to install Elasticsearch index template: %s.\nERROR:\n%v", sink.indexTemplate.Name, err)
+		} else {
+			sink.isInstalled = true
+		}
+	}
+
+	var rejected []string
+	err := retryWithBackoff(sink.name, sink.maxElapsedTime, func() error {
+		throttled, reasons, err := sink.bulk(pending)
+		rejected = append(rejected, reasons...)
+		if err != nil {
+			return err
+		}
+		pending = throttled
+		if len(pending) > 0 {
+			return &httpSinkError{statusCode: http.StatusTooManyRequests, message: fmt.Sprintf("%d documents were throttled", len(pending)), retryable: true}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("dropped %d documents: %w", len(pending), err)
+	} else if len(rejected) > 0 {
+		err = fmt.Errorf("dropped %d documents rejected by the cluster: %s", len(rejected), rejected[0])
+	}
+	sink.setLastErr(err)
+	return err
+}
+
+// Close indexes the batched event logs
+func (sink *ElasticsearchSink) Close() error {
+	return sink.Flush()
+}
+
+// Health returns the error of the last bulk request
+func (sink *ElasticsearchSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// setLastErr records the error of the last bulk request
+func (sink *ElasticsearchSink) setLastErr(err error) {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.lastErr = err
+}
+
+// indexName returns the index of an event log, by replacing the placeholders of the index pattern with the lowercase
+// values of the event log, or "none" for missing values
+func (sink *ElasticsearchSink) indexName(event SinkEvent, now time.Time) string {
+	return elasticsearchIndexPlaceholders.ReplaceAllStringFunc(sink.index, func(placeholder string) string {
+		var value string
+		switch placeholder {
+		case ElasticsearchIndexDate:
+			value = now.UTC().Format(sink.dateFormat)
+		case ElasticsearchIndexKind:
+			value = event.Kind()
+		case ElasticsearchIndexNamespace:
+			value = event.Namespace()
+		case ElasticsearchIndexEventType:
+			value = event.EventType()
+		}
+		value = elasticsearchInvalidIndexCharacters.ReplaceAllString(strings.ToLower(value), "-")
+		if value == "" {
+			return "none"
+		}
+		return value
+	})
+}
+
+// bulk indexes documents with a bulk request, and returns the documents that were throttled and the reasons of the
+// documents that were rejected
+func (sink *ElasticsearchSink) bulk(documents []elasticsearchDocument) (throttled []elasticsearchDocument, rejected []string, err error) {
+	var body bytes.Buffer
+	for _, document := range documents {
+		action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": document.index, "_id": document.id}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document.source)
+		body.WriteByte('\n')
+	}
+	responseBody, err := sink.request(http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var response elasticsearchBulkResponse
+	if err = json.Unmarshal(responseBody, &response); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if !response.Errors {
+		return nil, nil, nil
+	}
+	if len(response.Items) != len(documents) {
+		return nil, nil, fmt.Errorf("bulk response has %d items of %d documents", len(response.Items), len(documents))
+	}
+	for itemIndex, item := range response.Items {
+		for _, result := range item {
+			switch {
+			case result.Status >= 200 && result.Status < 300:
+			case isRetryableStatus(result.Status):
+				throttled = append(throttled, documents[itemIndex])
+			default:
+				reason := fmt.Sprintf("document: %s of index: %s failed with status: %d", documents[itemIndex].id, documents[itemIndex].index, result.Status)
+				if result.Error != nil {
+					reason += fmt.Sprintf(": %s: %s", result.Error.Type, result.Error.Reason)
+				}
+				log.Printf("[ERROR] Elasticsearch rejected %s.", reason)
+				rejected = append(rejected, reason)
+			}
+		}
+	}
+	return throttled, rejected, nil
+}
+
+// installIndexTemplate puts the composable index template of the index pattern
+func (sink *ElasticsearchSink) installIndexTemplate() error {
+	body, err := json.Marshal(sink.indexTemplateBody())
+	if err != nil {
+		return err
+	}
+	_, err = sink.request(http.MethodPut, "/_index_template/"+sink.indexTemplate.Name, "application/json", body)
+	return err
+}
+
+// indexTemplateBody returns the index template of the event logs, matching the indices of the index pattern. Event logs
+// are limited as in Logz.io: field names have no dots, lists of objects are JSON strings and long values are truncated
+// into "_overLimit" fields, so strings are mapped as keywords, with the message and truncated values as text.
+func (sink *ElasticsearchSink) indexTemplateBody() map[string]interface{} {
+	settings := map[string]interface{}{"index.mapping.total_fields.limit": 10000}
+	if sink.indexTemplate.Shards > 0 {
+		settings["index.number_of_shards"] = sink.indexTemplate.Shards
+	}
+	if sink.indexTemplate.Replicas != nil {
+		settings["index.number_of_replicas"] = *sink.indexTemplate.Replicas
+	}
+	keyword := map[string]interface{}{"type": "keyword"}
+	return map[string]interface{}{
+		"index_patterns": []string{elasticsearchIndexPlaceholders.ReplaceAllString(sink.index, "*")},
+		"priority":       sink.indexTemplate.Priority,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				"date_detection": false,
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{"over_limit": map[string]interface{}{
+						"match":              "*_overLimit",
+						"match_mapping_type": "string",
+						"mapping":            map[string]interface{}{"type": "text"},
+					}},
+					map[string]interface{}{"strings": map[string]interface{}{
+						"match_mapping_type": "string",
+						"mapping":            map[string]interface{}{"type": "keyword", "ignore_above": elasticsearchKeywordMaxCharacters},
+					}},
+				},
+				"properties": map[string]interface{}{
+					"@timestamp":       map[string]interface{}{"type": "date"},
+					"message":          map[string]interface{}{"type": "text"},
+					"type":             keyword,
+					"env_id":           keyword,
+					"eventType":        keyword,
+					"changeCategories": keyword,
+					"newObject":        elasticsearchObjectMapping(),
+					"oldObject":        elasticsearchObjectMapping(),
+				},
+			},
+		},
+	}
+}
+
+// elasticsearchObjectMapping returns the mapping of the identifying fields of the objects of event logs
+func elasticsearchObjectMapping() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	return map[string]interface{}{"properties": map[string]interface{}{
+		"apiVersion": keyword,
+		"kind":       keyword,
+		Metadata: map[string]interface{}{"properties": map[string]interface{}{
+			"name":              keyword,
+			"namespace":         keyword,
+			"uid":               keyword,
+			ResourceVersion:     keyword,
+			"creationTimestamp": map[string]interface{}{"type": "date"},
+		}},
+	}}
+}
+
+// request sends a request to the cluster, and returns the response body of a successful request
+func (sink *ElasticsearchSink) request(method string, path string, contentType string, body []byte) ([]byte, error) {
+	request, err := http.NewRequest(method, sink.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	if sink.username != "" {
+		request.SetBasicAuth(sink.username, sink.password)
+	}
+	for key, value := range sink.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return nil, newNetworkSinkError(err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return responseBody, nil
+	}
+	return nil, newHTTPSinkError(response, strings.TrimSpace(string(responseBody)))
+}
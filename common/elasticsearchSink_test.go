@@ -0,0 +1,246 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBulkAction is an action of a bulk request with its document
+type testBulkAction struct {
+	Index    string
+	ID       string
+	Document map[string]interface{}
+}
+
+// testBulkEndpoint is the state of an Elasticsearch bulk API on a test receiver, which responds to the documents of
+// each id with the queued item statuses
+type testBulkEndpoint struct {
+	*testHTTPReceiver
+	indexed      []testBulkAction
+	itemStatuses map[string][]int
+	templates    map[string]map[string]interface{}
+}
+
+// newTestBulkEndpoint starts a test receiver of the bulk API, which responds with the queued status codes first
+func newTestBulkEndpoint(t *testing.T, itemStatuses map[string][]int, responses ...int) *testBulkEndpoint {
+	endpoint := &testBulkEndpoint{itemStatuses: itemStatuses, templates: map[string]map[string]interface{}{}}
+	endpoint.testHTTPReceiver = newTestHTTPReceiver(t, endpoint.handle, responses...)
+	return endpoint
+}
+
+// handle indexes the documents of bulk requests and stores index templates
+func (endpoint *testBulkEndpoint) handle(writer http.ResponseWriter, request testHTTPRequest) {
+	if name, ok := strings.CutPrefix(request.path, "/_index_template/"); ok && request.method == http.MethodPut {
+		var template map[string]interface{}
+		json.Unmarshal(request.body, &template)
+		endpoint.templates[name] = template
+		writer.Write([]byte(`{"acknowledged":true}`))
+		return
+	}
+	if request.path != "/_bulk" || request.headers.Get("Content-Type") != "application/x-ndjson" {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response := elasticsearchBulkResponse{}
+	scanner := bufio.NewScanner(bytes.NewReader(request.body))
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		indexed := testBulkAction{Index: action["index"]["_index"], ID: action["index"]["_id"]}
+		json.Unmarshal(scanner.Bytes(), &indexed.Document)
+
+		result := elasticsearchBulkItemResult{Status: http.StatusCreated}
+		if statuses := endpoint.itemStatuses[indexed.ID]; len(statuses) > 0 {
+			result.Status = statuses[0]
+			endpoint.itemStatuses[indexed.ID] = statuses[1:]
+			result.Error = &struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			}{Type: "test_exception", Reason: fmt.Sprintf("status %d", result.Status)}
+			response.Errors = true
+		} else {
+			endpoint.indexed = append(endpoint.indexed, indexed)
+		}
+		response.Items = append(response.Items, map[string]elasticsearchBulkItemResult{"index": result})
+	}
+	json.NewEncoder(writer).Encode(response)
+}
+
+// bulkRequests returns the number of bulk requests received, and the authorization of the last request
+func (endpoint *testBulkEndpoint) bulkRequests() (count int, authorization string) {
+	for _, request := range endpoint.received() {
+		if request.path == "/_bulk" {
+			count++
+		}
+		authorization = request.headers.Get("Authorization")
+	}
+	return count, authorization
+}
+
+// TestElasticsearchSinkBulk tests batching documents into bulk requests of per-kind daily indices
+func TestElasticsearchSinkBulk(t *testing.T) {
+	endpoint := newTestBulkEndpoint(t, nil)
+	sink, err := NewElasticsearchSink(SinkConfig{Elasticsearch: &ElasticsearchSinkConfig{
+		Endpoint:     endpoint.server.URL + "/",
+		Index:        "k8s-{kind}-{date}",
+		APIKey:       "encoded-key",
+		MaxBatchSize: 2,
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create Elasticsearch sink: %v", err)
+	}
+
+	for _, event := range []SinkEvent{getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", testObjectVersion("1")), getTestSinkEvent(EventTypeModified, "ConfigMap", "settings", "default", testObjectVersion("2")), getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", testObjectVersion("3"))} {
+		if err = sink.Send(event); err != nil {
+			t.Fatalf("Failed to send event log: %v", err)
+		}
+	}
+	if requests, _ := endpoint.bulkRequests(); requests != 1 || len(endpoint.indexed) != 2 {
+		t.Fatalf("Expected a bulk request of a full batch, got %d requests of %d documents", requests, len(endpoint.indexed))
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Failed to close Elasticsearch sink: %v", err)
+	}
+	requests, authorization := endpoint.bulkRequests()
+	if requests != 2 || len(endpoint.indexed) != 3 {
+		t.Fatalf("Expected the batch to be indexed on close, got %d requests of %d documents", requests, len(endpoint.indexed))
+	}
+
+	date := time.Now().UTC().Format(DefaultElasticsearchDateFormat)
+	if indexed := endpoint.indexed[1]; indexed.Index != "k8s-configmap-"+date || indexed.ID != "settings-uid-2" || indexed.Document["message"] == nil || indexed.Document["@timestamp"] == nil {
+		t.Errorf("Unexpected indexed document: %+v", indexed)
+	}
+	if authorization != "ApiKey encoded-key" {
+		t.Errorf("Expected an API key authorization, got %s", authorization)
+	}
+	if len(endpoint.templates) != 0 {
+		t.Errorf("Expected no index template, got %v", endpoint.templates)
+	}
+}
+
+// TestElasticsearchSinkItemErrors tests retrying throttled documents and dropping rejected documents
+func TestElasticsearchSinkItemErrors(t *testing.T) {
+	withSinkBackoff(t)
+	endpoint := newTestBulkEndpoint(t, map[string][]int{
+		"web-uid-1":      {http.StatusTooManyRequests, http.StatusTooManyRequests},
+		"settings-uid-2": {http.StatusBadRequest},
+	})
+	sink, _ := NewElasticsearchSink(SinkConfig{Elasticsearch: &ElasticsearchSinkConfig{Endpoint: endpoint.server.URL, Username: "collector", Password: "secret"}})
+
+	sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", testObjectVersion("1")))
+	sink.Send(getTestSinkEvent(EventTypeModified, "ConfigMap", "settings", "default", testObjectVersion("2")))
+	sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "api", "default", testObjectVersion("3")))
+	err := sink.Flush()
+	if err == nil || !strings.Contains(err.Error(), "dropped 1 documents rejected") || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Expected an error of the rejected document, got %v", err)
+	}
+	if sink.Health() == nil {
+		t.Error("Expected the sink to be unhealthy")
+	}
+	requests, authorization := endpoint.bulkRequests()
+	if requests != 3 || len(endpoint.indexed) != 2 || endpoint.indexed[1].ID != "web-uid-1" {
+		t.Errorf("Expected the throttled document to be retried alone, got %d requests of %+v", requests, endpoint.indexed)
+	}
+	if !strings.HasPrefix(authorization, "Basic ") {
+		t.Errorf("Expected a basic authorization, got %s", authorization)
+	}
+
+	// Throttled bulk requests are retried, and logged with the name of the sink without its endpoint and credentials
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	throttled := newTestBulkEndpoint(t, nil, http.StatusTooManyRequests)
+	endpointURL := strings.Replace(throttled.server.URL, "://", "://user:secret-token@", 1)
+	sink, _ = NewElasticsearchSink(SinkConfig{Name: "elasticsearch-test", Elasticsearch: &ElasticsearchSinkConfig{Endpoint: endpointURL}})
+	sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", testObjectVersion("1")))
+	if err = sink.Flush(); err != nil || len(throttled.indexed) != 1 || sink.Health() != nil {
+		t.Errorf("Expected the throttled bulk request to be retried, got %v", err)
+	}
+	if !strings.Contains(output.String(), "sink: elasticsearch-test") || strings.Contains(output.String(), "secret-token") {
+		t.Errorf("Expected the retries to be logged with the sink name only, got:\n%s", output.String())
+	}
+}
+
+// TestElasticsearchSinkIndexTemplate tests installing the index template of the index pattern before the first bulk request
+func TestElasticsearchSinkIndexTemplate(t *testing.T) {
+	endpoint := newTestBulkEndpoint(t, nil)
+	replicas := 0
+	sink, _ := NewElasticsearchSink(SinkConfig{Elasticsearch: &ElasticsearchSinkConfig{
+		Endpoint:      endpoint.server.URL,
+		Index:         "k8s-{namespace}-{date}",
+		IndexTemplate: &ElasticsearchIndexTemplateConfig{Priority: 200, Replicas: &replicas},
+	}})
+	for run := 0; run < 2; run++ {
+		sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", testObjectVersion("1")))
+		if err := sink.Flush(); err != nil {
+			t.Fatalf("Failed to flush Elasticsearch sink: %v", err)
+		}
+	}
+
+	template, ok := endpoint.templates[DefaultElasticsearchIndexTemplateName]
+	if !ok {
+		t.Fatalf("Expected the index template to be installed, got %v", endpoint.templates)
+	}
+	if patterns := fmt.Sprint(template["index_patterns"]); patterns != "[k8s-*-*]" || template["priority"] != float64(200) {
+		t.Errorf("Unexpected index patterns: %s or priority: %v", patterns, template["priority"])
+	}
+	templateJSON, _ := json.Marshal(template)
+	for _, expected := range []string{`"index.number_of_replicas":0`, `"message":{"type":"text"}`, `"match":"*_overLimit"`, `"ignore_above":8191`, `"resourceVersion":{"type":"keyword"}`} {
+		if !strings.Contains(string(templateJSON), expected) {
+			t.Errorf("Expected the index template to contain %s, got %s", expected, templateJSON)
+		}
+	}
+}
+
+// TestElasticsearchIndexName tests replacing the placeholders of index patterns with valid index names
+func TestElasticsearchIndexName(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := getTestSinkEvent(EventTypeModified, "HorizontalPodAutoscaler", "web", "default", testObjectVersion("1"))
+	tests := map[string]string{
+		"k8s-events-{date}":             "k8s-events-2024.05.01",
+		"k8s-{kind}":                    "k8s-horizontalpodautoscaler",
+		"k8s-{namespace}-{eventType}":   "k8s-default-modified",
+		"logs-{kind}-{date}":            "logs-horizontalpodautoscaler-2024.05.01",
+		"k8s-{eventType}-{kind}-static": "k8s-modified-horizontalpodautoscaler-static",
+	}
+	for index, expected := range tests {
+		sink, err := NewElasticsearchSink(SinkConfig{Elasticsearch: &ElasticsearchSinkConfig{Endpoint: "http://localhost:9200", Index: index}})
+		if err != nil {
+			t.Fatalf("Failed to create Elasticsearch sink of index pattern: %s: %v", index, err)
+		}
+		if name := sink.(*ElasticsearchSink).indexName(event, now); name != expected {
+			t.Errorf("Expected index %s of pattern %s, got %s", expected, index, name)
+		}
+	}
+
+	sink, _ := NewElasticsearchSink(SinkConfig{Elasticsearch: &ElasticsearchSinkConfig{Endpoint: "http://localhost:9200", Index: "k8s-{kind}", DateFormat: "2006.01"}})
+	collectorLog := SinkEvent{Fields: map[string]interface{}{"message": "Collector started"}}
+	if name := sink.(*ElasticsearchSink).indexName(collectorLog, now); name != "k8s-none" {
+		t.Errorf("Expected the index of an event log without a kind, got %s", name)
+	}
+}
+
+// TestNewElasticsearchSink tests the validation of the Elasticsearch sink configuration
+func TestNewElasticsearchSink(t *testing.T) {
+	invalidConfigs := map[string]ElasticsearchSinkConfig{
+		"no endpoint":              {},
+		"uppercase index":          {Endpoint: "http://localhost:9200", Index: "K8s-{date}"},
+		"index with a space":       {Endpoint: "http://localhost:9200", Index: "k8s events"},
+		"index with a placeholder": {Endpoint: "http://localhost:9200", Index: "k8s-{name}"},
+	}
+	for name, config := range invalidConfigs {
+		if _, err := NewElasticsearchSink(SinkConfig{Elasticsearch: &config}); err == nil {
+			t.Errorf("Expected an error of a configuration with %s", name)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package common
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileSinkExtension is the extension of the NDJSON files of the file sink
+const fileSinkExtension = ".ndjson"
+
+// fileSinkTimestampFormat is the timestamp in the names of rotated files, which sorts the files by their rotation
+const fileSinkTimestampFormat = "20060102T150405.000000000Z"
+
+// FileSink writes NDJSON event logs to a local file, which is rotated by size and age into timestamped,
+// optionally compressed files with limited retention
+type FileSink struct {
+	directory      string
+	baseName       string
+	maxFileBytes   int64
+	rotateInterval time.Duration
+	compress       bool
+	maxFiles       int
+	maxTotalBytes  int64
+	mux            sync.Mutex
+	file           *os.File
+	writer         *bufio.Writer
+	size           int64
+	openedAt       time.Time
+	isDirty        bool
+	lastErr        error
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+// init registers the file sink type
+func init() {
+	RegisterSinkType(SinkTypeFile, NewFileSink)
+}
+
+// NewFileSink creates a file sink, opens its file for appending and starts syncing it to disk
+func NewFileSink(config SinkConfig) (Sink, error) {
+	if config.File == nil || config.File.Directory == "" {
+		return nil, fmt.Errorf("no directory is configured for the file sink")
+	}
+	sinkConfig := *config.File
+	sink := &FileSink{
+		directory:      sinkConfig.Directory,
+		baseName:       sinkConfig.BaseName,
+		maxFileBytes:   sinkConfig.MaxFileBytes,
+		rotateInterval: sinkConfig.RotateInterval.Duration,
+		compress:       sinkConfig.Compress,
+		maxFiles:       sinkConfig.MaxFiles,
+		maxTotalBytes:  sinkConfig.MaxTotalBytes,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	if sink.baseName == "" {
+		sink.baseName = DefaultFileSinkBaseName
+	}
+	if sink.maxFileBytes <= 0 {
+		sink.maxFileBytes = DefaultFileSinkMaxFileBytes
+	}
+	if sink.rotateInterval <= 0 {
+		sink.rotateInterval = DefaultFileSinkRotateInterval
+	}
+	syncInterval := sinkConfig.SyncInterval.Duration
+	if syncInterval <= 0 {
+		syncInterval = DefaultFileSinkSyncInterval
+	}
+
+	if err := os.MkdirAll(sink.directory, 0o755); err != nil {
+		return nil, err
+	}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	go sink.syncLoop(syncInterval)
+	return sink, nil
+}
+
+// activePath returns the path of the file the event logs are written to
+func (sink *FileSink) activePath() string {
+	return filepath.Join(sink.directory, sink.baseName+fileSinkExtension)
+}
+
+// open opens the active file for appending
+func (sink *FileSink) open() error {
+	file, err := os.OpenFile(sink.activePath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	sink.file, sink.writer = file, bufio.NewWriter(file)
+	sink.size, sink.openedAt = info.Size(), time.Now()
+	return nil
+}
+
+// Send writes an event log as a line of the active file, after rotating the file if it is full or old
+func (sink *FileSink) Send(event SinkEvent) error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+
+	line := append(slices.Clip(event.JSON), '\n')
+	var err error
+	if sink.size > 0 && (sink.size+int64(len(line)) > sink.maxFileBytes || sink.isExpired()) {
+		err = sink.rotate()
+	}
+	if err == nil && sink.writer == nil {
+		// A failed rotation is retried with the next event log
+		err = sink.open()
+	}
+	if err == nil {
+		var written int
+		written, err = sink.writer.Write(line)
+		sink.size += int64(written)
+		sink.isDirty = true
+	}
+	sink.lastErr = err
+	return err
+}
+
+// Flush writes the buffered event logs to the active file
+func (sink *FileSink) Flush() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	if sink.writer == nil {
+		return nil
+	}
+	return sink.writer.Flush()
+}
+
+// Close stops syncing, and writes and syncs the buffered event logs before closing the active file
+func (sink *FileSink) Close() error {
+	close(sink.stop)
+	<-sink.done
+
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	if sink.writer == nil {
+		return nil
+	}
+	err := sink.sync()
+	if closeErr := sink.file.Close(); err == nil {
+		err = closeErr
+	}
+	sink.file, sink.writer = nil, nil
+	return err
+}
+
+// Health returns the error of the last written event log
+func (sink *FileSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// isExpired returns whether the active file is older than the rotation interval
+func (sink *FileSink) isExpired() bool {
+	return time.Since(sink.openedAt) >= sink.rotateInterval
+}
+
+// syncLoop syncs the written event logs to disk and rotates the active file by age on an interval, until the sink is closed
+func (sink *FileSink) syncLoop(interval time.Duration) {
+	defer close(sink.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sink.stop:
+			return
+		case <-ticker.C:
+			sink.mux.Lock()
+			if sink.size > 0 && sink.isExpired() {
+				// The file is rotated by age without waiting for the next event log
+				if err := sink.rotate(); err != nil {
+					log.Printf("[ERROR] Failed to rotate file: %s.\nERROR:\n%v", sink.activePath(), err)
+					sink.lastErr = err
+				}
+			} else if err := sink.sync(); err != nil {
+				log.Printf("[ERROR] Failed to sync file: %s.\nERROR:\n%v", sink.activePath(), err)
+			}
+			sink.mux.Unlock()
+		}
+	}
+}
+
+// sync writes the buffered event logs to the active file and syncs it to disk, if event logs were written since the last sync
+func (sink *FileSink) sync() error {
+	if sink.writer == nil || !sink.isDirty {
+		return nil
+	}
+	if err := sink.writer.Flush(); err != nil {
+		return err
+	}
+	sink.isDirty = false
+	return sink.file.Sync()
+}
+
+// rotate closes the active file, renames it with a timestamp, compresses it if configured, removes the rotated files
+// beyond the retention limits and opens a new active file
+func (sink *FileSink) rotate() error {
+	if sink.file != nil {
+		err := sink.sync()
+		if closeErr := sink.file.Close(); err == nil {
+			err = closeErr
+		}
+		// When the rotation fails, the next event log reopens the active file, which is rotated again when it is full or old
+		sink.file, sink.writer, sink.size = nil, nil, 0
+		if err != nil {
+			return err
+		}
+	}
+
+	rotatedPath := filepath.Join(sink.directory, sink.baseName+"-"+time.Now().UTC().Format(fileSinkTimestampFormat)+fileSinkExtension)
+	if err := os.Rename(sink.activePath(), rotatedPath); err != nil {
+		return err
+	}
+	if sink.compress {
+		if err := compressFile(rotatedPath); err != nil {
+			// The uncompressed file is kept
+			log.Printf("[ERROR] Failed to compress rotated file: %s.\nERROR:\n%v", rotatedPath, err)
+		}
+	}
+	sink.enforceRetention()
+	return sink.open()
+}
+
+// compressFile compresses a file into a .gz file, and removes the file
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// rotatedFiles returns the paths of the rotated files, oldest first
+func (sink *FileSink) rotatedFiles() (paths []string) {
+	entries, err := os.ReadDir(sink.directory)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, sink.baseName+"-") &&
+			(strings.HasSuffix(name, fileSinkExtension) || strings.HasSuffix(name, fileSinkExtension+".gz")) {
+			paths = append(paths, filepath.Join(sink.directory, name))
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// enforceRetention removes the oldest rotated files beyond the maximal number of files or total size
+func (sink *FileSink) enforceRetention() {
+	if sink.maxFiles <= 0 && sink.maxTotalBytes <= 0 {
+		return
+	}
+	paths := sink.rotatedFiles()
+	sizes := make([]int64, len(paths))
+	var totalBytes int64
+	for pathIndex, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			sizes[pathIndex] = info.Size()
+			totalBytes += info.Size()
+		}
+	}
+
+	for pathIndex, path := range paths {
+		remaining := len(paths) - pathIndex
+		if (sink.maxFiles <= 0 || remaining <= sink.maxFiles) && (sink.maxTotalBytes <= 0 || totalBytes <= sink.maxTotalBytes) {
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("[ERROR] Failed to remove rotated file: %s.\nERROR:\n%v", path, err)
+			continue
+		}
+		totalBytes -= sizes[pathIndex]
+	}
+}
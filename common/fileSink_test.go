@@ -0,0 +1,200 @@
+package common
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readNDJSONFile returns the lines of an NDJSON file, decompressing gzip files
+func readNDJSONFile(t *testing.T, path string) (lines []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+	var scanner *bufio.Scanner
+	if strings.HasSuffix(path, ".gz") {
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to decompress file: %s: %v", path, err)
+		}
+		scanner = bufio.NewScanner(reader)
+	} else {
+		scanner = bufio.NewScanner(file)
+	}
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// newTestFileSink creates a file sink in a temporary directory
+func newTestFileSink(t *testing.T, config FileSinkConfig) *FileSink {
+	if config.Directory == "" {
+		config.Directory = t.TempDir()
+	}
+	sink, err := NewFileSink(SinkConfig{File: &config})
+	if err != nil {
+		t.Fatalf("Failed to create file sink: %v", err)
+	}
+	return sink.(*FileSink)
+}
+
+// TestFileSinkWrite tests writing event logs as NDJSON lines, and appending to an existing file
+func TestFileSinkWrite(t *testing.T) {
+	directory := t.TempDir()
+	for run := 0; run < 2; run++ {
+		sink := newTestFileSink(t, FileSinkConfig{Directory: directory})
+		for index := 0; index < 3; index++ {
+			if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", fmt.Sprintf("web-%d", index), "default", nil)); err != nil {
+				t.Fatalf("Failed to send event log: %v", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Failed to close file sink: %v", err)
+		}
+	}
+
+	lines := readNDJSONFile(t, filepath.Join(directory, DefaultFileSinkBaseName+".ndjson"))
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 appended lines, got %d", len(lines))
+	}
+	if lines[4] != string(getTestSinkEvent(EventTypeModified, "Deployment", "web-1", "default", nil).JSON) {
+		t.Errorf("Expected the JSON event log, got %s", lines[4])
+	}
+}
+
+// TestFileSinkRotation tests rotating by size and age, compressing rotated files and limiting their retention
+func TestFileSinkRotation(t *testing.T) {
+	eventBytes := int64(len(getTestSinkEvent(EventTypeModified, "Deployment", "web-0", "default", nil).JSON) + 1)
+	tests := []struct {
+		name          string
+		config        FileSinkConfig
+		events        int
+		wait          time.Duration
+		expectedFiles int
+		expectedLines int
+	}{
+		{name: "size", config: FileSinkConfig{MaxFileBytes: 2 * eventBytes}, events: 6, expectedFiles: 2, expectedLines: 6},
+		{name: "age", config: FileSinkConfig{RotateInterval: metav1.Duration{Duration: time.Millisecond}}, events: 3, wait: 2 * time.Millisecond, expectedFiles: 2, expectedLines: 3},
+		{name: "compressed", config: FileSinkConfig{MaxFileBytes: eventBytes, Compress: true}, events: 4, expectedFiles: 3, expectedLines: 4},
+		{name: "max files", config: FileSinkConfig{MaxFileBytes: eventBytes, MaxFiles: 2}, events: 6, expectedFiles: 2, expectedLines: 3},
+		{name: "max total bytes", config: FileSinkConfig{MaxFileBytes: eventBytes, MaxTotalBytes: 3 * eventBytes}, events: 6, expectedFiles: 3, expectedLines: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := newTestFileSink(t, test.config)
+			for index := 0; index < test.events; index++ {
+				if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", fmt.Sprintf("web-%d", index), "default", nil)); err != nil {
+					t.Fatalf("Failed to send event log: %v", err)
+				}
+				time.Sleep(test.wait)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Failed to close file sink: %v", err)
+			}
+
+			rotated := sink.rotatedFiles()
+			if len(rotated) != test.expectedFiles {
+				t.Fatalf("Expected %d rotated files, got %v", test.expectedFiles, rotated)
+			}
+			lines := readNDJSONFile(t, sink.activePath())
+			for _, path := range rotated {
+				if test.config.Compress != strings.HasSuffix(path, ".gz") {
+					t.Errorf("Expected compressed rotated files: %t, got %s", test.config.Compress, path)
+				}
+				lines = append(lines, readNDJSONFile(t, path)...)
+			}
+			if len(lines) != test.expectedLines {
+				t.Errorf("Expected %d retained lines, got %d", test.expectedLines, len(lines))
+			}
+		})
+	}
+}
+
+// TestFileSinkRotationFailure tests recovering from a failed rotation with the next event log
+func TestFileSinkRotationFailure(t *testing.T) {
+	event := getTestSinkEvent(EventTypeModified, "Deployment", "web-0", "default", nil)
+	sink := newTestFileSink(t, FileSinkConfig{MaxFileBytes: int64(len(event.JSON) + 1)})
+	defer sink.Close()
+	if err := sink.Send(event); err != nil {
+		t.Fatalf("Failed to send event log: %v", err)
+	}
+
+	// The rename of the rotation fails once the active file is removed
+	os.Remove(sink.activePath())
+	if err := sink.Send(event); err == nil || sink.Health() == nil {
+		t.Fatal("Expected an error of the failed rotation")
+	}
+	for run := 0; run < 2; run++ {
+		if err := sink.Send(event); err != nil || sink.Health() != nil {
+			t.Fatalf("Expected the sink to recover after the failed rotation, got %v", err)
+		}
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Failed to flush file sink: %v", err)
+	}
+	if rotated := sink.rotatedFiles(); len(rotated) != 1 || len(readNDJSONFile(t, sink.activePath())) != 1 {
+		t.Errorf("Expected the reopened file to be rotated, got %v", rotated)
+	}
+}
+
+// TestFileSinkRotationInterval tests rotating an idle active file by age on the sync interval
+func TestFileSinkRotationInterval(t *testing.T) {
+	sink := newTestFileSink(t, FileSinkConfig{
+		RotateInterval: metav1.Duration{Duration: 10 * time.Millisecond},
+		SyncInterval:   metav1.Duration{Duration: 5 * time.Millisecond},
+	})
+	defer sink.Close()
+	if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web-0", "default", nil)); err != nil {
+		t.Fatalf("Failed to send event log: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(sink.rotatedFiles()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the idle file to be rotated")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rotated := sink.rotatedFiles(); len(rotated) != 1 || len(readNDJSONFile(t, rotated[0])) != 1 {
+		t.Errorf("Expected the event log in a single rotated file, got %v", rotated)
+	}
+}
+
+// TestFileSinkSync tests syncing written event logs to disk on an interval, without flushing the sink
+func TestFileSinkSync(t *testing.T) {
+	sink := newTestFileSink(t, FileSinkConfig{SyncInterval: metav1.Duration{Duration: 5 * time.Millisecond}})
+	defer sink.Close()
+	if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web-0", "default", nil)); err != nil {
+		t.Fatalf("Failed to send event log: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(readNDJSONFile(t, sink.activePath())) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the event log to be synced")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestNewFileSink tests the validation of the file sink configuration
+func TestNewFileSink(t *testing.T) {
+	if _, err := NewFileSink(SinkConfig{}); err == nil {
+		t.Error("Expected an error without a directory")
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0o644)
+	if _, err := NewFileSink(SinkConfig{File: &FileSinkConfig{Directory: file}}); err == nil {
+		t.Error("Expected an error of a directory that is a file")
+	}
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// informerHealth is the state of the informer of a watched resource
+type informerHealth struct {
+	isCreated     bool
+	isStopped     bool
+	createErr     string
+	hasSynced     func() bool
+	lastHeartbeat time.Time
+}
+
+// informerHealths are the informers of the watched resources, by resource
+var informerHealths = map[schema.GroupVersionResource]*informerHealth{}
+var informerHealthsMux sync.Mutex
+
+// isInformersExpected is set once the watched resources are known, so the collector isn't ready before its informers are created
+var isInformersExpected bool
+
+// HealthCheck is the result of a check of a health probe
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// resourceAPIName returns the name of a resource in health checks, as in the informer logs
+func resourceAPIName(gvr schema.GroupVersionResource) string {
+	return fmt.Sprintf("%s/%s/%s", gvr.Group, gvr.Version, gvr.Resource)
+}
+
+// ExpectInformers records the watched resources, whose informers must be created and synced for the collector to be ready
+func ExpectInformers(gvrs []schema.GroupVersionResource) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	isInformersExpected = true
+	for _, gvr := range gvrs {
+		if _, ok := informerHealths[gvr]; !ok {
+			informerHealths[gvr] = &informerHealth{}
+		}
+	}
+}
+
+// InformerCreated records the informer of a resource and the function reporting whether its cache synced
+func InformerCreated(gvr schema.GroupVersionResource, hasSynced func() bool) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	informerHealths[gvr] = &informerHealth{isCreated: true, hasSynced: hasSynced, lastHeartbeat: time.Now()}
+}
+
+// InformerNotCreated records that the informer of a resource failed to be created
+func InformerNotCreated(gvr schema.GroupVersionResource, reason string) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	informerHealths[gvr] = &informerHealth{createErr: reason}
+}
+
+// InformerHeartbeat records that the watch loop of the informer of a resource listed or watched the resource
+func InformerHeartbeat(gvr schema.GroupVersionResource) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	if health, ok := informerHealths[gvr]; ok {
+		health.lastHeartbeat = time.Now()
+	}
+}
+
+// InformerStopped records that the informer of a resource stopped running
+func InformerStopped(gvr schema.GroupVersionResource) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	if health, ok := informerHealths[gvr]; ok {
+		health.isStopped = true
+	}
+}
+
+// sortedInformerResources returns the resources of the informers, sorted by name
+func sortedInformerResources() []schema.GroupVersionResource {
+	gvrs := make([]schema.GroupVersionResource, 0, len(informerHealths))
+	for gvr := range informerHealths {
+		gvrs = append(gvrs, gvr)
+	}
+	slices.SortFunc(gvrs, func(a, b schema.GroupVersionResource) int {
+		return strings.Compare(resourceAPIName(a), resourceAPIName(b))
+	})
+	return gvrs
+}
+
+// Readiness checks that the informer of every watched resource was created and synced its cache, and that no sink
+// has been failing for longer than the failure threshold
+func Readiness(sinkFailureThreshold time.Duration) (checks []HealthCheck) {
+	informerHealthsMux.Lock()
+	if !isInformersExpected {
+		checks = append(checks, HealthCheck{Name: "informers", Reason: "the watched resources aren't known yet"})
+	}
+	for _, gvr := range sortedInformerResources() {
+		health := informerHealths[gvr]
+		check := HealthCheck{Name: "informer " + resourceAPIName(gvr)}
+		switch {
+		case health.createErr != "":
+			check.Reason = "the informer was not created: " + health.createErr
+		case !health.isCreated:
+			check.Reason = "the informer was not created yet"
+		case health.hasSynced == nil || !health.hasSynced():
+			check.Reason = "the informer cache has not synced"
+		default:
+			check.Healthy = true
+		}
+		checks = append(checks, check)
+	}
+	informerHealthsMux.Unlock()
+
+	for _, status := range SinkStatuses() {
+		check := HealthCheck{Name: "sink " + status.Name, Healthy: true}
+		if status.FailingSince != nil && time.Since(*status.FailingSince) > sinkFailureThreshold {
+			check.Healthy = false
+			check.Reason = fmt.Sprintf("failing since %s: %s", status.FailingSince.UTC().Format(time.RFC3339), status.LastError)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// Liveness checks that the watch loop of every created informer is running, and listed or watched its resource within
+// the stall threshold. Watches are reopened every few minutes, so a watch loop without requests is stalled.
+func Liveness(watchStallThreshold time.Duration) (checks []HealthCheck) {
+	informerHealthsMux.Lock()
+	defer informerHealthsMux.Unlock()
+	for _, gvr := range sortedInformerResources() {
+		health := informerHealths[gvr]
+		if !health.isCreated {
+			// Informers that weren't created have no watch loop, and fail the readiness probe
+			continue
+		}
+		check := HealthCheck{Name: "watch " + resourceAPIName(gvr), Healthy: true}
+		if health.isStopped {
+			check.Healthy = false
+			check.Reason = "the informer stopped"
+		} else if stalled := time.Since(health.lastHeartbeat); stalled > watchStallThreshold {
+			check.Healthy = false
+			check.Reason = fmt.Sprintf("no list or watch request for %s", stalled.Round(time.Second))
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// HealthHandler serves the checks of a health probe, with status 200 if all checks are healthy and 503 otherwise
+func HealthHandler(probe string, checks func() []HealthCheck) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var body strings.Builder
+		isHealthy := true
+		for _, check := range checks() {
+			if check.Healthy {
+				fmt.Fprintf(&body, "[+]%s ok\n", check.Name)
+			} else {
+				isHealthy = false
+				fmt.Fprintf(&body, "[-]%s failed: %s\n", check.Name, check.Reason)
+			}
+		}
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writer.Header().Set("X-Content-Type-Options", "nosniff")
+		if !isHealthy {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(&body, "%s check failed\n", probe)
+		} else {
+			fmt.Fprintf(&body, "%s check passed\n", probe)
+		}
+		writer.Write([]byte(body.String()))
+	})
+}
+
+// StartHealthServer starts the HTTP liveness and readiness probes, if they are enabled in the configuration.
+// The probes are served by the metrics endpoint if it has the same address.
+func StartHealthServer() {
+	config := Config.Health
+	if config == nil {
+		return
+	}
+
+	address := config.Address
+	if address == "" {
+		address = DefaultHealthAddress
+	}
+	sinkFailureThreshold := config.SinkFailureThreshold.Duration
+	if sinkFailureThreshold <= 0 {
+		sinkFailureThreshold = DefaultSinkFailureThreshold
+	}
+	watchStallThreshold := config.WatchStallThreshold.Duration
+	if watchStallThreshold <= 0 {
+		watchStallThreshold = DefaultWatchStallThreshold
+	}
+	mux := observabilityMux(address)
+	mux.Handle(LivenessPath, HealthHandler("healthz", func() []HealthCheck { return Liveness(watchStallThreshold) }))
+	mux.Handle(ReadinessPath, HealthHandler("readyz", func() []HealthCheck { return Readiness(sinkFailureThreshold) }))
+}
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resetInformerHealths clears the recorded informers during a test
+func resetInformerHealths(t *testing.T) {
+	reset := func() {
+		informerHealthsMux.Lock()
+		defer informerHealthsMux.Unlock()
+		informerHealths = map[schema.GroupVersionResource]*informerHealth{}
+		isInformersExpected = false
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// failedChecks returns the reasons of the failed checks by name
+func failedChecks(checks []HealthCheck) map[string]string {
+	failed := map[string]string{}
+	for _, check := range checks {
+		if !check.Healthy {
+			failed[check.Name] = check.Reason
+		}
+	}
+	return failed
+}
+
+// TestReadinessInformers tests that the collector is ready once the informer of every watched resource is created and synced
+func TestReadinessInformers(t *testing.T) {
+	resetInformerHealths(t)
+	if failed := failedChecks(Readiness(time.Minute)); failed["informers"] == "" {
+		t.Errorf("Expected the collector not to be ready before the watched resources are known, got %v", failed)
+	}
+
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	rollouts := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	ExpectInformers([]schema.GroupVersionResource{deployments, secrets, rollouts})
+	isSynced := false
+	InformerCreated(deployments, func() bool { return isSynced })
+	InformerNotCreated(rollouts, "failed to create informer")
+
+	failed := failedChecks(Readiness(time.Minute))
+	expected := map[string]string{
+		"informer apps/v1/deployments":           "the informer cache has not synced",
+		"informer /v1/secrets":                   "the informer was not created yet",
+		"informer argoproj.io/v1alpha1/rollouts": "the informer was not created: failed to create informer",
+	}
+	for name, reason := range expected {
+		if failed[name] != reason {
+			t.Errorf("Expected check: %s to fail with: %s, got: %s", name, reason, failed[name])
+		}
+	}
+
+	isSynced = true
+	InformerCreated(secrets, func() bool { return true })
+	InformerCreated(rollouts, func() bool { return true })
+	if failed = failedChecks(Readiness(time.Minute)); len(failed) != 0 {
+		t.Errorf("Expected the collector to be ready, got %v", failed)
+	}
+}
+
+// TestReadinessSinks tests that the collector isn't ready when a sink fails for longer than the threshold
+func TestReadinessSinks(t *testing.T) {
+	resetInformerHealths(t)
+	ExpectInformers(nil)
+	t.Cleanup(func() { CloseSinks(time.Second) })
+	sink := &testSink{sendErr: errors.New("unavailable")}
+	AddSink("health-test", "test", sink, SinkFilter{}, 0)
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+	for sinkStatus(t, "health-test").FailingSince == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	if failed := failedChecks(Readiness(time.Hour)); len(failed) != 0 {
+		t.Errorf("Expected a sink failing within the threshold to be ready, got %v", failed)
+	}
+	if failed := failedChecks(Readiness(0)); !strings.HasSuffix(failed["sink health-test"], ": unavailable") {
+		t.Errorf("Expected the failing sink to fail the readiness, got %v", failed)
+	}
+
+	// A successful request resets the failure
+	sink.mux.Lock()
+	sink.sendErr = nil
+	sink.mux.Unlock()
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+	for sinkStatus(t, "health-test").FailingSince != nil {
+		time.Sleep(time.Millisecond)
+	}
+	if failed := failedChecks(Readiness(0)); len(failed) != 0 {
+		t.Errorf("Expected the recovered sink to be ready, got %v", failed)
+	}
+}
+
+// TestLiveness tests detecting stopped and stalled watch loops of informers
+func TestLiveness(t *testing.T) {
+	resetInformerHealths(t)
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	InformerCreated(deployments, func() bool { return true })
+	InformerCreated(secrets, func() bool { return true })
+	InformerNotCreated(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "failed to create informer")
+
+	if checks := Liveness(time.Hour); len(checks) != 2 || len(failedChecks(checks)) != 0 {
+		t.Errorf("Expected the watch loops of the created informers to be live, got %+v", checks)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	InformerHeartbeat(deployments)
+	InformerStopped(secrets)
+	failed := failedChecks(Liveness(5 * time.Millisecond))
+	if len(failed) != 1 || failed["watch /v1/secrets"] != "the informer stopped" {
+		t.Errorf("Expected only the stopped informer to fail, got %v", failed)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if failed = failedChecks(Liveness(5 * time.Millisecond)); !strings.HasPrefix(failed["watch apps/v1/deployments"], "no list or watch request for") {
+		t.Errorf("Expected the stalled watch loop to fail, got %v", failed)
+	}
+}
+
+// TestHealthHandler tests serving the checks of a probe with the status of the checks
+func TestHealthHandler(t *testing.T) {
+	checks := []HealthCheck{{Name: "watch apps/v1/deployments", Healthy: true}}
+	server := httptest.NewServer(HealthHandler("readyz", func() []HealthCheck { return checks }))
+	defer server.Close()
+	get := func() (int, string) {
+		response, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Failed to get probe: %v", err)
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	if status, body := get(); status != http.StatusOK || body != "[+]watch apps/v1/deployments ok\nreadyz check passed\n" {
+		t.Errorf("Unexpected healthy response: %d %q", status, body)
+	}
+	checks = append(checks, HealthCheck{Name: "sink logzio", Reason: "failing"})
+	if status, body := get(); status != http.StatusServiceUnavailable || !strings.Contains(body, "[-]sink logzio failed: failing\nreadyz check failed\n") {
+		t.Errorf("Unexpected unhealthy response: %d %q", status, body)
+	}
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sinkInitialBackoff and sinkMaxBackoff bound the exponential backoff between retries of a sink request
+var sinkInitialBackoff = time.Second
+var sinkMaxBackoff = 30 * time.Second
+
+// httpSinkError is a failed request of an HTTP sink
+type httpSinkError struct {
+	statusCode int
+	message    string
+	retryable  bool
+	retryAfter time.Duration
+}
+
+// Error returns the status and message of the failed request
+func (err *httpSinkError) Error() string {
+	if err.statusCode == 0 {
+		return fmt.Sprintf("request failed: %s", err.message)
+	}
+	return fmt.Sprintf("request failed with status: %d: %s", err.statusCode, err.message)
+}
+
+// newHTTPSinkError returns the error of a failed response, throttled and unavailable responses are retryable
+// after the delay of their Retry-After header
+func newHTTPSinkError(response *http.Response, message string) *httpSinkError {
+	err := &httpSinkError{statusCode: response.StatusCode, message: message}
+	if isRetryableStatus(response.StatusCode) {
+		err.retryable = true
+		err.retryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+	}
+	return err
+}
+
+// isRetryableStatus checks whether a status is of a throttled or temporarily unavailable request
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newNetworkSinkError returns the error of a request that got no response, which is retryable
+func newNetworkSinkError(err error) *httpSinkError {
+	return &httpSinkError{message: err.Error(), retryable: true}
+}
+
+// parseRetryAfter parses a Retry-After header of delay seconds or an HTTP date, or returns 0
+func parseRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// retryWithBackoff sends a request until it succeeds or fails with an error that isn't retryable. Throttled requests wait
+// as long as the server asks, other retries wait an exponential backoff with jitter, until the maximal elapsed time.
+func retryWithBackoff(sinkName string, maxElapsedTime time.Duration, request func() error) error {
+	start := time.Now()
+	backoff := sinkInitialBackoff
+	for {
+		err := request()
+		if err == nil {
+			return nil
+		}
+		sinkErr, _ := err.(*httpSinkError)
+		if sinkErr == nil || !sinkErr.retryable {
+			return err
+		}
+
+		wait := sinkErr.retryAfter
+		if wait <= 0 {
+			wait = backoff/2 + rand.N(backoff/2+1)
+			backoff = min(backoff*2, sinkMaxBackoff)
+		}
+		if time.Since(start)+wait > maxElapsedTime {
+			return fmt.Errorf("retried for %s: %w", time.Since(start).Round(time.Millisecond), err)
+		}
+		log.Printf("[ERROR] Failed to send request of sink: %s, retrying in %s.\nERROR:\n%v", sinkName, wait, err)
+		time.Sleep(wait)
+	}
+}
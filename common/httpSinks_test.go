@@ -0,0 +1,172 @@
+package common
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHTTPRequest is a request received by the test HTTP receiver, with its decompressed body
+type testHTTPRequest struct {
+	method  string
+	path    string
+	headers http.Header
+	body    []byte
+	queued  bool // responded with a queued status code
+}
+
+// testHTTPReceiver is an in-process HTTP receiver of the sinks, which records the requests and responds with the queued
+// status codes before passing the requests to its handler
+type testHTTPReceiver struct {
+	mux       sync.Mutex
+	server    *httptest.Server
+	requests  []testHTTPRequest
+	responses []int
+	handle    func(writer http.ResponseWriter, request testHTTPRequest)
+}
+
+// newTestHTTPReceiver starts a test HTTP receiver, whose handler is called with the receiver locked. Without a handler,
+// requests succeed with an empty response.
+func newTestHTTPReceiver(t *testing.T, handle func(writer http.ResponseWriter, request testHTTPRequest), responses ...int) *testHTTPReceiver {
+	receiver := &testHTTPReceiver{responses: responses, handle: handle}
+	receiver.server = httptest.NewServer(http.HandlerFunc(receiver.serve))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+// serve records a request, and responds with the next queued status code or passes the request to the handler
+func (receiver *testHTTPReceiver) serve(writer http.ResponseWriter, request *http.Request) {
+	receiver.mux.Lock()
+	defer receiver.mux.Unlock()
+	reader := io.Reader(request.Body)
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(request.Body)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader = gzipReader
+	}
+	body, _ := io.ReadAll(reader)
+	received := testHTTPRequest{method: request.Method, path: request.URL.Path, headers: request.Header, body: body}
+	receiver.requests = append(receiver.requests, received)
+
+	if len(receiver.responses) > 0 {
+		receiver.requests[len(receiver.requests)-1].queued = true
+		status := receiver.responses[0]
+		receiver.responses = receiver.responses[1:]
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		writer.Write([]byte(`{"code":14,"message":"receiver unavailable"}`))
+		return
+	}
+	if receiver.handle != nil {
+		receiver.handle(writer, received)
+	}
+}
+
+// received returns the requests received
+func (receiver *testHTTPReceiver) received() []testHTTPRequest {
+	receiver.mux.Lock()
+	defer receiver.mux.Unlock()
+	return receiver.requests
+}
+
+// mergeTestFields merges fields into a map of fields, merging nested maps
+func mergeTestFields(fields map[string]interface{}, extraFields map[string]interface{}) {
+	for key, value := range extraFields {
+		nested, isMap := value.(map[string]interface{})
+		existing, existingIsMap := fields[key].(map[string]interface{})
+		if isMap && existingIsMap {
+			mergeTestFields(existing, nested)
+			continue
+		}
+		fields[key] = value
+	}
+}
+
+// testObjectVersion returns the extra fields of an event log of an object version
+func testObjectVersion(resourceVersion string) map[string]interface{} {
+	return map[string]interface{}{"newObject": map[string]interface{}{Metadata: map[string]interface{}{ResourceVersion: resourceVersion}}}
+}
+
+// getTestSinkEvent returns the event log of a resource event with its JSON document. The object has a uid derived
+// from its name, and the extra fields are merged into the event log, such as the metadata of "newObject".
+func getTestSinkEvent(eventType string, kind string, name string, namespace string, extraFields map[string]interface{}) SinkEvent {
+	fields := map[string]interface{}{
+		"message":   fmt.Sprintf("[EVENT] Resource: %s of kind: %s in namespace: %s was %s.", name, kind, namespace, eventType),
+		"eventType": eventType,
+		"type":      DefaultLogType,
+		"newObject": map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			Metadata: map[string]interface{}{
+				"name":          name,
+				"namespace":     namespace,
+				"uid":           name + "-uid",
+				ResourceVersion: "1",
+			},
+		},
+	}
+	mergeTestFields(fields, extraFields)
+	eventJSON, _ := json.Marshal(fields)
+	return SinkEvent{Fields: fields, JSON: eventJSON}
+}
+
+// withSinkBackoff shortens the backoff between retries of sink requests
+func withSinkBackoff(t *testing.T) {
+	initialBackoff, maxBackoff := sinkInitialBackoff, sinkMaxBackoff
+	sinkInitialBackoff, sinkMaxBackoff = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() {
+		sinkInitialBackoff, sinkMaxBackoff = initialBackoff, maxBackoff
+	})
+}
+
+// TestParseRetryAfter tests parsing Retry-After headers of throttled requests
+func TestParseRetryAfter(t *testing.T) {
+	if parseRetryAfter("3") != 3*time.Second || parseRetryAfter("") != 0 || parseRetryAfter("soon") != 0 {
+		t.Error("Unexpected Retry-After delay")
+	}
+	retryAfter := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if retryAfter <= 50*time.Second || retryAfter > time.Minute {
+		t.Errorf("Expected a delay of about a minute, got %s", retryAfter)
+	}
+}
+
+// TestRetryWithBackoff tests retrying requests until they succeed, fail with an error that isn't retryable, or time out
+func TestRetryWithBackoff(t *testing.T) {
+	withSinkBackoff(t)
+	tests := []struct {
+		name             string
+		errs             []error
+		expectedRequests int
+		expectError      bool
+	}{
+		{name: "retryable", errs: []error{newNetworkSinkError(errors.New("connection refused")), &httpSinkError{statusCode: http.StatusServiceUnavailable, retryable: true}}, expectedRequests: 3},
+		{name: "not retryable", errs: []error{&httpSinkError{statusCode: http.StatusBadRequest}}, expectedRequests: 1, expectError: true},
+		{name: "other error", errs: []error{errors.New("invalid request")}, expectedRequests: 1, expectError: true},
+		{name: "retry after", errs: []error{&httpSinkError{statusCode: http.StatusTooManyRequests, retryable: true, retryAfter: time.Hour}}, expectedRequests: 1, expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			err := retryWithBackoff(test.name, time.Second, func() error {
+				requests++
+				if requests <= len(test.errs) {
+					return test.errs[requests-1]
+				}
+				return nil
+			})
+			if (err != nil) != test.expectError || requests != test.expectedRequests {
+				t.Errorf("Expected %d requests and error: %t, got %d requests and %v", test.expectedRequests, test.expectError, requests, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// metricsDurationBuckets are the upper bounds of the buckets of duration histograms, in seconds
+var metricsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector metrics, registered with the default Prometheus registry
+var (
+	// EventsObserved counts the informer events of each resource, including events that are suppressed
+	EventsObserved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "logzio_k8s_events_observed_total",
+		Help: "Informer events observed, by resource and event type.",
+	}, []string{"group", "version", "resource", "event_type"})
+	// EventsSuppressed counts the updates that only changed internal or ignored fields
+	EventsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "logzio_k8s_events_suppressed_total",
+		Help: "Informer events suppressed as internal or ignored changes, by resource and event type.",
+	}, []string{"group", "version", "resource", "event_type"})
+	// EventsShipped counts the event logs each sink accepted, batching sinks accept event logs into their batches
+	EventsShipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "logzio_k8s_events_shipped_total",
+		Help: "Event logs accepted by each sink, by resource and event type.",
+	}, []string{"sink", "group", "version", "resource", "event_type"})
+	// LastEventTimestamp is when the last informer event of each resource was observed
+	LastEventTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "logzio_k8s_events_last_event_timestamp_seconds",
+		Help: "Unix time of the last informer event observed, by resource.",
+	}, []string{"group", "version", "resource"})
+	// InformerSynced is whether the informer of each resource synced its cache
+	InformerSynced = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "logzio_k8s_events_informer_synced",
+		Help: "Whether the informer of the resource synced its cache, 1 or 0.",
+	}, []string{"group", "version", "resource"})
+	// RelatedResourcesDuration is the latency of looking up the related cluster resources of an event
+	RelatedResourcesDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "logzio_k8s_events_related_resources_duration_seconds",
+		Help:    "Latency of looking up the related cluster resources of events, by kind.",
+		Buckets: metricsDurationBuckets,
+	}, []string{"kind"})
+)
+
+// ObserveEvent counts an informer event of a resource and records its time
+func ObserveEvent(gvr schema.GroupVersionResource, eventType string) {
+	EventsObserved.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, eventType).Inc()
+	LastEventTimestamp.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).SetToCurrentTime()
+}
+
+// SuppressEvent counts an informer event of a resource that isn't sent
+func SuppressEvent(gvr schema.GroupVersionResource, eventType string) {
+	EventsSuppressed.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, eventType).Inc()
+}
+
+// SetInformerSynced records whether the informer of a resource synced its cache
+func SetInformerSynced(gvr schema.GroupVersionResource, synced bool) {
+	value := 0.0
+	if synced {
+		value = 1
+	}
+	InformerSynced.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).Set(value)
+}
+
+// shipEvent counts an event log sent by a sink, by the resource of its object
+func shipEvent(sinkName string, event SinkEvent) {
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	apiVersion, _ := newObject["apiVersion"].(string)
+	var resource string
+	if kind := event.Kind(); kind != "" {
+		resource = KindToResource(kind)
+	}
+	groupVersion, _ := schema.ParseGroupVersion(apiVersion)
+	EventsShipped.WithLabelValues(sinkName, groupVersion.Group, groupVersion.Version, resource, event.EventType()).Inc()
+}
+
+// sinkCollector collects the sink metrics from the sink statuses when the metrics are served
+type sinkCollector struct{}
+
+// Descriptions of the sink metrics
+var (
+	sinkFailuresDesc   = prometheus.NewDesc("logzio_k8s_events_sink_failures_total", "Event logs that a sink failed to send.", []string{"sink", "type"}, nil)
+	sinkDroppedDesc    = prometheus.NewDesc("logzio_k8s_events_sink_dropped_total", "Event logs dropped because the queue of the sink was full.", []string{"sink", "type"}, nil)
+	sinkQueueDepthDesc = prometheus.NewDesc("logzio_k8s_events_sink_queue_depth", "Event logs queued for a sink.", []string{"sink", "type"}, nil)
+	sinkHealthyDesc    = prometheus.NewDesc("logzio_k8s_events_sink_healthy", "Whether the last request of a sink succeeded, 1 or 0.", []string{"sink", "type"}, nil)
+)
+
+// init registers the collector of the sink metrics
+func init() {
+	prometheus.MustRegister(sinkCollector{})
+}
+
+// Describe sends the descriptions of the sink metrics
+func (sinkCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- sinkFailuresDesc
+	descs <- sinkDroppedDesc
+	descs <- sinkQueueDepthDesc
+	descs <- sinkHealthyDesc
+}
+
+// Collect sends the sink metrics of the current sink statuses
+func (sinkCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, status := range SinkStatuses() {
+		healthy := 0.0
+		if status.Healthy {
+			healthy = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(sinkFailuresDesc, prometheus.CounterValue, float64(status.Failed), status.Name, status.Type)
+		metrics <- prometheus.MustNewConstMetric(sinkDroppedDesc, prometheus.CounterValue, float64(status.Dropped), status.Name, status.Type)
+		metrics <- prometheus.MustNewConstMetric(sinkQueueDepthDesc, prometheus.GaugeValue, float64(status.Queued), status.Name, status.Type)
+		metrics <- prometheus.MustNewConstMetric(sinkHealthyDesc, prometheus.GaugeValue, healthy, status.Name, status.Type)
+	}
+}
+
+// StartMetricsServer starts the HTTP metrics endpoint, if it is enabled in the configuration
+func StartMetricsServer() {
+	config := Config.Metrics
+	if config == nil {
+		return
+	}
+
+	address, path := config.Address, config.Path
+	if address == "" {
+		address = DefaultMetricsAddress
+	}
+	if path == "" {
+		path = DefaultMetricsPath
+	}
+	observabilityMux(address).Handle(path, promhttp.Handler())
+}
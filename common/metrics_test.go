@@ -0,0 +1,89 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TestEventMetrics tests counting observed, suppressed and shipped events by resource and event type
+func TestEventMetrics(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	observed := testutil.ToFloat64(EventsObserved.WithLabelValues("apps", "v1", "deployments", EventTypeModified))
+	suppressed := testutil.ToFloat64(EventsSuppressed.WithLabelValues("apps", "v1", "deployments", EventTypeModified))
+	ObserveEvent(gvr, EventTypeModified)
+	SuppressEvent(gvr, EventTypeModified)
+	if testutil.ToFloat64(EventsObserved.WithLabelValues("apps", "v1", "deployments", EventTypeModified)) != observed+1 ||
+		testutil.ToFloat64(EventsSuppressed.WithLabelValues("apps", "v1", "deployments", EventTypeModified)) != suppressed+1 {
+		t.Error("Expected the observed and suppressed events to be counted")
+	}
+	if timestamp := testutil.ToFloat64(LastEventTimestamp.WithLabelValues("apps", "v1", "deployments")); time.Since(time.Unix(int64(timestamp), 0)) > time.Minute {
+		t.Errorf("Expected the time of the last event, got %v", timestamp)
+	}
+
+	SetInformerSynced(gvr, true)
+	if testutil.ToFloat64(InformerSynced.WithLabelValues("apps", "v1", "deployments")) != 1 {
+		t.Error("Expected the informer to be synced")
+	}
+
+	event := SinkEvent{Fields: map[string]interface{}{
+		"eventType": EventTypeAdded,
+		"newObject": map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"},
+	}}
+	shipped := testutil.ToFloat64(EventsShipped.WithLabelValues("metrics", "", "v1", "configmaps", EventTypeAdded))
+	shipEvent("metrics", event)
+	if testutil.ToFloat64(EventsShipped.WithLabelValues("metrics", "", "v1", "configmaps", EventTypeAdded)) != shipped+1 {
+		t.Error("Expected the shipped event to be counted by its resource")
+	}
+}
+
+// TestSinkCollector tests serving the metrics with the statuses of the sinks
+func TestSinkCollector(t *testing.T) {
+	t.Cleanup(func() { CloseSinks(time.Second) })
+	sink := &testSink{}
+	AddSink("metrics-test", "test", sink, SinkFilter{}, 0)
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+	// The event log is counted as shipped before it is counted as sent
+	for sinkStatus(t, "metrics-test").Sent == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	RelatedResourcesDuration.WithLabelValues("Deployment").Observe(0.02)
+
+	server := httptest.NewServer(promhttp.Handler())
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %s", response.Header.Get("Content-Type"))
+	}
+	for _, expected := range []string{
+		`logzio_k8s_events_sink_healthy{sink="metrics-test",type="test"} 1`,
+		`logzio_k8s_events_sink_queue_depth{sink="metrics-test",type="test"} 0`,
+		`logzio_k8s_events_sink_failures_total{sink="metrics-test",type="test"} 0`,
+		`logzio_k8s_events_sink_dropped_total{sink="metrics-test",type="test"} 0`,
+		`logzio_k8s_events_shipped_total{event_type="ADDED",group="",resource="",sink="metrics-test",version=""}`,
+		"# TYPE logzio_k8s_events_related_resources_duration_seconds histogram",
+		`logzio_k8s_events_related_resources_duration_seconds_bucket{kind="Deployment",le="0.025"}`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected the metrics to contain %s, got:\n%s", expected, body)
+		}
+	}
+
+	// The sink metrics of a removed sink aren't served
+	CloseSinks(time.Second)
+	if count := testutil.CollectAndCount(sinkCollector{}); count != 0 {
+		t.Errorf("Expected no sink metrics without sinks, got %d", count)
+	}
+}
@@ -0,0 +1,493 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP request encodings
+const (
+	OTLPEncodingProtobuf = "protobuf"
+	OTLPEncodingJSON     = "json"
+)
+
+// OpenTelemetry severity numbers of the event logs
+const (
+	otlpSeverityInfo = 9
+	otlpSeverityWarn = 13
+)
+
+// otlpScopeName is the instrumentation scope of the exported log records
+const otlpScopeName = "logzio-k8s-events"
+
+// otlpSemanticKinds are the kinds with OpenTelemetry semantic convention resource attributes, as k8s.<kind>.name and k8s.<kind>.uid
+var otlpSemanticKinds = []string{"Pod", "ReplicaSet", "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob", "Node", "Namespace"}
+
+// otlpRecord is a log record of the OpenTelemetry log data model, with the attributes of its resource
+type otlpRecord struct {
+	timeUnixNano       uint64
+	severityNumber     int32
+	severityText       string
+	body               string
+	attributes         map[string]interface{}
+	resourceAttributes map[string]interface{}
+}
+
+// otlpResourceLogs are the log records of a resource
+type otlpResourceLogs struct {
+	attributes map[string]interface{}
+	records    []otlpRecord
+}
+
+// OTLPSink exports event logs to an OpenTelemetry OTLP/HTTP logs endpoint, in batches
+type OTLPSink struct {
+	name           string
+	client         *http.Client
+	endpoint       string
+	encoding       string
+	compression    string
+	headers        map[string]string
+	maxBatchSize   int
+	maxElapsedTime time.Duration
+	batchMux       sync.Mutex
+	batch          []otlpRecord
+	mux            sync.Mutex
+	lastErr        error
+}
+
+// init registers the OTLP sink type
+func init() {
+	RegisterSinkType(SinkTypeOTLP, NewOTLPSink)
+}
+
+// NewOTLPSink creates an OTLP/HTTP logs exporter sink
+func NewOTLPSink(config SinkConfig) (Sink, error) {
+	var sinkConfig OTLPSinkConfig
+	if config.OTLP != nil {
+		sinkConfig = *config.OTLP
+	}
+	sink := &OTLPSink{
+		name:           config.Name,
+		endpoint:       sinkConfig.Endpoint,
+		encoding:       sinkConfig.Encoding,
+		compression:    sinkConfig.Compression,
+		headers:        sinkConfig.Headers,
+		maxBatchSize:   sinkConfig.MaxBatchSize,
+		maxElapsedTime: sinkConfig.MaxElapsedTime.Duration,
+	}
+	if sink.endpoint == "" {
+		sink.endpoint = DefaultOTLPEndpoint
+	}
+	if sink.encoding == "" {
+		sink.encoding = OTLPEncodingProtobuf
+	}
+	if sink.encoding != OTLPEncodingProtobuf && sink.encoding != OTLPEncodingJSON {
+		return nil, fmt.Errorf("unknown OTLP encoding: %s", sink.encoding)
+	}
+	if sink.compression != "" && sink.compression != "none" && sink.compression != "gzip" {
+		return nil, fmt.Errorf("unknown OTLP compression: %s", sink.compression)
+	}
+	if sink.maxBatchSize <= 0 {
+		sink.maxBatchSize = DefaultOTLPMaxBatchSize
+	}
+	if sink.maxElapsedTime <= 0 {
+		sink.maxElapsedTime = DefaultSinkMaxElapsedTime
+	}
+	timeout := sinkConfig.Timeout.Duration
+	if timeout <= 0 {
+		timeout = DefaultSinkRequestTimeout
+	}
+	sink.client = &http.Client{Timeout: timeout}
+	return sink, nil
+}
+
+// Send adds an event log to the batch, and exports the batch when it is full
+func (sink *OTLPSink) Send(event SinkEvent) error {
+	sink.batchMux.Lock()
+	sink.batch = append(sink.batch, otlpLogRecord(event, time.Now()))
+	isFull := len(sink.batch) >= sink.maxBatchSize
+	sink.batchMux.Unlock()
+	if isFull {
+		return sink.Flush()
+	}
+	return nil
+}
+
+// Flush exports the batched event logs
+func (sink *OTLPSink) Flush() error {
+	sink.batchMux.Lock()
+	defer sink.batchMux.Unlock()
+	if len(sink.batch) == 0 {
+		return nil
+	}
+	records := sink.batch
+	sink.batch = nil
+
+	err := sink.export(records)
+	sink.mux.Lock()
+	sink.lastErr = err
+	sink.mux.Unlock()
+	return err
+}
+
+// Close exports the batched event logs
+func (sink *OTLPSink) Close() error {
+	return sink.Flush()
+}
+
+// Health returns the error of the last export request
+func (sink *OTLPSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// export sends log records to the OTLP endpoint, and retries throttled and unavailable requests with exponential backoff
+// until the maximal elapsed time, as in the OTLP specification
+func (sink *OTLPSink) export(records []otlpRecord) error {
+	body, contentType, err := encodeOTLPRequest(records, sink.encoding)
+	if err != nil {
+		return err
+	}
+	if sink.compression == "gzip" {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err = writer.Write(body); err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			return err
+		}
+		body = compressed.Bytes()
+	}
+
+	err = retryWithBackoff(sink.name, sink.maxElapsedTime, func() error {
+		return sink.post(body, contentType)
+	})
+	if err != nil {
+		return fmt.Errorf("dropped %d log records: %w", len(records), err)
+	}
+	return nil
+}
+
+// post sends an export request, and reports the log records the endpoint rejected in a partial success
+func (sink *OTLPSink) post(body []byte, contentType string) error {
+	request, err := http.NewRequest(http.MethodPost, sink.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	if sink.compression == "gzip" {
+		request.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range sink.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return newNetworkSinkError(err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		// Partial successes are not retried
+		if rejected, message := decodeOTLPPartialSuccess(responseBody, response.Header.Get("Content-Type")); rejected > 0 {
+			log.Printf("[ERROR] OTLP sink: %s rejected %d log records.\nERROR:\n%s", sink.name, rejected, message)
+		}
+		return nil
+	}
+
+	return newHTTPSinkError(response, decodeOTLPStatusMessage(responseBody, response.Header.Get("Content-Type")))
+}
+
+// otlpLogRecord maps an event log to an OpenTelemetry log record: the body is the event message, the attributes are the other
+// parsed fields, and the resource attributes identify the Kubernetes object of the event
+func otlpLogRecord(event SinkEvent, now time.Time) otlpRecord {
+	record := otlpRecord{
+		timeUnixNano:       uint64(now.UnixNano()),
+		severityNumber:     otlpSeverityInfo,
+		severityText:       "INFO",
+		body:               event.Message(),
+		attributes:         map[string]interface{}{},
+		resourceAttributes: map[string]interface{}{},
+	}
+	if event.EventType() == EventTypeRejected {
+		record.severityNumber, record.severityText = otlpSeverityWarn, "WARN"
+	}
+	for key, value := range event.Fields {
+		if key != "message" {
+			record.attributes[key] = value
+		}
+	}
+
+	serviceName, _ := event.Fields["type"].(string)
+	if serviceName == "" {
+		serviceName = DefaultLogType
+	}
+	record.resourceAttributes["service.name"] = serviceName
+	if namespace := event.Namespace(); namespace != "" {
+		record.resourceAttributes["k8s.namespace.name"] = namespace
+	}
+	kind := event.Kind()
+	if kind == "" {
+		return record
+	}
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	metadata, _ := newObject[Metadata].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	uid, _ := metadata["uid"].(string)
+	record.resourceAttributes["k8s.object.kind"] = kind
+	if name != "" {
+		record.resourceAttributes["k8s.object.name"] = name
+	}
+	if uid != "" {
+		record.resourceAttributes["k8s.object.uid"] = uid
+	}
+	if slices.Contains(otlpSemanticKinds, kind) {
+		prefix := "k8s." + strings.ToLower(kind)
+		if name != "" {
+			record.resourceAttributes[prefix+".name"] = name
+		}
+		if uid != "" {
+			record.resourceAttributes[prefix+".uid"] = uid
+		}
+	}
+	return record
+}
+
+// groupOTLPRecords groups log records by their resource attributes, in the order of the records
+func groupOTLPRecords(records []otlpRecord) (resourceLogs []*otlpResourceLogs) {
+	resources := map[string]*otlpResourceLogs{}
+	for _, record := range records {
+		keyBytes, _ := json.Marshal(record.resourceAttributes) // Map keys are marshaled sorted
+		key := string(keyBytes)
+		resource, ok := resources[key]
+		if !ok {
+			resource = &otlpResourceLogs{attributes: record.resourceAttributes}
+			resources[key] = resource
+			resourceLogs = append(resourceLogs, resource)
+		}
+		resource.records = append(resource.records, record)
+	}
+	return resourceLogs
+}
+
+// encodeOTLPRequest encodes log records in an ExportLogsServiceRequest, and returns its content type
+func encodeOTLPRequest(records []otlpRecord, encoding string) (body []byte, contentType string, err error) {
+	resourceLogs := groupOTLPRecords(records)
+	if encoding == OTLPEncodingJSON {
+		body, err = json.Marshal(otlpJSONRequest(resourceLogs))
+		return body, "application/json", err
+	}
+	body, err = otlpProtobufRequest(resourceLogs)
+	return body, "application/x-protobuf", err
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// otlpInt converts a number to an integer, if it is integral
+func otlpInt(number float64) (int64, bool) {
+	if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
+
+// otlpAnyValue converts a value to an AnyValue message
+func otlpAnyValue(value interface{}) *commonpb.AnyValue {
+	switch typed := value.(type) {
+	case nil:
+		return &commonpb.AnyValue{}
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: typed}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: typed}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(typed)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: typed}}
+	case float64:
+		if integer, ok := otlpInt(typed); ok {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: integer}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: typed}}
+	case []interface{}:
+		array := &commonpb.ArrayValue{}
+		for _, item := range typed {
+			array.Values = append(array.Values, otlpAnyValue(item))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: array}}
+	case map[string]interface{}:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: otlpKeyValues(typed)}}}
+	default:
+		return otlpAnyValue(fmt.Sprint(typed))
+	}
+}
+
+// otlpKeyValues converts a map to KeyValue messages, sorted by key
+func otlpKeyValues(values map[string]interface{}) (keyValues []*commonpb.KeyValue) {
+	for _, key := range sortedKeys(values) {
+		keyValues = append(keyValues, &commonpb.KeyValue{Key: key, Value: otlpAnyValue(values[key])})
+	}
+	return keyValues
+}
+
+// otlpProtobufRequest encodes resource logs as a protobuf ExportLogsServiceRequest
+func otlpProtobufRequest(resourceLogs []*otlpResourceLogs) ([]byte, error) {
+	request := &collogspb.ExportLogsServiceRequest{}
+	for _, resource := range resourceLogs {
+		scopeLogs := &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: otlpScopeName}}
+		for _, record := range resource.records {
+			scopeLogs.LogRecords = append(scopeLogs.LogRecords, &logspb.LogRecord{
+				TimeUnixNano:         record.timeUnixNano,
+				ObservedTimeUnixNano: record.timeUnixNano,
+				SeverityNumber:       logspb.SeverityNumber(record.severityNumber),
+				SeverityText:         record.severityText,
+				Body:                 otlpAnyValue(record.body),
+				Attributes:           otlpKeyValues(record.attributes),
+			})
+		}
+		request.ResourceLogs = append(request.ResourceLogs, &logspb.ResourceLogs{
+			Resource:  &resourcepb.Resource{Attributes: otlpKeyValues(resource.attributes)},
+			ScopeLogs: []*logspb.ScopeLogs{scopeLogs},
+		})
+	}
+	return proto.Marshal(request)
+}
+
+// otlpJSONAnyValue encodes a value as an AnyValue in the OTLP JSON encoding, with 64 bit integers as strings
+func otlpJSONAnyValue(value interface{}) map[string]interface{} {
+	switch typed := value.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case string:
+		return map[string]interface{}{"stringValue": typed}
+	case bool:
+		return map[string]interface{}{"boolValue": typed}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(typed)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+	case float64:
+		if integer, ok := otlpInt(typed); ok {
+			return map[string]interface{}{"intValue": strconv.FormatInt(integer, 10)}
+		}
+		return map[string]interface{}{"doubleValue": typed}
+	case []interface{}:
+		values := []interface{}{}
+		for _, item := range typed {
+			values = append(values, otlpJSONAnyValue(item))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": otlpJSONKeyValues(typed)}}
+	default:
+		return otlpJSONAnyValue(fmt.Sprint(typed))
+	}
+}
+
+// otlpJSONKeyValues encodes a map as KeyValues in the OTLP JSON encoding, sorted by key
+func otlpJSONKeyValues(values map[string]interface{}) []interface{} {
+	keyValues := []interface{}{}
+	for _, key := range sortedKeys(values) {
+		keyValues = append(keyValues, map[string]interface{}{"key": key, "value": otlpJSONAnyValue(values[key])})
+	}
+	return keyValues
+}
+
+// otlpJSONRequest encodes resource logs as an ExportLogsServiceRequest in the OTLP JSON encoding
+func otlpJSONRequest(resourceLogs []*otlpResourceLogs) map[string]interface{} {
+	resources := []interface{}{}
+	for _, resource := range resourceLogs {
+		logRecords := []interface{}{}
+		for _, record := range resource.records {
+			timeUnixNano := strconv.FormatUint(record.timeUnixNano, 10)
+			logRecords = append(logRecords, map[string]interface{}{
+				"timeUnixNano":         timeUnixNano,
+				"observedTimeUnixNano": timeUnixNano,
+				"severityNumber":       record.severityNumber,
+				"severityText":         record.severityText,
+				"body":                 otlpJSONAnyValue(record.body),
+				"attributes":           otlpJSONKeyValues(record.attributes),
+			})
+		}
+		resources = append(resources, map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": otlpJSONKeyValues(resource.attributes)},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": otlpScopeName},
+				"logRecords": logRecords,
+			}},
+		})
+	}
+	return map[string]interface{}{"resourceLogs": resources}
+}
+
+// decodeOTLPPartialSuccess decodes the number of rejected log records and the error message of an ExportLogsServiceResponse
+func decodeOTLPPartialSuccess(body []byte, contentType string) (rejected int64, message string) {
+	if strings.HasPrefix(contentType, "application/json") {
+		var response struct {
+			PartialSuccess struct {
+				RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+				ErrorMessage       string      `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		if json.Unmarshal(body, &response) == nil {
+			rejected, _ = response.PartialSuccess.RejectedLogRecords.Int64()
+			message = response.PartialSuccess.ErrorMessage
+		}
+		return rejected, message
+	}
+
+	var response collogspb.ExportLogsServiceResponse
+	if proto.Unmarshal(body, &response) == nil {
+		rejected = response.GetPartialSuccess().GetRejectedLogRecords()
+		message = response.GetPartialSuccess().GetErrorMessage()
+	}
+	return rejected, message
+}
+
+// decodeOTLPStatusMessage decodes the message of the Status of a failed export request, or returns the response body
+func decodeOTLPStatusMessage(body []byte, contentType string) (message string) {
+	if strings.HasPrefix(contentType, "application/json") {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return status.Message
+		}
+	} else if strings.HasPrefix(contentType, "application/x-protobuf") {
+		var status spb.Status
+		if proto.Unmarshal(body, &status) == nil && status.GetMessage() != "" {
+			return status.GetMessage()
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,381 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// receivedLogRecord is a log record decoded by the test OTLP receiver
+type receivedLogRecord struct {
+	resource   map[string]interface{}
+	severity   int64
+	body       interface{}
+	attributes map[string]interface{}
+}
+
+// receivedLogRecords decodes the log records of the export requests that a test receiver accepted
+func receivedLogRecords(requests []testHTTPRequest) (records []receivedLogRecord) {
+	for _, request := range requests {
+		if request.queued {
+			continue
+		}
+		if request.headers.Get("Content-Type") == "application/json" {
+			records = append(records, decodeJSONLogsRequest(request.body)...)
+		} else {
+			records = append(records, decodeProtobufLogsRequest(request.body)...)
+		}
+	}
+	return records
+}
+
+// decodeProtobufAnyValue decodes an AnyValue message
+func decodeProtobufAnyValue(value *commonpb.AnyValue) interface{} {
+	switch typed := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return typed.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return typed.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return typed.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return typed.DoubleValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := []interface{}{}
+		for _, item := range typed.ArrayValue.GetValues() {
+			values = append(values, decodeProtobufAnyValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		return decodeProtobufKeyValues(typed.KvlistValue.GetValues())
+	}
+	return nil
+}
+
+// decodeProtobufKeyValues decodes KeyValue messages
+func decodeProtobufKeyValues(keyValues []*commonpb.KeyValue) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, keyValue := range keyValues {
+		values[keyValue.GetKey()] = decodeProtobufAnyValue(keyValue.GetValue())
+	}
+	return values
+}
+
+// decodeProtobufLogsRequest decodes the log records of a protobuf ExportLogsServiceRequest with the OTLP generated types
+func decodeProtobufLogsRequest(body []byte) (records []receivedLogRecord) {
+	var request collogspb.ExportLogsServiceRequest
+	if proto.Unmarshal(body, &request) != nil {
+		return nil
+	}
+	for _, resourceLogs := range request.GetResourceLogs() {
+		resource := decodeProtobufKeyValues(resourceLogs.GetResource().GetAttributes())
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, logRecord := range scopeLogs.GetLogRecords() {
+				records = append(records, receivedLogRecord{
+					resource:   resource,
+					severity:   int64(logRecord.GetSeverityNumber()),
+					body:       decodeProtobufAnyValue(logRecord.GetBody()),
+					attributes: decodeProtobufKeyValues(logRecord.GetAttributes()),
+				})
+			}
+		}
+	}
+	return records
+}
+
+// decodeJSONAnyValue decodes an AnyValue in the OTLP JSON encoding
+func decodeJSONAnyValue(value map[string]interface{}) interface{} {
+	switch {
+	case value["stringValue"] != nil:
+		return value["stringValue"]
+	case value["boolValue"] != nil:
+		return value["boolValue"]
+	case value["intValue"] != nil:
+		integer, _ := strconv.ParseInt(value["intValue"].(string), 10, 64)
+		return integer
+	case value["doubleValue"] != nil:
+		return value["doubleValue"]
+	case value["arrayValue"] != nil:
+		values := []interface{}{}
+		for _, item := range value["arrayValue"].(map[string]interface{})["values"].([]interface{}) {
+			values = append(values, decodeJSONAnyValue(item.(map[string]interface{})))
+		}
+		return values
+	case value["kvlistValue"] != nil:
+		return decodeJSONKeyValues(value["kvlistValue"].(map[string]interface{})["values"].([]interface{}))
+	}
+	return nil
+}
+
+// decodeJSONKeyValues decodes KeyValues in the OTLP JSON encoding
+func decodeJSONKeyValues(keyValues []interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, keyValue := range keyValues {
+		keyValueMap := keyValue.(map[string]interface{})
+		values[keyValueMap["key"].(string)] = decodeJSONAnyValue(keyValueMap["value"].(map[string]interface{}))
+	}
+	return values
+}
+
+// decodeJSONLogsRequest decodes the log records of an ExportLogsServiceRequest in the OTLP JSON encoding
+func decodeJSONLogsRequest(body []byte) (records []receivedLogRecord) {
+	var request map[string]interface{}
+	if json.Unmarshal(body, &request) != nil {
+		return nil
+	}
+	for _, resourceLogs := range request["resourceLogs"].([]interface{}) {
+		resourceLogsMap := resourceLogs.(map[string]interface{})
+		resource := decodeJSONKeyValues(resourceLogsMap["resource"].(map[string]interface{})["attributes"].([]interface{}))
+		for _, scopeLogs := range resourceLogsMap["scopeLogs"].([]interface{}) {
+			for _, logRecord := range scopeLogs.(map[string]interface{})["logRecords"].([]interface{}) {
+				logRecordMap := logRecord.(map[string]interface{})
+				records = append(records, receivedLogRecord{
+					resource:   resource,
+					severity:   int64(logRecordMap["severityNumber"].(float64)),
+					body:       decodeJSONAnyValue(logRecordMap["body"].(map[string]interface{})),
+					attributes: decodeJSONKeyValues(logRecordMap["attributes"].([]interface{})),
+				})
+			}
+		}
+	}
+	return records
+}
+
+// otlpTestObject is the object of the OTLP test event logs, with nested attributes
+var otlpTestObject = map[string]interface{}{"newObject": map[string]interface{}{
+	Metadata: map[string]interface{}{"generation": float64(2)},
+	"spec":   map[string]interface{}{"paused": true, "ratio": 0.5, "args": []interface{}{"--verbose"}},
+}}
+
+// TestOTLPLogRecord tests mapping event logs to OpenTelemetry log records
+func TestOTLPLogRecord(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	record := otlpLogRecord(getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", otlpTestObject), now)
+	if record.body != "[EVENT] Resource: web of kind: Deployment in namespace: default was MODIFIED." || record.timeUnixNano != uint64(now.UnixNano()) {
+		t.Errorf("Unexpected log record: %+v", record)
+	}
+	if _, ok := record.attributes["message"]; ok || record.attributes["eventType"] != EventTypeModified || record.attributes["newObject"] == nil {
+		t.Errorf("Expected the parsed fields other than the message as attributes, got %v", record.attributes)
+	}
+	expectedResource := map[string]interface{}{
+		"service.name":        DefaultLogType,
+		"k8s.namespace.name":  "default",
+		"k8s.object.kind":     "Deployment",
+		"k8s.object.name":     "web",
+		"k8s.object.uid":      "web-uid",
+		"k8s.deployment.name": "web",
+		"k8s.deployment.uid":  "web-uid",
+	}
+	if len(record.resourceAttributes) != len(expectedResource) {
+		t.Errorf("Expected resource attributes %v, got %v", expectedResource, record.resourceAttributes)
+	}
+	for key, value := range expectedResource {
+		if record.resourceAttributes[key] != value {
+			t.Errorf("Expected resource attribute %s=%v, got %v", key, value, record.resourceAttributes[key])
+		}
+	}
+
+	record = otlpLogRecord(getTestSinkEvent(EventTypeRejected, "ConfigMap", "settings", "default", otlpTestObject), now)
+	if record.severityNumber != otlpSeverityWarn || record.resourceAttributes["k8s.configmap.name"] != nil || record.resourceAttributes["k8s.object.name"] != "settings" {
+		t.Errorf("Expected a warning without semantic convention attributes, got %+v", record)
+	}
+
+	record = otlpLogRecord(SinkEvent{Fields: map[string]interface{}{"message": "Collector started"}}, now)
+	if len(record.resourceAttributes) != 1 || record.body != "Collector started" {
+		t.Errorf("Expected only the service name of collector logs, got %+v", record)
+	}
+}
+
+// TestOTLPSinkExport tests exporting batches of event logs in the protobuf and JSON encodings
+func TestOTLPSinkExport(t *testing.T) {
+	tests := []struct {
+		encoding    string
+		compression string
+	}{
+		{encoding: OTLPEncodingProtobuf},
+		{encoding: OTLPEncodingJSON},
+		{encoding: OTLPEncodingProtobuf, compression: "gzip"},
+	}
+	for _, test := range tests {
+		t.Run(test.encoding+test.compression, func(t *testing.T) {
+			receiver := newTestHTTPReceiver(t, nil)
+			sink, err := NewOTLPSink(SinkConfig{OTLP: &OTLPSinkConfig{
+				Endpoint:     receiver.server.URL + "/v1/logs",
+				Encoding:     test.encoding,
+				Compression:  test.compression,
+				Headers:      map[string]string{"Authorization": "Bearer token"},
+				MaxBatchSize: 2,
+			}})
+			if err != nil {
+				t.Fatalf("Failed to create OTLP sink: %v", err)
+			}
+
+			events := []SinkEvent{
+				getTestSinkEvent(EventTypeAdded, "Deployment", "web", "default", otlpTestObject),
+				getTestSinkEvent(EventTypeModified, "Deployment", "web", "default", otlpTestObject),
+				getTestSinkEvent(EventTypeDeleted, "Pod", "web-1", "default", otlpTestObject),
+			}
+			for _, event := range events {
+				if err = sink.Send(event); err != nil {
+					t.Fatalf("Failed to send event log: %v", err)
+				}
+			}
+			if requests := receiver.received(); len(requests) != 1 {
+				t.Errorf("Expected the full batch to be exported, got %d requests", len(requests))
+			}
+			if err = sink.Close(); err != nil {
+				t.Fatalf("Failed to close OTLP sink: %v", err)
+			}
+
+			requests := receiver.received()
+			records := receivedLogRecords(requests)
+			if len(requests) != 2 || len(records) != 3 {
+				t.Fatalf("Expected 3 log records in 2 requests, got %d in %d", len(records), len(requests))
+			}
+			if headers := requests[1].headers; headers.Get("Authorization") != "Bearer token" {
+				t.Errorf("Expected the configured headers, got %v", headers)
+			}
+			for recordIndex, record := range records {
+				if record.body != events[recordIndex].Message() || record.severity != otlpSeverityInfo {
+					t.Errorf("Unexpected log record: %+v", record)
+				}
+				if record.attributes["eventType"] != events[recordIndex].EventType() {
+					t.Errorf("Expected the event type attribute, got %v", record.attributes)
+				}
+			}
+			if records[2].resource["k8s.pod.name"] != "web-1" || records[0].resource["k8s.deployment.uid"] != "web-uid" {
+				t.Errorf("Unexpected resource attributes: %v, %v", records[0].resource, records[2].resource)
+			}
+			newObject, _ := records[0].attributes["newObject"].(map[string]interface{})
+			metadata, _ := newObject[Metadata].(map[string]interface{})
+			spec, _ := newObject["spec"].(map[string]interface{})
+			args, _ := spec["args"].([]interface{})
+			if metadata["generation"] != int64(2) || spec["paused"] != true || spec["ratio"] != 0.5 || len(args) != 1 || args[0] != "--verbose" {
+				t.Errorf("Unexpected nested attributes: %v", newObject)
+			}
+		})
+	}
+}
+
+// TestOTLPSinkRetries tests retrying throttled and unavailable export requests, and dropping rejected requests
+func TestOTLPSinkRetries(t *testing.T) {
+	withSinkBackoff(t)
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name             string
+		responses        []int
+		expectedRequests int
+		expectError      bool
+	}{
+		{name: "retryable", responses: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}, expectedRequests: 5},
+		{name: "bad request", responses: []int{http.StatusBadRequest}, expectedRequests: 1, expectError: true},
+		{name: "internal error", responses: []int{http.StatusInternalServerError}, expectedRequests: 1, expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver := newTestHTTPReceiver(t, nil, test.responses...)
+			endpoint := strings.Replace(receiver.server.URL, "://", "://user:secret-token@", 1)
+			sink, _ := NewOTLPSink(SinkConfig{Name: "otlp-test", OTLP: &OTLPSinkConfig{Endpoint: endpoint}})
+			sink.Send(getTestSinkEvent(EventTypeAdded, "Deployment", "web", "default", otlpTestObject))
+
+			err := sink.Flush()
+			if (err != nil) != test.expectError || (sink.Health() != nil) != test.expectError {
+				t.Errorf("Expected error: %t, got %v", test.expectError, err)
+			}
+			requests := receiver.received()
+			if len(requests) != test.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", test.expectedRequests, len(requests))
+			}
+			if records := receivedLogRecords(requests); !test.expectError && len(records) != 1 {
+				t.Errorf("Expected the retried log record to be received, got %d", len(records))
+			}
+		})
+	}
+
+	// Retries are logged with the name of the sink, without its endpoint and credentials
+	if !strings.Contains(output.String(), "sink: otlp-test") || strings.Contains(output.String(), "secret-token") {
+		t.Errorf("Expected the retries to be logged with the sink name only, got:\n%s", output.String())
+	}
+
+	// Retries stop after the maximal elapsed time
+	receiver := newTestHTTPReceiver(t, nil, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	sink, _ := NewOTLPSink(SinkConfig{OTLP: &OTLPSinkConfig{Endpoint: receiver.server.URL, MaxElapsedTime: metav1.Duration{Duration: 5 * time.Millisecond}}})
+	sink.Send(getTestSinkEvent(EventTypeAdded, "Deployment", "web", "default", otlpTestObject))
+	if err := sink.Flush(); err == nil {
+		t.Error("Expected an error after the maximal elapsed time")
+	}
+	if requests := receiver.received(); len(requests) >= 5 || len(receivedLogRecords(requests)) != 0 {
+		t.Errorf("Expected the log record to be dropped, got %d requests", len(requests))
+	}
+}
+
+// TestDecodeOTLPPartialSuccess tests decoding the rejected log records of export responses
+func TestDecodeOTLPPartialSuccess(t *testing.T) {
+	response, err := proto.Marshal(&collogspb.ExportLogsServiceResponse{
+		PartialSuccess: &collogspb.ExportLogsPartialSuccess{RejectedLogRecords: 2, ErrorMessage: "invalid body"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode partial success: %v", err)
+	}
+	if rejected, message := decodeOTLPPartialSuccess(response, "application/x-protobuf"); rejected != 2 || message != "invalid body" {
+		t.Errorf("Unexpected protobuf partial success: %d, %s", rejected, message)
+	}
+
+	response = []byte(`{"partialSuccess":{"rejectedLogRecords":"3","errorMessage":"invalid body"}}`)
+	if rejected, message := decodeOTLPPartialSuccess(response, "application/json"); rejected != 3 || message != "invalid body" {
+		t.Errorf("Unexpected JSON partial success: %d, %s", rejected, message)
+	}
+	if rejected, _ := decodeOTLPPartialSuccess(nil, "application/x-protobuf"); rejected != 0 {
+		t.Errorf("Expected no rejected log records of an empty response, got %d", rejected)
+	}
+}
+
+// TestDecodeOTLPStatusMessage tests decoding the Status message of failed export requests
+func TestDecodeOTLPStatusMessage(t *testing.T) {
+	body, err := proto.Marshal(&spb.Status{Code: 14, Message: "receiver unavailable"})
+	if err != nil {
+		t.Fatalf("Failed to encode status: %v", err)
+	}
+	if message := decodeOTLPStatusMessage(body, "application/x-protobuf"); message != "receiver unavailable" {
+		t.Errorf("Unexpected protobuf status message: %s", message)
+	}
+	if message := decodeOTLPStatusMessage([]byte(`{"code":14,"message":"receiver unavailable"}`), "application/json"); message != "receiver unavailable" {
+		t.Errorf("Unexpected JSON status message: %s", message)
+	}
+	if message := decodeOTLPStatusMessage([]byte("bad gateway\n"), "text/plain"); message != "bad gateway" {
+		t.Errorf("Expected the response body of an unknown content type, got: %s", message)
+	}
+}
+
+// TestNewOTLPSink tests the defaults and validation of the OTLP sink configuration
+func TestNewOTLPSink(t *testing.T) {
+	sink, err := NewOTLPSink(SinkConfig{Type: SinkTypeOTLP})
+	if err != nil {
+		t.Fatalf("Failed to create OTLP sink: %v", err)
+	}
+	otlpSink := sink.(*OTLPSink)
+	if otlpSink.endpoint != DefaultOTLPEndpoint || otlpSink.encoding != OTLPEncodingProtobuf || otlpSink.maxBatchSize != DefaultOTLPMaxBatchSize {
+		t.Errorf("Unexpected OTLP sink defaults: %+v", otlpSink)
+	}
+	if _, err = NewOTLPSink(SinkConfig{OTLP: &OTLPSinkConfig{Encoding: "xml"}}); err == nil {
+		t.Error("Expected an error of an unknown encoding")
+	}
+	if _, err = NewOTLPSink(SinkConfig{OTLP: &OTLPSinkConfig{Compression: "zstd"}}); err == nil {
+		t.Error("Expected an error of an unknown compression")
+	}
+}
@@ -1,21 +1,30 @@
 package common
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"k8s.io/utils/strings/slices"
 	"log"
+	"maps"
+	"os"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 )
 
-var eventKind string
+var hashKey []byte
+var hashKeyOnce sync.Once
 
 type KubernetesMetadata struct {
 	Name            string `json:"name,omitempty"`
 	Namespace       string `json:"namespace,omitempty"`
 	ResourceVersion string `json:"resourceVersion,omitempty"`
+	UID             string `json:"uid,omitempty"`
 }
 type KubernetesEvent struct {
 	Kind               string `json:"kind,omitempty"`
@@ -39,10 +48,22 @@ type LogEvent struct {
 	ExtraFields            map[string]interface{} `json:"-"`
 }
 
+// StatusCondition describes a status condition transition of a resource
+type StatusCondition struct {
+	Type               string `json:"type,omitempty"`
+	Status             string `json:"status,omitempty"`
+	PreviousStatus     string `json:"previousStatus,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	PreviousReason     string `json:"previousReason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
 type RelatedClusterServices struct {
 	Deployments         []string `json:"deployments,omitempty"`
 	DaemonSets          []string `json:"daemonsets,omitempty"`
 	StatefulSets        []string `json:"statefulsets,omitempty"`
+	Rollouts            []string `json:"rollouts,omitempty"`
 	Pods                []string `json:"pods,omitempty"`
 	Secrets             []string `json:"secrets,omitempty"`
 	ServiceAccounts     []string `json:"serviceaccounts,omitempty"`
@@ -88,6 +109,74 @@ func ParseEventMessage(eventType string, resourceName string, resourceKind strin
 	return msg
 }
 
+// ParseAdmissionMessage parses event messages of change attempts seen by the admission webhook
+func ParseAdmissionMessage(eventType string, operation string, resourceName string, resourceKind string, resourceNamespace string, username string) (msg string) {
+	inNamespaceMsg := ""
+	if resourceNamespace != "" {
+		inNamespaceMsg = " in namespace: " + resourceNamespace
+	}
+
+	switch eventType {
+	case EventTypeRejected:
+		msg = fmt.Sprintf("[EVENT] %s of resource: %s of kind: %s%s by user: %s was rejected.", operation, resourceName, resourceKind, inNamespaceMsg, username)
+	case EventTypeDryRun:
+		msg = fmt.Sprintf("[EVENT] Dry run %s of resource: %s of kind: %s%s by user: %s.", operation, resourceName, resourceKind, inNamespaceMsg, username)
+	default:
+		log.Printf("[ERROR] Failed to parse admission event log message. Unknown eventType: %s.\n", eventType)
+	}
+	return msg
+}
+
+// ParseHelmReleaseMessage parses event messages of Helm release actions
+func ParseHelmReleaseMessage(action string, releaseName string, releaseNamespace string, chart string, chartVersion string, revision string, status string) (msg string) {
+	return fmt.Sprintf("[EVENT] Helm release: %s in namespace: %s %s of chart: %s version: %s to revision: %s finished with status: %s.", releaseName, releaseNamespace, action, chart, chartVersion, revision, status)
+}
+
+// ParseGitOpsTransitionMessage parses event messages of Argo CD and Flux sync and health transitions
+func ParseGitOpsTransitionMessage(resourceName string, resourceKind string, resourceNamespace string, transition string, revision string) (msg string) {
+	inNamespaceMsg := ""
+	if resourceNamespace != "" {
+		inNamespaceMsg = " in namespace: " + resourceNamespace
+	}
+	revisionMsg := ""
+	if revision != "" {
+		revisionMsg = " at revision: " + revision
+	}
+	return fmt.Sprintf("[EVENT] Resource: %s of kind: %s%s transitioned to: %s%s.", resourceName, resourceKind, inNamespaceMsg, transition, revisionMsg)
+}
+
+// ParseProgressiveDeliveryMessage parses event messages of progressive delivery transitions, with the step, weight or analysis detail of the transition
+func ParseProgressiveDeliveryMessage(resourceName string, resourceKind string, resourceNamespace string, transition string, detail string) (msg string) {
+	inNamespaceMsg := ""
+	if resourceNamespace != "" {
+		inNamespaceMsg = " in namespace: " + resourceNamespace
+	}
+	detailMsg := ""
+	if detail != "" {
+		detailMsg = " to " + detail
+	}
+	return fmt.Sprintf("[EVENT] Progressive delivery of resource: %s of kind: %s%s %s%s.", resourceName, resourceKind, inNamespaceMsg, transition, detailMsg)
+}
+
+// ParseStatusChangeMessage parses event messages of resource status condition transitions
+func ParseStatusChangeMessage(resourceName string, resourceKind string, resourceNamespace string, condition StatusCondition) (msg string) {
+	inNamespaceMsg := ""
+	if resourceNamespace != "" {
+		inNamespaceMsg = " in namespace: " + resourceNamespace
+	}
+	previousStatus := condition.PreviousStatus
+	if previousStatus == "" {
+		previousStatus = ConditionStatusUnknown
+	}
+	reasonMsg := ""
+	if condition.Reason != "" {
+		reasonMsg = fmt.Sprintf(" Reason: %s.", condition.Reason)
+	}
+
+	msg = fmt.Sprintf("[EVENT] Resource: %s of kind: %s%s condition: %s changed from: %s to: %s.%s", resourceName, resourceKind, inNamespaceMsg, condition.Type, previousStatus, condition.Status, reasonMsg)
+	return msg
+}
+
 // FormatFieldName formats field name
 func FormatFieldName(field string) (fieldName string) {
 	fieldName = field
@@ -127,15 +216,23 @@ func FormatFieldValue(value interface{}) (fieldValue interface{}) {
 func FormatFieldOverLimit(fieldName string, fieldValue interface{}) (fieldOverLimit string, truncatedFieldValue interface{}) {
 	fieldOverLimit = fieldName
 	truncatedFieldValue = fieldValue
-	var valueLengthLimit = 32700
+	// Strings are measured as they are, and other scalars are within the limit without formatting them
+	stringValue, isString := fieldValue.(string)
+	switch fieldValue.(type) {
+	case bool, float64, int, int64, json.Number:
+		return fieldOverLimit, truncatedFieldValue
+	}
+	if !isString {
+		stringValue = fmt.Sprint(fieldValue)
+	}
 	// Check if the field value length is over the limit
-	if len(fmt.Sprint(fieldValue)) >= valueLengthLimit && !strings.HasSuffix(fieldName, "_overLimit") {
-		// Add the field to the fieldsOverLimit slice, so it will be ignored in the next iteration
+	if len(stringValue) >= FieldValueLengthLimit {
 		// Truncate the field value to the limit
-		truncatedFieldValue = fmt.Sprintf("%s", fmt.Sprint(fieldValue)[:valueLengthLimit])
-		// Rename the field if it passes value length limit
-		fieldOverLimit = fmt.Sprintf("%s_overLimit", fieldName)
-		// Add the field to the fieldsOverLimit slice, so it will be ignored in the next iteration
+		truncatedFieldValue = stringValue[:FieldValueLengthLimit]
+		// Rename the field if it passes value length limit, unless it is already named as over the limit
+		if !strings.HasSuffix(fieldName, "_overLimit") {
+			fieldOverLimit = fieldName + "_overLimit"
+		}
 	}
 	return fieldOverLimit, truncatedFieldValue
 
@@ -151,87 +248,127 @@ func IsEmptyMap(value interface{}) bool {
 	return isEmpty
 }
 
-// parseLogzioLimits parses the logzio limits and replaces the fields with the new ones
-func parseLogzioLimits(eventLog map[string]interface{}) (parsedLogEvent map[string]interface{}) {
+// parsedField is a field of an event log whose name changed when it was parsed within the Logz.io limits
+type parsedField struct {
+	field string
+	name  string
+	value interface{}
+}
 
-	// Declare variables
+// eventLogKind returns the kind of the new object of an event log, used to mask the sensitive fields of its kind
+func eventLogKind(eventLog map[string]interface{}) string {
+	newObject, _ := eventLog["newObject"].(map[string]interface{})
+	kind, _ := newObject["kind"].(string)
+	return kind
+}
+
+// parseLogzioLimits returns the event log within the Logz.io limits, without modifying the event log.
+// The output only depends on the event log, so it is safe for concurrent use and deterministic. Nested fields that
+// are already within the limits are shared with the event log instead of copied, so neither should be modified.
+func parseLogzioLimits(eventLog map[string]interface{}) (parsedLogEvent map[string]interface{}) {
+	parsedLogEvent, _ = parseLogzioFields(eventLog, eventLogKind(eventLog))
+	return parsedLogEvent
+}
 
-	// Iterate over the log
-	parsedLogEvent = eventLog
+// isSameFieldValue checks if formatting a field value within the limits kept the value. Formatted values are strings.
+func isSameFieldValue(value interface{}, fieldValue interface{}) bool {
+	formattedValue, isFormatted := fieldValue.(string)
+	stringValue, isString := value.(string)
+	return !isFormatted || (isString && stringValue == formattedValue)
+}
 
-	if eventLog["newObject"] != nil {
-		eventI := eventLog["newObject"].(map[string]interface{})
-		if eventI["kind"] != nil {
-			eventKind = eventI["kind"].(string)
+// parseLogzioField parses a field of an event log of a kind, and returns its new name and value, and whether they
+// changed. Empty fields return an empty name.
+func parseLogzioField(kind string, field string, value interface{}) (fieldName string, fieldValue interface{}, isChanged bool) {
+	// Remove the empty or invalid/nil fields from the log
+	if value == nil || IsEmptyMap(value) {
+		return "", nil, true
+	}
+	// Replace the dots/slashes/hyphens of the field name with underscores
+	fieldName = FormatFieldName(field)
+	if maskedField, maskedValue := MaskSensitiveData(kind, fieldName, value); maskedField != fieldName {
+		return maskedField, maskedValue, true
+	}
+	if nestedField, ok := value.(map[string]interface{}); ok {
+		nestedValue, isNestedChanged := parseLogzioFields(nestedField, kind)
+		if len(nestedValue) == 0 {
+			return "", nil, true
 		}
+		return fieldName, nestedValue, isNestedChanged || fieldName != field
 	}
-	for field, value := range eventLog {
-		// Check if the field contains a dot/slash/hyphen and replace it with underscore
-		// Check if the field is empty
+	fieldName, fieldValue = FormatFieldOverLimit(fieldName, FormatFieldValue(value))
+	return fieldName, fieldValue, fieldName != field || !isSameFieldValue(value, fieldValue)
+}
 
-		if !reflect.ValueOf(value).IsValid() || value == nil || IsEmptyMap(value) {
-			// Remove the empty or invalid/nil/struct{} field from the log
-			delete(parsedLogEvent, field)
+// parseLogzioFields parses the fields of an event log of a kind, and returns whether they changed. The event log is
+// copied on its first changed field, and returned as is if no field changed.
+// Fields whose name doesn't change are kept over renamed fields with the same name, and renamed fields are added in
+// the order of their original names, so the output doesn't depend on the iteration order of the map.
+func parseLogzioFields(eventLog map[string]interface{}, kind string) (parsedLogEvent map[string]interface{}, isChanged bool) {
+	var renamedFields []parsedField
+	for field, value := range eventLog {
+		fieldName, fieldValue, isFieldChanged := parseLogzioField(kind, field, value)
+		if !isFieldChanged {
+			continue
 		}
-		fieldName := FormatFieldName(field)
-		if fieldName != field {
-			// Rename the field
-			parsedLogEvent[fieldName] = value
-			// Remove the original field
-			delete(parsedLogEvent, field)
+		if !isChanged {
+			parsedLogEvent = maps.Clone(eventLog)
+			isChanged = true
 		}
-		maskedField, maskedValue := MaskSensitiveData(eventKind, fieldName, value)
-		if !reflect.DeepEqual(value, maskedValue) {
-			parsedLogEvent[maskedField] = maskedValue
-			delete(parsedLogEvent, fieldName)
+		if fieldName == field {
+			parsedLogEvent[field] = fieldValue
+			continue
 		}
-
-		nestedField, ok := value.(map[string]interface{})
-
-		// Check if the field is a nested map or struct
-		if ok {
-			parseLogzioLimits(nestedField)
-		} else {
-			{
-
-				fieldValue := FormatFieldValue(value)
-				if !reflect.DeepEqual(value, fieldValue) {
-					// Add the field value to the parsed log
-					parsedLogEvent[fieldName] = fieldValue
-				}
-
-				fieldOverLimit, truncatedFieldValue := FormatFieldOverLimit(fieldName, fieldValue)
-				if fieldOverLimit != fieldName {
-					parsedLogEvent[fieldOverLimit] = truncatedFieldValue
-					delete(parsedLogEvent, fieldName)
-				}
-
-			}
-
+		delete(parsedLogEvent, field)
+		if fieldName != "" {
+			renamedFields = append(renamedFields, parsedField{field: field, name: fieldName, value: fieldValue})
 		}
-
+	}
+	if !isChanged {
+		return eventLog, false
 	}
 
-	return parsedLogEvent
+	slices.SortFunc(renamedFields, func(a, b parsedField) int {
+		return strings.Compare(a.field, b.field)
+	})
+	for _, renamedField := range renamedFields {
+		if _, exists := parsedLogEvent[renamedField.name]; !exists {
+			parsedLogEvent[renamedField.name] = renamedField.value
+		}
+	}
+	return parsedLogEvent, true
 }
 
 // hashData uses MD5 to hash the secret and return the hashed secret
 func hashData(data interface{}) (hashedData string) {
-	// Create a new MD5 hash object
-	hash := md5.New()
-
-	// Write the secret to the hash object
-	hash.Write([]byte(data.(string)))
-
 	// Get the MD5 hash of the secret
-	hashSum := hash.Sum(nil)
+	hashSum := md5.Sum([]byte(data.(string)))
 
 	// Convert the MD5 hash to a string
-	hashedData = fmt.Sprintf("%x", hashSum)
+	hashedData = hex.EncodeToString(hashSum[:])
 
 	return hashedData
 }
 
+// KeyedHash returns a hex encoded HMAC-SHA256 of the data, keyed by the SECRET_HASH_KEY environment variable.
+// If SECRET_HASH_KEY isn't set, a random key is used, so hashes can only be compared within the same run.
+func KeyedHash(data []byte) (hashedData string) {
+	hashKeyOnce.Do(func() {
+		hashKey = []byte(os.Getenv("SECRET_HASH_KEY"))
+		if len(hashKey) == 0 {
+			log.Printf("[WARNING] SECRET_HASH_KEY is not set, using a random key for hashing secret values.")
+			hashKey = make([]byte, 32)
+			if _, err := rand.Read(hashKey); err != nil {
+				log.Printf("[ERROR] Failed to generate a random hash key.\nERROR:\n%v", err)
+			}
+		}
+	})
+
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // MaskSensitiveData masks sensitive data in the log event
 func MaskSensitiveData(eventKind string, fieldName string, fieldValue interface{}) (maskedField string, maskedValue interface{}) {
 	maskedValue = fieldValue // Initialize maskedValue to original fieldValue
@@ -243,9 +380,12 @@ func MaskSensitiveData(eventKind string, fieldName string, fieldValue interface{
 	// Check if the field name is in the list of fields to mask, or has "_crt" in it, or is a secret data or last applied configuration
 	if slices.Contains(fieldsToMask, fieldName) || strings.Contains(fieldName, "_crt") || (eventKind == "Secret" && (fieldName == "data" || fieldName == "kubectl_kubernetes_io_last_applied_configuration")) {
 		// If the field is sensitive, mask the field value by hashing it
-		stringValue := fmt.Sprintf("%v", fieldValue)
+		stringValue, isString := fieldValue.(string)
+		if !isString {
+			stringValue = fmt.Sprintf("%v", fieldValue)
+		}
 		maskedValue = hashData(stringValue)
-		maskedField = fmt.Sprintf("%s_hashed", fieldName) // Append "_hashed" to the field name
+		maskedField = fieldName + "_hashed" // Append "_hashed" to the field name
 	}
 
 	// Return the masked field name and value
@@ -2,8 +2,12 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"math/rand"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -158,6 +162,7 @@ func GetTestEventLog() (eventLog map[string]interface{}) {
 func TestParseLogzioLimits(t *testing.T) {
 	eventLog := GetTestEventLog()
 	parsedLogEvent := eventLog
+	var eventKind string
 
 	t.Run("testNewObjectKind", func(t *testing.T) {
 		if eventLog["newObject"] != nil {
@@ -222,3 +227,285 @@ func TestParseLogzioLimits(t *testing.T) {
 		})
 	}
 }
+
+// TestParseStatusChangeMessage tests the message of status condition transitions
+func TestParseStatusChangeMessage(t *testing.T) {
+	condition := StatusCondition{Type: "Available", Status: "False", PreviousStatus: "True", Reason: "MinimumReplicasUnavailable"}
+	msg := ParseStatusChangeMessage("test-deployment", "Deployment", "default", condition)
+	expected := "[EVENT] Resource: test-deployment of kind: Deployment in namespace: default condition: Available changed from: True to: False. Reason: MinimumReplicasUnavailable."
+	if msg != expected {
+		t.Errorf("Expected message: %s, got: %s", expected, msg)
+	}
+
+	condition = StatusCondition{Type: "Ready", Status: "True"}
+	msg = ParseStatusChangeMessage("test-clusterrole", "ClusterRole", "", condition)
+	expected = "[EVENT] Resource: test-clusterrole of kind: ClusterRole condition: Ready changed from: Unknown to: True."
+	if msg != expected {
+		t.Errorf("Expected message: %s, got: %s", expected, msg)
+	}
+}
+
+// TestKeyedHash tests that keyed hashes are stable and don't reveal the hashed value
+func TestKeyedHash(t *testing.T) {
+	hashedData := KeyedHash([]byte("hunter2"))
+	if hashedData != KeyedHash([]byte("hunter2")) {
+		t.Errorf("Expected the keyed hash to be stable")
+	}
+	if hashedData == KeyedHash([]byte("hunter3")) {
+		t.Errorf("Expected different values to have different keyed hashes")
+	}
+	if len(hashedData) != 64 || hashedData == hashData("hunter2") {
+		t.Errorf("Expected a hex encoded HMAC-SHA256, got %s", hashedData)
+	}
+}
+
+// TestParseAdmissionMessage tests the messages of rejected and dry run changes
+func TestParseAdmissionMessage(t *testing.T) {
+	msg := ParseAdmissionMessage(EventTypeRejected, "UPDATE", "web", "Deployment", "default", "alice")
+	expected := "[EVENT] UPDATE of resource: web of kind: Deployment in namespace: default by user: alice was rejected."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+	msg = ParseAdmissionMessage(EventTypeDryRun, "CREATE", "admin", "ClusterRole", "", "bob")
+	expected = "[EVENT] Dry run CREATE of resource: admin of kind: ClusterRole by user: bob."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+}
+
+// TestParseHelmReleaseMessage tests the messages of Helm release actions
+func TestParseHelmReleaseMessage(t *testing.T) {
+	msg := ParseHelmReleaseMessage("upgrade", "web", "default", "web", "1.2.3", "2", "deployed")
+	expected := "[EVENT] Helm release: web in namespace: default upgrade of chart: web version: 1.2.3 to revision: 2 finished with status: deployed."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+}
+
+// TestParseGitOpsTransitionMessage tests the message of GitOps sync and health transitions
+func TestParseGitOpsTransitionMessage(t *testing.T) {
+	msg := ParseGitOpsTransitionMessage("web", "Application", "argocd", "synced", "abc123")
+	expected := "[EVENT] Resource: web of kind: Application in namespace: argocd transitioned to: synced at revision: abc123."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+	msg = ParseGitOpsTransitionMessage("web", "Application", "argocd", "degraded", "")
+	expected = "[EVENT] Resource: web of kind: Application in namespace: argocd transitioned to: degraded."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+}
+
+// TestParseProgressiveDeliveryMessage tests the message of progressive delivery transitions
+func TestParseProgressiveDeliveryMessage(t *testing.T) {
+	msg := ParseProgressiveDeliveryMessage("web", "Rollout", "default", "step-progressed", "step: 2/3")
+	expected := "[EVENT] Progressive delivery of resource: web of kind: Rollout in namespace: default step-progressed to step: 2/3."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+	msg = ParseProgressiveDeliveryMessage("web", "Canary", "default", "promoted", "")
+	expected = "[EVENT] Progressive delivery of resource: web of kind: Canary in namespace: default promoted."
+	if msg != expected {
+		t.Errorf("Expected message %q, got %q", expected, msg)
+	}
+}
+
+// TestParseLogzioLimitsFields tests renaming, masking, formatting and truncating fields, and resolving renamed fields
+// with the same name
+func TestParseLogzioLimitsFields(t *testing.T) {
+	eventLog := map[string]interface{}{
+		"a.b":      "dot",
+		"a_b":      "exact",
+		"a-b":      "hyphen",
+		"c.d":      "dot",
+		"c-d":      "hyphen",
+		"empty":    map[string]interface{}{},
+		"nil":      nil,
+		"password": "secret",
+		"token":    strings.Repeat("t", FieldValueLengthLimit+1),
+		"api_key":  []interface{}{map[string]interface{}{"k": "v"}},
+		"list":     []interface{}{map[string]interface{}{"k": "v"}},
+		"long":     strings.Repeat("x", FieldValueLengthLimit+1),
+		"nested":   map[string]interface{}{"x.y": 1.0, "empty": map[string]interface{}{"e": nil}},
+		"newObject": map[string]interface{}{
+			"kind": "Secret",
+			"data": map[string]interface{}{"k": "v"},
+		},
+	}
+	expected := map[string]interface{}{
+		"a_b":             "exact",
+		"c_d":             "hyphen",
+		"password_hashed": hashData("secret"),
+		"token_hashed":    hashData(strings.Repeat("t", FieldValueLengthLimit+1)),
+		"api_key_hashed":  hashData("[map[k:v]]"),
+		"list":            `[{"k":"v"}]`,
+		"long_overLimit":  strings.Repeat("x", FieldValueLengthLimit),
+		"nested":          map[string]interface{}{"x_y": 1.0},
+		"newObject": map[string]interface{}{
+			"kind":        "Secret",
+			"data_hashed": hashData("map[k:v]"),
+		},
+	}
+	if parsedLogEvent := parseLogzioLimits(eventLog); !reflect.DeepEqual(parsedLogEvent, expected) {
+		t.Errorf("Expected parsed event log:\n%v\ngot:\n%v", expected, parsedLogEvent)
+	}
+}
+
+// TestParseLogzioLimitsUnchanged tests that an event log within the limits is returned as is, without copying it
+func TestParseLogzioLimitsUnchanged(t *testing.T) {
+	eventLog := map[string]interface{}{
+		"message":   "Test log",
+		"replicas":  3.0,
+		"newObject": map[string]interface{}{"kind": "ConfigMap", "data": map[string]interface{}{"k": "v"}},
+	}
+	if parsedLogEvent := parseLogzioLimits(eventLog); reflect.ValueOf(parsedLogEvent).Pointer() != reflect.ValueOf(eventLog).Pointer() {
+		t.Errorf("Expected the event log to be returned as is, got %v", parsedLogEvent)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { parseLogzioLimits(eventLog) }); allocs != 0 {
+		t.Errorf("Expected parsing an event log within the limits not to allocate, got %v allocations", allocs)
+	}
+}
+
+// randomEventLogKeys are the field names of random event logs, with names that are renamed to the same name, and
+// sensitive names
+var randomEventLogKeys = []string{"a", "b", "a.b", "a_b", "a-b", "a\\b", "password", "password_hashed", "data", "kind", "tls.crt", "x", "x_overLimit"}
+
+// randomEventLogValue returns a random field value of an event log, with nested fields up to a depth
+func randomEventLogValue(random *rand.Rand, depth int) interface{} {
+	switch random.Intn(10) {
+	case 0:
+		return nil
+	case 1:
+		return random.Float64()
+	case 2:
+		return random.Intn(2) == 0
+	case 3:
+		return strings.Repeat("v", FieldValueLengthLimit-1+random.Intn(3))
+	case 4:
+		return []interface{}{fmt.Sprint(random.Int()), fmt.Sprint(random.Int())}
+	case 5:
+		return []interface{}{map[string]interface{}{"k": fmt.Sprint(random.Int())}}
+	case 6, 7:
+		if depth > 0 {
+			return randomEventLogFields(random, depth-1)
+		}
+	}
+	return fmt.Sprint(random.Int())
+}
+
+// randomEventLogFields returns random fields of an event log, with nested fields up to a depth
+func randomEventLogFields(random *rand.Rand, depth int) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for i := random.Intn(len(randomEventLogKeys)); i > 0; i-- {
+		fields[randomEventLogKeys[random.Intn(len(randomEventLogKeys))]] = randomEventLogValue(random, depth)
+	}
+	return fields
+}
+
+// randomEventLog returns a random event log of a seed
+func randomEventLog(seed int64) map[string]interface{} {
+	random := rand.New(rand.NewSource(seed))
+	eventLog := randomEventLogFields(random, 3)
+	newObject := randomEventLogFields(random, 3)
+	newObject["kind"] = []string{"Secret", "ConfigMap"}[random.Intn(2)]
+	eventLog["newObject"] = newObject
+	return eventLog
+}
+
+// checkParsedFields checks that parsed fields have valid names and non empty values within the limit
+func checkParsedFields(t *testing.T, fields map[string]interface{}) {
+	for field, value := range fields {
+		if strings.ContainsAny(field, "\\.-") {
+			t.Errorf("Expected field %s to be renamed", field)
+		}
+		switch value := value.(type) {
+		case nil:
+			t.Errorf("Expected field %s with nil value to be removed", field)
+		case map[string]interface{}:
+			if len(value) == 0 {
+				t.Errorf("Expected field %s with empty value to be removed", field)
+			}
+			checkParsedFields(t, value)
+		case string:
+			if len(value) > FieldValueLengthLimit {
+				t.Errorf("Expected field %s to be truncated, got length %d", field, len(value))
+			}
+		}
+	}
+}
+
+// TestParseLogzioLimitsProperties tests that parsing random event logs doesn't modify them, and returns the same
+// valid output across repeated runs, regardless of the iteration order of the maps
+func TestParseLogzioLimitsProperties(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		eventLog := randomEventLog(seed)
+		parsedLogEvent := parseLogzioLimits(eventLog)
+		if !reflect.DeepEqual(eventLog, randomEventLog(seed)) {
+			t.Fatalf("Expected the event log of seed %d not to be modified", seed)
+		}
+		checkParsedFields(t, parsedLogEvent)
+		for run := 0; run < 20; run++ {
+			if !reflect.DeepEqual(parseLogzioLimits(eventLog), parsedLogEvent) {
+				t.Fatalf("Expected the event log of seed %d to be parsed to the same output in every run", seed)
+			}
+		}
+	}
+}
+
+// TestParseLogzioLimitsConcurrent tests parsing event logs of different kinds concurrently, so each event log is masked
+// by its own kind
+func TestParseLogzioLimitsConcurrent(t *testing.T) {
+	eventLogs := []map[string]interface{}{
+		{"newObject": map[string]interface{}{"kind": "Secret", "data": map[string]interface{}{"k": "v"}}},
+		{"newObject": map[string]interface{}{"kind": "ConfigMap", "data": map[string]interface{}{"k": "v"}}},
+	}
+	expected := []map[string]interface{}{
+		{"newObject": map[string]interface{}{"kind": "Secret", "data_hashed": hashData("map[k:v]")}},
+		{"newObject": map[string]interface{}{"kind": "ConfigMap", "data": map[string]interface{}{"k": "v"}}},
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				index := (worker + i) % len(eventLogs)
+				if parsedLogEvent := parseLogzioLimits(eventLogs[index]); !reflect.DeepEqual(parsedLogEvent, expected[index]) {
+					t.Errorf("Expected parsed event log %v, got %v", expected[index], parsedLogEvent)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkParseLogzioLimits benchmarks parsing an event log of a secret
+func BenchmarkParseLogzioLimits(b *testing.B) {
+	eventLog := GetTestEventLog()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseLogzioLimits(eventLog)
+	}
+}
+
+// BenchmarkParseLogzioLimitsNested benchmarks parsing an event log with nested, renamed, over limit and list fields
+func BenchmarkParseLogzioLimitsNested(b *testing.B) {
+	eventLog := randomEventLog(1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseLogzioLimits(eventLog)
+	}
+}
+
+// BenchmarkParseLogzioLimitsParallel benchmarks parsing an event log of a secret concurrently
+func BenchmarkParseLogzioLimitsParallel(b *testing.B) {
+	eventLog := GetTestEventLog()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			parseLogzioLimits(eventLog)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/logzio/logzio-go"
 	"log"
 	"os"
@@ -11,6 +12,11 @@ import (
 
 var LogzioSender *logzio.LogzioSender
 
+// init registers the Logz.io sink type
+func init() {
+	RegisterSinkType(SinkTypeLogzio, NewLogzioSink)
+}
+
 // ConfigureLogzioSender configures the Logz.io sender
 func ConfigureLogzioSender() {
 
@@ -18,16 +24,8 @@ func ConfigureLogzioSender() {
 	LogzioToken := os.Getenv("LOGZIO_TOKEN")
 	if LogzioToken != "" {
 		LogzioListener := os.Getenv("LOGZIO_LISTENER")
-		if LogzioListener == "" {
-			LogzioListener = DefaultListener // Defaults to us-east-1 region
-		}
 		// Creating a new logz.io logger with specified configuration
-		LogzioSender, err = logzio.New(
-			LogzioToken,
-			logzio.SetUrl(LogzioListener),
-			logzio.SetDrainDuration(time.Second*5),
-			logzio.SetDrainDiskThreshold(99),
-		)
+		LogzioSender, err = newLogzioSender(LogzioToken, LogzioListener)
 		if err != nil {
 			// If there is an error in creating the logger, log the error and exit
 			log.Fatalf("\n[FATAL] Failed to configure the Logz.io resources.\nERROR: %v\n", err)
@@ -38,25 +36,80 @@ func ConfigureLogzioSender() {
 	}
 }
 
-// shipLogEvent ships log event to Logz.io
-func shipLogEvent(eventLog string) {
+// LogzioSink sends event logs to Logz.io
+type LogzioSink struct {
+	sender  *logzio.LogzioSender
+	mux     sync.Mutex
+	lastErr error
+}
+
+// newLogzioSender creates a Logz.io sender for a shipping token and listener URL
+func newLogzioSender(token string, listener string) (*logzio.LogzioSender, error) {
+	if listener == "" {
+		listener = DefaultListener // Defaults to us-east-1 region
+	}
+	return logzio.New(
+		token,
+		logzio.SetUrl(listener),
+		logzio.SetDrainDuration(time.Second*5),
+		logzio.SetDrainDiskThreshold(99),
+	)
+}
+
+// NewLogzioSink creates a Logz.io sink, the token and listener default to the LOGZIO_TOKEN and LOGZIO_LISTENER environment variables
+func NewLogzioSink(config SinkConfig) (Sink, error) {
+	var sinkConfig LogzioSinkConfig
+	if config.Logzio != nil {
+		sinkConfig = *config.Logzio
+	}
+	if sinkConfig.Token == "" {
+		sinkConfig.Token = os.Getenv("LOGZIO_TOKEN")
+	}
+	if sinkConfig.Listener == "" {
+		sinkConfig.Listener = os.Getenv("LOGZIO_LISTENER")
+	}
+	if sinkConfig.Token == "" {
+		return nil, fmt.Errorf("no shipping token is configured for the Logz.io sink")
+	}
 
-	// Logging the event
-	log.Printf("\n[LOG]: %s\n", eventLog)
-	err = LogzioSender.Send([]byte(eventLog)) // Sending the log event to logz.io
+	sender, err := newLogzioSender(sinkConfig.Token, sinkConfig.Listener)
 	if err != nil {
-		// If there is an error in sending the log, log the error
-		log.Printf("\nFailed to send log:\n%v to Logz.io.\nRelated error:\n%v.", eventLog, err)
-		return
+		return nil, err
 	}
+	return &LogzioSink{sender: sender}, nil
+}
 
-	LogzioSender.Drain() // Draining the logger
+// Send queues an event log in the Logz.io sender
+func (sink *LogzioSink) Send(event SinkEvent) error {
+	err := sink.sender.Send(event.JSON)
+	sink.mux.Lock()
+	sink.lastErr = err
+	sink.mux.Unlock()
+	return err
+}
 
+// Flush drains the queued event logs of the Logz.io sender
+func (sink *LogzioSink) Flush() error {
+	sink.sender.Drain()
+	return nil
 }
 
-func ParseEventLog(msg string, extraFields ...map[string]interface{}) (eventLog string) {
+// Close stops the Logz.io sender, after draining its queued event logs
+func (sink *LogzioSink) Close() error {
+	sink.sender.Stop()
+	return nil
+}
+
+// Health returns the last error of queueing an event log in the Logz.io sender
+func (sink *LogzioSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// parseEventLogFields parses a log message and extra fields into the fields of an event log, within the Logz.io limits
+func parseEventLogFields(msg string, extraFields ...map[string]interface{}) (parsedLogMap map[string]interface{}) {
 
-	var parsedEventLog []byte
 	var logMap map[string]interface{}
 
 	// Reading environment variables
@@ -81,7 +134,7 @@ func ParseEventLog(msg string, extraFields ...map[string]interface{}) (eventLog
 	if err != nil {
 		// If there is an error in unmarshaling, log the error
 		log.Printf("\n[ERROR] Failed to parse event log:\n%v\nERROR:\n%v", logEvent, err)
-		return
+		return nil
 	}
 
 	// Merge ExtraFields into logMap
@@ -89,40 +142,43 @@ func ParseEventLog(msg string, extraFields ...map[string]interface{}) (eventLog
 		logMap[key] = value
 	}
 	// Parse the log map to fit logz.io limits
-	parsedLogMap := parseLogzioLimits(logMap)
+	return parseLogzioLimits(logMap)
+}
+
+// ParseEventLog parses a log message and extra fields into a JSON event log, within the Logz.io limits
+func ParseEventLog(msg string, extraFields ...map[string]interface{}) (eventLog string) {
+	parsedLogMap := parseEventLogFields(msg, extraFields...)
+	if parsedLogMap == nil {
+		return
+	}
 
 	// Marshal the parsed log map into a byte slice
-	parsedEventLog, err = json.Marshal(parsedLogMap)
+	parsedEventLog, err := json.Marshal(parsedLogMap)
 	if err != nil {
 		// If there is an error in marshaling, log the error
-		log.Printf("\n[ERROR] Failed to parse event log:\n%v\nERROR:\n%v", logEvent, err)
+		log.Printf("\n[ERROR] Failed to parse event log:\n%v\nERROR:\n%v", parsedLogMap, err)
 	}
 
 	return string(parsedEventLog)
 }
 
-// SendLog sends a log message and any extra fields to Logz.io.
+// SendLog sends a log message and any extra fields to the configured sinks.
 func SendLog(msg string, extraFields ...map[string]interface{}) {
 
-	if LogzioSender != nil {
-		var senderWG sync.WaitGroup // Create a new WaitGroup here
-		var eventLog string
-		if len(extraFields) > 0 {
-			eventLog = ParseEventLog(msg, extraFields[0])
-		} else {
-			eventLog = ParseEventLog(msg)
+	if hasSinks() {
+		parsedLogMap := parseEventLogFields(msg, extraFields...)
+		if parsedLogMap == nil {
+			return
 		}
-		if eventLog == "" {
+		eventLog, err := json.Marshal(parsedLogMap)
+		if err != nil {
+			// If there is an error in marshaling, log the error
+			log.Printf("\n[ERROR] Failed to parse event log:\n%v\nERROR:\n%v", parsedLogMap, err)
 			return
 		}
 
-		senderWG.Add(1) // Increment the WaitGroup
-		go func() {
-			defer senderWG.Done() // Decrement the WaitGroup when the goroutine finishes
-			shipLogEvent(eventLog)
-
-		}()
-
-		senderWG.Wait() // Wait for all goroutines to finish
+		// Logging the event
+		log.Printf("\n[LOG]: %s\n", eventLog)
+		dispatchEvent(SinkEvent{Fields: parsedLogMap, JSON: eventLog})
 	}
 }
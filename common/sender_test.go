@@ -67,6 +67,12 @@ func TestConfigureLogzioSender(t *testing.T) {
 // TestSendLog is used to test the Logz.io sender functionality.
 func TestSendLog(t *testing.T) {
 
+	// Send the event logs to the Logz.io sender configured by TestConfigureLogzioSender
+	if LogzioSender != nil {
+		AddSink(SinkTypeLogzio, SinkTypeLogzio, &LogzioSink{sender: LogzioSender}, SinkFilter{}, 0)
+		t.Cleanup(func() { CloseSinks(DefaultSinkCloseTimeout) })
+	}
+
 	t.Run("SendLog", func(t *testing.T) {
 		os.Setenv("ENV_ID", "dev")
 		os.Setenv("LOG_TYPE", "logzio-k8s-events-test")
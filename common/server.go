@@ -0,0 +1,135 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LoadTLSCertificate loads a serving certificate and key from PEM files
+func LoadTLSCertificate(certFile, keyFile string) (certificate tls.Certificate, err error) {
+	certificate, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return certificate, fmt.Errorf("failed to load TLS certificate %s and key %s: %w", certFile, keyFile, err)
+	}
+	return certificate, nil
+}
+
+// NewTLSServer creates an HTTPS server for the handler. If a client CA file is set,
+// clients must present a certificate signed by that CA.
+func NewTLSServer(address string, handler http.Handler, certificate tls.Certificate, clientCAFile string) (server *http.Server, err error) {
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		clientCA, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file %s: %w", clientCAFile, err)
+		}
+		clientCAPool := x509.NewCertPool()
+		if !clientCAPool.AppendCertsFromPEM(clientCA) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	server = &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server, nil
+}
+
+// ServeTLS serves an HTTPS server in the background, logging the error if it stops unexpectedly
+func ServeTLS(name string, server *http.Server) {
+	go func() {
+		log.Printf("Starting %s on %s", name, server.Addr)
+		// The certificates are already set in the TLS configuration
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] %s stopped.\nERROR:\n%v", name, err)
+		}
+	}()
+}
+
+// Serve serves an HTTP server in the background, logging the error if it stops unexpectedly
+func Serve(name string, server *http.Server) {
+	go func() {
+		log.Printf("Starting %s on %s", name, server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] %s stopped.\nERROR:\n%v", name, err)
+		}
+	}()
+}
+
+// observabilityMuxes are the handlers of the HTTP servers of the metrics and health endpoints, by listen address
+var observabilityMuxes = map[string]*http.ServeMux{}
+var observabilityMuxesMux sync.Mutex
+
+// observabilityMux returns the handler of the HTTP server of the metrics and health endpoints of an address,
+// and starts the server with the first endpoint of the address
+func observabilityMux(address string) *http.ServeMux {
+	observabilityMuxesMux.Lock()
+	defer observabilityMuxesMux.Unlock()
+	mux, ok := observabilityMuxes[address]
+	if !ok {
+		mux = http.NewServeMux()
+		observabilityMuxes[address] = mux
+		Serve("observability endpoint", &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second})
+	}
+	return mux
+}
+
+// GenerateSelfSignedCertificate generates a self-signed serving certificate for the DNS names,
+// and returns it together with its PEM encoding to be used as a CA bundle
+func GenerateSelfSignedCertificate(dnsNames []string, validity time.Duration) (certificate tls.Certificate, certificatePEM []byte, err error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return certificate, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return certificate, nil, fmt.Errorf("failed to generate certificate serial number: %w", err)
+	}
+
+	notBefore := time.Now().Add(-time.Minute)
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		// The certificate is its own CA
+		IsCA: true,
+	}
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return certificate, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	privateKeyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return certificate, nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	certificatePEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER})
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKeyDER})
+	certificate, err = tls.X509KeyPair(certificatePEM, privateKeyPEM)
+	return certificate, certificatePEM, err
+}
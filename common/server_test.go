@@ -0,0 +1,83 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestLoadTLSCertificateMissing tests that missing certificate files are reported
+func TestLoadTLSCertificateMissing(t *testing.T) {
+	if _, err := LoadTLSCertificate("missing.crt", "missing.key"); err == nil {
+		t.Error("Expected an error for missing certificate files")
+	}
+}
+
+// TestNewTLSServer tests the TLS configuration of the server
+func TestNewTLSServer(t *testing.T) {
+	server, err := NewTLSServer(":8443", http.NotFoundHandler(), tls.Certificate{}, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if server.TLSConfig.MinVersion != tls.VersionTLS12 || server.TLSConfig.ClientAuth != tls.NoClientCert {
+		t.Errorf("Unexpected TLS configuration: %+v", server.TLSConfig)
+	}
+
+	if _, err = NewTLSServer(":8443", http.NotFoundHandler(), tls.Certificate{}, "missing-ca.crt"); err == nil {
+		t.Error("Expected an error for a missing client CA file")
+	}
+}
+
+// TestGenerateSelfSignedCertificate tests that the generated certificate is valid for its DNS names
+func TestGenerateSelfSignedCertificate(t *testing.T) {
+	certificate, certificatePEM, err := GenerateSelfSignedCertificate([]string{"k8s-events.monitoring.svc"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(certificatePEM) {
+		t.Fatal("Failed to parse certificate PEM")
+	}
+	if _, err = leaf.Verify(x509.VerifyOptions{DNSName: "k8s-events.monitoring.svc", Roots: roots}); err != nil {
+		t.Errorf("Expected the certificate to verify, got %v", err)
+	}
+}
+
+// TestObservabilityServer tests serving the metrics and health probes with the same address by one server
+func TestObservabilityServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	config := Config
+	t.Cleanup(func() { Config = config })
+	Config.Metrics = &MetricsConfig{Address: address}
+	Config.Health = &HealthConfig{Address: address}
+	StartMetricsServer()
+	StartHealthServer()
+
+	for path, expectedStatus := range map[string]int{DefaultMetricsPath: http.StatusOK, LivenessPath: http.StatusOK, ReadinessPath: http.StatusServiceUnavailable} {
+		var response *http.Response
+		deadline := time.Now().Add(5 * time.Second)
+		for response, err = http.Get("http://" + address + path); err != nil && time.Now().Before(deadline); response, err = http.Get("http://" + address + path) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", path, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expectedStatus {
+			t.Errorf("Expected status %d of %s, got %d", expectedStatus, path, response.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// objectVersionEventTypes are the event types with one event per object version
+var objectVersionEventTypes = []string{EventTypeAdded, EventTypeModified, EventTypeDeleted}
+
+// Sink is a destination of event logs. Each sink is sent event logs from a queue of its own,
+// so a slow or failing sink doesn't block the others.
+type Sink interface {
+	// Send sends an event log
+	Send(event SinkEvent) error
+	// Flush sends the event logs buffered by the sink
+	Flush() error
+	// Close flushes the sink and releases its resources
+	Close() error
+	// Health returns the last error of the sink, or nil if it is healthy
+	Health() error
+}
+
+// SinkEvent is an event log sent to the sinks
+type SinkEvent struct {
+	// Fields are the parsed fields of the event log, including its message and type
+	Fields map[string]interface{}
+	// JSON is the JSON encoding of the fields, as sent to Logz.io
+	JSON []byte
+}
+
+// Message returns the message of the event log
+func (event SinkEvent) Message() string {
+	message, _ := event.Fields["message"].(string)
+	return message
+}
+
+// EventType returns the event type of the event log, or an empty string for logs of the collector
+func (event SinkEvent) EventType() string {
+	eventType, _ := event.Fields["eventType"].(string)
+	return eventType
+}
+
+// Kind returns the kind of the event resource
+func (event SinkEvent) Kind() string {
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	kind, _ := newObject["kind"].(string)
+	return kind
+}
+
+// Name returns the name of the event resource, or of the Helm release of release events
+func (event SinkEvent) Name() string {
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	metadata, _ := newObject[Metadata].(map[string]interface{})
+	if name, ok := metadata["name"].(string); ok {
+		return name
+	}
+	helmRelease, _ := event.Fields["helmRelease"].(map[string]interface{})
+	name, _ := helmRelease["name"].(string)
+	return name
+}
+
+// Namespace returns the namespace of the event resource, or of the Helm release of release events
+func (event SinkEvent) Namespace() string {
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	metadata, _ := newObject[Metadata].(map[string]interface{})
+	if namespace, ok := metadata["namespace"].(string); ok {
+		return namespace
+	}
+	helmRelease, _ := event.Fields["helmRelease"].(map[string]interface{})
+	namespace, _ := helmRelease["namespace"].(string)
+	return namespace
+}
+
+// ID returns a stable id of the event log. Events of an object version are identified by the object uid and resource
+// version, events derived from an object version, such as status transitions, add a hash of their type and message,
+// and events without an object version are identified by a hash of the event log.
+func (event SinkEvent) ID() string {
+	newObject, _ := event.Fields["newObject"].(map[string]interface{})
+	metadata, _ := newObject[Metadata].(map[string]interface{})
+	uid, _ := metadata["uid"].(string)
+	resourceVersion, _ := metadata["resourceVersion"].(string)
+
+	eventType := event.EventType()
+	hash := fnv.New64a()
+	hash.Write([]byte(eventType + "\n" + event.Message()))
+	if uid == "" || resourceVersion == "" {
+		hash.Write(event.JSON)
+		return strconv.FormatUint(hash.Sum64(), 16)
+	}
+	if slices.Contains(objectVersionEventTypes, eventType) {
+		return uid + "-" + resourceVersion
+	}
+	return uid + "-" + resourceVersion + "-" + strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// Matches checks whether an event log passes the filter
+func (filter SinkFilter) Matches(event SinkEvent) bool {
+	if len(filter.EventTypes) > 0 && !slices.Contains(filter.EventTypes, event.EventType()) {
+		return false
+	}
+	if len(filter.Kinds) > 0 && !slices.Contains(filter.Kinds, event.Kind()) {
+		return false
+	}
+	if len(filter.Namespaces) > 0 && !slices.Contains(filter.Namespaces, event.Namespace()) {
+		return false
+	}
+	return true
+}
+
+// SinkFactory creates a sink from its configuration
+type SinkFactory func(config SinkConfig) (Sink, error)
+
+// sinkFactories are the registered sink types
+var sinkFactories = map[string]SinkFactory{}
+var sinkFactoriesMux sync.RWMutex
+
+// RegisterSinkType registers the factory of a sink type, so sinks of the type can be configured
+func RegisterSinkType(sinkType string, factory SinkFactory) {
+	sinkFactoriesMux.Lock()
+	defer sinkFactoriesMux.Unlock()
+	sinkFactories[sinkType] = factory
+}
+
+// SinkStatus is the health and counters of a sink
+type SinkStatus struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Healthy   bool   `json:"healthy"`
+	LastError string `json:"lastError,omitempty"`
+	Queued    int    `json:"queued"`
+	Sent      int64  `json:"sent"`
+	Failed    int64  `json:"failed"`
+	Dropped   int64  `json:"dropped"`
+	// FailingSince is when the sink started failing, if its last request failed
+	FailingSince *time.Time `json:"failingSince,omitempty"`
+}
+
+// sinkWorker sends the event logs of a sink from its queue
+type sinkWorker struct {
+	name         string
+	sinkType     string
+	sink         Sink
+	filter       SinkFilter
+	queue        chan SinkEvent
+	done         chan struct{}
+	sent         atomic.Int64
+	failed       atomic.Int64
+	dropped      atomic.Int64
+	mux          sync.Mutex
+	lastErr      error
+	failingSince time.Time
+}
+
+// sinkWorkers are the configured sinks
+var sinkWorkers []*sinkWorker
+var sinkWorkersMux sync.RWMutex
+
+// AddSink starts sending the event logs that pass a filter to a sink
+func AddSink(name string, sinkType string, sink Sink, filter SinkFilter, queueSize int) {
+	if queueSize <= 0 {
+		queueSize = DefaultSinkQueueSize
+	}
+	worker := &sinkWorker{
+		name:     name,
+		sinkType: sinkType,
+		sink:     sink,
+		filter:   filter,
+		queue:    make(chan SinkEvent, queueSize),
+		done:     make(chan struct{}),
+	}
+	go worker.run()
+
+	sinkWorkersMux.Lock()
+	defer sinkWorkersMux.Unlock()
+	sinkWorkers = append(sinkWorkers, worker)
+}
+
+// run sends the queued event logs to the sink until the queue is closed, and flushes the sink whenever its queue is empty
+func (worker *sinkWorker) run() {
+	defer close(worker.done)
+	for event := range worker.queue {
+		err := worker.sink.Send(event)
+		if err != nil {
+			worker.failed.Add(1)
+			log.Printf("[ERROR] Failed to send event log to sink: %s.\nERROR:\n%v", worker.name, err)
+		} else {
+			shipEvent(worker.name, event)
+			worker.sent.Add(1)
+		}
+		if err == nil && len(worker.queue) == 0 {
+			if err = worker.sink.Flush(); err != nil {
+				log.Printf("[ERROR] Failed to flush sink: %s.\nERROR:\n%v", worker.name, err)
+			}
+		}
+		worker.mux.Lock()
+		worker.lastErr = err
+		if err == nil {
+			worker.failingSince = time.Time{}
+		} else if worker.failingSince.IsZero() {
+			worker.failingSince = time.Now()
+		}
+		worker.mux.Unlock()
+	}
+}
+
+// status returns the health and counters of the sink
+func (worker *sinkWorker) status() (status SinkStatus) {
+	status = SinkStatus{
+		Name:    worker.name,
+		Type:    worker.sinkType,
+		Queued:  len(worker.queue),
+		Sent:    worker.sent.Load(),
+		Failed:  worker.failed.Load(),
+		Dropped: worker.dropped.Load(),
+	}
+	worker.mux.Lock()
+	if !worker.failingSince.IsZero() {
+		failingSince := worker.failingSince
+		status.FailingSince = &failingSince
+	}
+	lastErr := worker.lastErr
+	worker.mux.Unlock()
+	err := worker.sink.Health()
+	if err == nil {
+		err = lastErr
+	}
+	status.Healthy = err == nil
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	return status
+}
+
+// ConfigureSinks creates the configured sinks, or the Logz.io sender if no sinks are configured
+func ConfigureSinks() {
+	if len(Config.Sinks) == 0 {
+		ConfigureLogzioSender()
+		AddSink(SinkTypeLogzio, SinkTypeLogzio, &LogzioSink{sender: LogzioSender}, SinkFilter{}, 0)
+		return
+	}
+
+	for sinkIndex, sinkConfig := range Config.Sinks {
+		if sinkConfig.Name == "" {
+			sinkConfig.Name = fmt.Sprintf("%s-%d", sinkConfig.Type, sinkIndex)
+		}
+		sinkFactoriesMux.RLock()
+		factory, ok := sinkFactories[sinkConfig.Type]
+		sinkFactoriesMux.RUnlock()
+		if !ok {
+			log.Fatalf("\n[FATAL] Unknown type: %s of sink: %s.\n", sinkConfig.Type, sinkConfig.Name)
+		}
+		sink, err := factory(sinkConfig)
+		if err != nil {
+			log.Fatalf("\n[FATAL] Failed to configure sink: %s.\nERROR: %v\n", sinkConfig.Name, err)
+		}
+		AddSink(sinkConfig.Name, sinkConfig.Type, sink, sinkConfig.Filter, sinkConfig.QueueSize)
+		log.Printf("Configured sink: %s of type: %s", sinkConfig.Name, sinkConfig.Type)
+	}
+}
+
+// dispatchEvent queues an event log for each sink whose filter it passes, event logs of full queues are dropped
+func dispatchEvent(event SinkEvent) {
+	sinkWorkersMux.RLock()
+	defer sinkWorkersMux.RUnlock()
+	for _, worker := range sinkWorkers {
+		if !worker.filter.Matches(event) {
+			continue
+		}
+		select {
+		case worker.queue <- event:
+		default:
+			worker.dropped.Add(1)
+			log.Printf("[ERROR] Queue of sink: %s is full, dropping event log.", worker.name)
+		}
+	}
+}
+
+// hasSinks checks whether any sinks are configured
+func hasSinks() bool {
+	sinkWorkersMux.RLock()
+	defer sinkWorkersMux.RUnlock()
+	return len(sinkWorkers) > 0
+}
+
+// SinkStatuses returns the health and counters of the configured sinks
+func SinkStatuses() (statuses []SinkStatus) {
+	sinkWorkersMux.RLock()
+	defer sinkWorkersMux.RUnlock()
+	for _, worker := range sinkWorkers {
+		statuses = append(statuses, worker.status())
+	}
+	return statuses
+}
+
+// CloseSinks stops queueing event logs, waits up to a timeout for the sinks to send their queued event logs, and closes them.
+// Sinks that are still sending after the timeout aren't closed, as sinks may not be closed during a Send.
+func CloseSinks(timeout time.Duration) {
+	sinkWorkersMux.Lock()
+	workers := sinkWorkers
+	sinkWorkers = nil
+	sinkWorkersMux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, worker := range workers {
+		close(worker.queue)
+	}
+	for _, worker := range workers {
+		select {
+		case <-worker.done:
+		case <-ctx.Done():
+			log.Printf("[ERROR] Timed out sending the queued event logs of sink: %s, dropping %d event logs.", worker.name, len(worker.queue))
+			continue
+		}
+		if err := worker.sink.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close sink: %s.\nERROR:\n%v", worker.name, err)
+		}
+	}
+}
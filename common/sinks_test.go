@@ -0,0 +1,201 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink records the event logs sent to it
+type testSink struct {
+	mux     sync.Mutex
+	events  []SinkEvent
+	flushes int
+	closed  bool
+	sendErr error
+	block   chan struct{}
+}
+
+// Send records an event log, after the sink is unblocked
+func (sink *testSink) Send(event SinkEvent) error {
+	if sink.block != nil {
+		<-sink.block
+	}
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.events = append(sink.events, event)
+	return sink.sendErr
+}
+
+// Flush counts the flushes of the sink
+func (sink *testSink) Flush() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.flushes++
+	return nil
+}
+
+// Close marks the sink as closed
+func (sink *testSink) Close() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.closed = true
+	return nil
+}
+
+// Health reports the sink as healthy
+func (sink *testSink) Health() error {
+	return nil
+}
+
+// eventCount returns the number of event logs sent to the sink
+func (sink *testSink) eventCount() int {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return len(sink.events)
+}
+
+// waitForEvents waits for a sink to be sent a number of event logs
+func waitForEvents(t *testing.T, sink *testSink, count int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.eventCount() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d event logs, got %d", count, sink.eventCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// sinkStatus returns the status of a sink by name
+func sinkStatus(t *testing.T, name string) SinkStatus {
+	for _, status := range SinkStatuses() {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("Sink: %s isn't configured", name)
+	return SinkStatus{}
+}
+
+// TestSinkFilter tests filtering event logs by event type, kind and namespace
+func TestSinkFilter(t *testing.T) {
+	event := getTestSinkEvent(EventTypeModified, "Deployment", "test", "default", nil)
+	if event.EventType() != EventTypeModified || event.Kind() != "Deployment" || event.Namespace() != "default" || event.Name() != "test" {
+		t.Fatalf("Unexpected event log fields: %+v", event.Fields)
+	}
+
+	testCases := []struct {
+		filter   SinkFilter
+		expected bool
+	}{
+		{SinkFilter{}, true},
+		{SinkFilter{EventTypes: []string{EventTypeModified, EventTypeAdded}}, true},
+		{SinkFilter{EventTypes: []string{EventTypeDeleted}}, false},
+		{SinkFilter{Kinds: []string{"Deployment"}, Namespaces: []string{"default"}}, true},
+		{SinkFilter{Kinds: []string{"Deployment"}, Namespaces: []string{"kube-system"}}, false},
+	}
+	for _, testCase := range testCases {
+		if matches := testCase.filter.Matches(event); matches != testCase.expected {
+			t.Errorf("Expected filter %+v to match: %v, got %v", testCase.filter, testCase.expected, matches)
+		}
+	}
+
+	helmEvent := SinkEvent{Fields: map[string]interface{}{"eventType": EventTypeHelmRelease, "helmRelease": map[string]interface{}{"namespace": "apps"}}}
+	if !(SinkFilter{Namespaces: []string{"apps"}}).Matches(helmEvent) {
+		t.Error("Expected Helm release events to match the namespace of their release")
+	}
+}
+
+// TestSinkFanOut tests that event logs are sent to each matching sink, and a blocked or failing sink doesn't block the others
+func TestSinkFanOut(t *testing.T) {
+	t.Cleanup(func() { CloseSinks(time.Second) })
+	allSink, deletedSink := &testSink{}, &testSink{}
+	blockedSink := &testSink{block: make(chan struct{})}
+	failingSink := &testSink{sendErr: errors.New("unavailable")}
+	AddSink("all", "test", allSink, SinkFilter{}, 0)
+	AddSink("deleted", "test", deletedSink, SinkFilter{EventTypes: []string{EventTypeDeleted}}, 0)
+	AddSink("blocked", "test", blockedSink, SinkFilter{}, 1)
+	AddSink("failing", "test", failingSink, SinkFilter{}, 0)
+
+	// Wait for the blocked sink to take the first event log from its queue
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+	for sinkStatus(t, "blocked").Queued > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+	}
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeDeleted})
+
+	waitForEvents(t, allSink, 6)
+	waitForEvents(t, deletedSink, 1)
+	waitForEvents(t, failingSink, 6)
+
+	if status := sinkStatus(t, "all"); !status.Healthy || status.Sent != 6 || status.Dropped != 0 {
+		t.Errorf("Unexpected status of the healthy sink: %+v", status)
+	}
+	if status := sinkStatus(t, "failing"); status.Healthy || status.Failed != 6 || status.LastError != "unavailable" {
+		t.Errorf("Unexpected status of the failing sink: %+v", status)
+	}
+	// The blocked sink holds one event log in Send and one in its queue
+	if status := sinkStatus(t, "blocked"); status.Dropped != 4 {
+		t.Errorf("Expected the blocked sink to drop 4 event logs, got %+v", status)
+	}
+	if deletedSink.events[0].EventType() != EventTypeDeleted {
+		t.Errorf("Expected only deleted event logs, got %+v", deletedSink.events[0].Fields)
+	}
+
+	close(blockedSink.block)
+	CloseSinks(time.Second)
+	if !allSink.closed || !blockedSink.closed || allSink.flushes == 0 {
+		t.Errorf("Expected the sinks to be flushed and closed")
+	}
+	if blockedSink.eventCount() != 2 {
+		t.Errorf("Expected the queued event logs of the blocked sink to be sent on close, got %d", blockedSink.eventCount())
+	}
+	if statuses := SinkStatuses(); statuses != nil {
+		t.Errorf("Expected no sinks after closing, got %+v", statuses)
+	}
+}
+
+// TestCloseSinksTimeout tests that a sink still sending after the close timeout isn't closed during its Send
+func TestCloseSinksTimeout(t *testing.T) {
+	blockedSink := &testSink{block: make(chan struct{})}
+	AddSink("blocked", "test", blockedSink, SinkFilter{}, 0)
+	SendLog("Test log", map[string]interface{}{"eventType": EventTypeAdded})
+
+	CloseSinks(10 * time.Millisecond)
+	blockedSink.mux.Lock()
+	closed := blockedSink.closed
+	blockedSink.mux.Unlock()
+	if closed {
+		t.Error("Expected the sink not to be closed while it is sending")
+	}
+	close(blockedSink.block)
+	waitForEvents(t, blockedSink, 1)
+}
+
+// TestConfigureSinks tests creating the configured sinks with their registered factories
+func TestConfigureSinks(t *testing.T) {
+	var configs []SinkConfig
+	RegisterSinkType("test", func(config SinkConfig) (Sink, error) {
+		configs = append(configs, config)
+		return &testSink{}, nil
+	})
+	Config.Sinks = []SinkConfig{{Type: "test", QueueSize: 10}, {Name: "named", Type: "test", Filter: SinkFilter{Kinds: []string{"Secret"}}}}
+	t.Cleanup(func() {
+		Config.Sinks = nil
+		CloseSinks(time.Second)
+	})
+
+	ConfigureSinks()
+	var names []string
+	for _, status := range SinkStatuses() {
+		names = append(names, status.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"test-0", "named"}) || len(configs) != 2 || configs[1].Filter.Kinds[0] != "Secret" {
+		t.Errorf("Unexpected configured sinks: %v of configurations: %+v", names, configs)
+	}
+}
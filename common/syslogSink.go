@@ -0,0 +1,322 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog networks
+const (
+	SyslogNetworkUDP = "udp"
+	SyslogNetworkTCP = "tcp"
+	SyslogNetworkTLS = "tls"
+)
+
+// Syslog message bodies
+const (
+	SyslogBodyMessage = "message"
+	SyslogBodyJSON    = "json"
+)
+
+// syslogMaxUDPMessageBytes is the maximal payload of a UDP datagram, longer messages are truncated
+const syslogMaxUDPMessageBytes = 65507
+
+// syslogTimestampFormat is the RFC 5424 timestamp, with at most microseconds
+const syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// syslogFacilities are the facility codes by name
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities are the severity codes by name
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// defaultSyslogSeverities are the severities of event types and change categories, other events are "info"
+var defaultSyslogSeverities = map[string]string{
+	EventTypeRejected: "warning",
+	EventTypeDeleted:  "notice",
+	// Change categories of RBAC objects, pod security and credentials
+	"rbacRules":       "warning",
+	"roleRef":         "warning",
+	"subjects":        "warning",
+	"securityContext": "notice",
+	"serviceAccount":  "notice",
+	"secrets":         "notice",
+}
+
+// SyslogSink sends event logs as RFC 5424 syslog messages over UDP, or over TCP or TLS with octet-counting framing
+type SyslogSink struct {
+	network          string
+	address          string
+	facility         int
+	appName          string
+	hostname         string
+	procID           string
+	structuredDataID string
+	body             string
+	severities       map[string]int
+	timeout          time.Duration
+	tlsConfig        *tls.Config
+	mux              sync.Mutex
+	conn             net.Conn
+	lastErr          error
+}
+
+// init registers the syslog sink type
+func init() {
+	RegisterSinkType(SinkTypeSyslog, NewSyslogSink)
+}
+
+// NewSyslogSink creates a syslog sink, the connection is opened with the first event log
+func NewSyslogSink(config SinkConfig) (Sink, error) {
+	if config.Syslog == nil || config.Syslog.Address == "" {
+		return nil, fmt.Errorf("no address is configured for the syslog sink")
+	}
+	sinkConfig := *config.Syslog
+	sink := &SyslogSink{
+		network:          sinkConfig.Network,
+		address:          sinkConfig.Address,
+		appName:          sinkConfig.AppName,
+		hostname:         sinkConfig.Hostname,
+		procID:           strconv.Itoa(os.Getpid()),
+		structuredDataID: sinkConfig.StructuredDataID,
+		body:             sinkConfig.Body,
+		severities:       map[string]int{},
+		timeout:          sinkConfig.Timeout.Duration,
+	}
+	if sink.network == "" {
+		sink.network = SyslogNetworkUDP
+	}
+	if sink.network != SyslogNetworkUDP && sink.network != SyslogNetworkTCP && sink.network != SyslogNetworkTLS {
+		return nil, fmt.Errorf("unknown syslog network: %s", sink.network)
+	}
+	facility := sinkConfig.Facility
+	if facility == "" {
+		facility = DefaultSyslogFacility
+	}
+	var ok bool
+	if sink.facility, ok = syslogFacilities[facility]; !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", facility)
+	}
+	if sink.appName == "" {
+		sink.appName = DefaultLogType
+	}
+	if sink.hostname == "" {
+		sink.hostname, _ = os.Hostname()
+	}
+	if sink.structuredDataID == "" {
+		sink.structuredDataID = DefaultSyslogStructuredDataID
+	}
+	if sink.body == "" {
+		sink.body = SyslogBodyMessage
+	}
+	if sink.body != SyslogBodyMessage && sink.body != SyslogBodyJSON {
+		return nil, fmt.Errorf("unknown syslog body: %s", sink.body)
+	}
+	if sink.timeout <= 0 {
+		sink.timeout = DefaultSinkRequestTimeout
+	}
+
+	for _, severities := range []map[string]string{defaultSyslogSeverities, sinkConfig.Severities} {
+		for key, severity := range severities {
+			code, ok := syslogSeverities[severity]
+			if !ok {
+				return nil, fmt.Errorf("unknown syslog severity: %s of: %s", severity, key)
+			}
+			sink.severities[key] = code
+		}
+	}
+
+	if sink.network == SyslogNetworkTLS {
+		tlsConfig, err := syslogTLSConfig(sinkConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		sink.tlsConfig = tlsConfig
+	}
+	return sink, nil
+}
+
+// syslogTLSConfig creates the TLS configuration of the connection to the syslog server
+func syslogTLSConfig(config *SyslogTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config == nil {
+		return tlsConfig, nil
+	}
+	tlsConfig.ServerName = config.ServerName
+	if config.CAFile != "" {
+		caPEM, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read syslog CA file %s: %w", config.CAFile, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in syslog CA file %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		certificate, err := LoadTLSCertificate(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// Send sends an event log as a syslog message, and reconnects once if the connection failed
+func (sink *SyslogSink) Send(event SinkEvent) error {
+	message := sink.format(event, time.Now())
+
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	err := sink.write(message)
+	if err != nil {
+		sink.disconnect()
+		err = sink.write(message)
+		if err != nil {
+			sink.disconnect()
+		}
+	}
+	sink.lastErr = err
+	return err
+}
+
+// Flush does nothing, messages are written as they are sent
+func (sink *SyslogSink) Flush() error {
+	return nil
+}
+
+// Close closes the connection to the syslog server
+func (sink *SyslogSink) Close() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	sink.disconnect()
+	return nil
+}
+
+// Health returns the error of the last message
+func (sink *SyslogSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// write writes a message to the syslog server, connecting if needed. TCP and TLS messages are framed by octet counting.
+func (sink *SyslogSink) write(message []byte) (err error) {
+	if sink.conn == nil {
+		dialer := &net.Dialer{Timeout: sink.timeout}
+		if sink.network == SyslogNetworkTLS {
+			sink.conn, err = tls.DialWithDialer(dialer, "tcp", sink.address, sink.tlsConfig)
+		} else {
+			sink.conn, err = dialer.Dial(sink.network, sink.address)
+		}
+		if err != nil {
+			sink.conn = nil
+			return err
+		}
+	}
+
+	if sink.network == SyslogNetworkUDP {
+		if len(message) > syslogMaxUDPMessageBytes {
+			message = message[:syslogMaxUDPMessageBytes]
+		}
+	} else {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+	if err = sink.conn.SetWriteDeadline(time.Now().Add(sink.timeout)); err != nil {
+		return err
+	}
+	_, err = sink.conn.Write(message)
+	return err
+}
+
+// disconnect closes the connection, so the next message reconnects
+func (sink *SyslogSink) disconnect() {
+	if sink.conn != nil {
+		sink.conn.Close()
+		sink.conn = nil
+	}
+}
+
+// severity returns the most severe of the severities of the event type and change categories of an event log
+func (sink *SyslogSink) severity(event SinkEvent) int {
+	severity := syslogSeverities["info"]
+	if code, ok := sink.severities[event.EventType()]; ok {
+		severity = min(severity, code)
+	}
+	categories, _ := event.Fields["changeCategories"].([]interface{})
+	for _, category := range categories {
+		if name, ok := category.(string); ok {
+			if code, ok := sink.severities[name]; ok {
+				severity = min(severity, code)
+			}
+		}
+	}
+	return severity
+}
+
+// format formats an event log as an RFC 5424 syslog message, with a structured data element of the event resource
+func (sink *SyslogSink) format(event SinkEvent, now time.Time) []byte {
+	var message strings.Builder
+	priority := sink.facility*8 + sink.severity(event)
+	fmt.Fprintf(&message, "<%d>1 %s %s %s %s %s ", priority, now.UTC().Format(syslogTimestampFormat),
+		syslogHeaderField(sink.hostname, 255), syslogHeaderField(sink.appName, 48), syslogHeaderField(sink.procID, 128),
+		syslogHeaderField(event.EventType(), 32))
+
+	parameters := [][2]string{{"kind", event.Kind()}, {"namespace", event.Namespace()}, {"name", event.Name()}, {"eventType", event.EventType()}}
+	var structuredData strings.Builder
+	for _, parameter := range parameters {
+		if parameter[1] != "" {
+			fmt.Fprintf(&structuredData, " %s=\"%s\"", parameter[0], escapeSyslogParameter(parameter[1]))
+		}
+	}
+	if structuredData.Len() == 0 {
+		message.WriteString("-")
+	} else {
+		message.WriteString("[" + sink.structuredDataID + structuredData.String() + "]")
+	}
+
+	body := event.Message()
+	if sink.body == SyslogBodyJSON {
+		body = string(event.JSON)
+	}
+	if body != "" {
+		message.WriteString(" " + body)
+	}
+	return []byte(message.String())
+}
+
+// syslogHeaderField returns a header field of printable ASCII characters up to a maximal length, or the nil value "-"
+func syslogHeaderField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// escapeSyslogParameter escapes the double quotes, backslashes and closing brackets of a structured data parameter value
+func escapeSyslogParameter(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,232 @@
+package common
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readOctetCountedFrames reads octet-counted syslog frames from a connection, until a frame is malformed
+func readOctetCountedFrames(conn net.Conn, count int) (frames []string) {
+	reader := bufio.NewReader(conn)
+	for range count {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return frames
+		}
+		frameLength, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			return frames
+		}
+		frame := make([]byte, frameLength)
+		if _, err = io.ReadFull(reader, frame); err != nil {
+			return frames
+		}
+		frames = append(frames, string(frame))
+	}
+	return frames
+}
+
+// acceptFrames accepts a connection of a listener, and returns the octet-counted frames read from it
+func acceptFrames(listener net.Listener, count int) chan []string {
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		received <- readOctetCountedFrames(conn, count)
+	}()
+	return received
+}
+
+// TestSyslogFormat tests formatting event logs as RFC 5424 messages with structured data and mapped severities
+func TestSyslogFormat(t *testing.T) {
+	sink, err := NewSyslogSink(SinkConfig{Syslog: &SyslogSinkConfig{
+		Address:    "localhost:514",
+		Facility:   "auth",
+		Hostname:   "collector host",
+		Severities: map[string]string{"image": "notice"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create syslog sink: %v", err)
+	}
+	syslogSink := sink.(*SyslogSink)
+	syslogSink.procID = "7"
+	now := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name     string
+		event    SinkEvent
+		expected string
+	}{
+		{
+			name:     "modified",
+			event:    getTestSinkEvent(EventTypeModified, "Deployment", "admins", "prod", nil),
+			expected: `<38>1 2024-05-01T12:00:00.123456Z collectorhost logzio-k8s-events 7 MODIFIED [k8s@32473 kind="Deployment" namespace="prod" name="admins" eventType="MODIFIED"] [EVENT] Resource: admins of kind: Deployment in namespace: prod was MODIFIED.`,
+		},
+		{
+			name:     "rbac change category",
+			event:    getTestSinkEvent(EventTypeModified, "RoleBinding", "admins", "prod", map[string]interface{}{"changeCategories": []interface{}{"labels", "subjects"}}),
+			expected: `<36>1 `,
+		},
+		{
+			name:     "deleted",
+			event:    getTestSinkEvent(EventTypeDeleted, "Secret", "admins", "prod", nil),
+			expected: `<37>1 `,
+		},
+		{
+			name:     "configured change category",
+			event:    getTestSinkEvent(EventTypeModified, "Deployment", "admins", "prod", map[string]interface{}{"changeCategories": []interface{}{"image"}}),
+			expected: `<37>1 `,
+		},
+		{
+			name:     "collector log",
+			event:    SinkEvent{Fields: map[string]interface{}{"message": "Collector started"}},
+			expected: `<38>1 2024-05-01T12:00:00.123456Z collectorhost logzio-k8s-events 7 - - Collector started`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if message := string(syslogSink.format(test.event, now)); !strings.HasPrefix(message, test.expected) {
+				t.Errorf("Expected %s, got %s", test.expected, message)
+			}
+		})
+	}
+
+	if escaped := escapeSyslogParameter(`a"b\c]`); escaped != `a\"b\\c\]` {
+		t.Errorf("Unexpected escaped parameter: %s", escaped)
+	}
+}
+
+// TestSyslogSinkUDP tests sending messages as UDP datagrams
+func TestSyslogSinkUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	sink, _ := NewSyslogSink(SinkConfig{Syslog: &SyslogSinkConfig{Address: listener.LocalAddr().String(), Body: SyslogBodyJSON}})
+	defer sink.Close()
+
+	event := getTestSinkEvent(EventTypeAdded, "Deployment", "admins", "prod", nil)
+	if err = sink.Send(event); err != nil {
+		t.Fatalf("Failed to send event log: %v", err)
+	}
+	buffer := make([]byte, 4096)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	length, _, err := listener.ReadFrom(buffer)
+	if err != nil {
+		t.Fatalf("Failed to read datagram: %v", err)
+	}
+	if message := string(buffer[:length]); !strings.HasPrefix(message, "<134>1 ") || !strings.HasSuffix(message, "] "+string(event.JSON)) {
+		t.Errorf("Unexpected datagram: %s", message)
+	}
+}
+
+// TestSyslogSinkTCP tests sending octet-counted messages over TCP, and reconnecting after the connection is closed
+func TestSyslogSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	sink, _ := NewSyslogSink(SinkConfig{Syslog: &SyslogSinkConfig{Network: SyslogNetworkTCP, Address: listener.Addr().String()}})
+	defer sink.Close()
+
+	received := acceptFrames(listener, 2)
+	for _, eventType := range []string{EventTypeAdded, EventTypeModified} {
+		if err = sink.Send(getTestSinkEvent(eventType, "Deployment", "admins", "prod", nil)); err != nil {
+			t.Fatalf("Failed to send event log: %v", err)
+		}
+	}
+	frames := <-received
+	if len(frames) != 2 || !strings.Contains(frames[0], " ADDED [") || !strings.HasSuffix(frames[1], "was MODIFIED.") {
+		t.Errorf("Unexpected frames: %q", frames)
+	}
+
+	// The sink reconnects when the server closes the connection
+	received = acceptFrames(listener, 1)
+	sink.(*SyslogSink).conn.Close()
+	if err = sink.Send(getTestSinkEvent(EventTypeDeleted, "Deployment", "admins", "prod", nil)); err != nil {
+		t.Fatalf("Failed to send event log after reconnecting: %v", err)
+	}
+	if frames = <-received; len(frames) != 1 || !strings.Contains(frames[0], " DELETED [") {
+		t.Errorf("Unexpected frames after reconnecting: %q", frames)
+	}
+}
+
+// TestSyslogSinkTLS tests sending messages over TLS to a server verified by a custom CA
+func TestSyslogSinkTLS(t *testing.T) {
+	certificate, certificatePEM, err := GenerateSelfSignedCertificate([]string{"syslog.example.com"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, certificatePEM, 0o600)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := acceptFrames(listener, 1)
+	sink, err := NewSyslogSink(SinkConfig{Syslog: &SyslogSinkConfig{
+		Network: SyslogNetworkTLS,
+		Address: listener.Addr().String(),
+		TLS:     &SyslogTLSConfig{CAFile: caFile, ServerName: "syslog.example.com"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create syslog sink: %v", err)
+	}
+	defer sink.Close()
+	if err = sink.Send(getTestSinkEvent(EventTypeAdded, "Deployment", "admins", "prod", nil)); err != nil {
+		t.Fatalf("Failed to send event log: %v", err)
+	}
+	if frames := <-received; len(frames) != 1 || !strings.Contains(frames[0], "kind=\"Deployment\"") {
+		t.Errorf("Unexpected frames: %q", frames)
+	}
+
+	// Servers that the CA doesn't verify are rejected
+	untrusted, _ := NewSyslogSink(SinkConfig{Syslog: &SyslogSinkConfig{Network: SyslogNetworkTLS, Address: listener.Addr().String(), TLS: &SyslogTLSConfig{CAFile: caFile, ServerName: "other.example.com"}}})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	if err = untrusted.Send(getTestSinkEvent(EventTypeAdded, "Deployment", "admins", "prod", nil)); err == nil || untrusted.Health() == nil {
+		t.Error("Expected an error of an unverified server")
+	}
+}
+
+// TestNewSyslogSink tests the validation of the syslog sink configuration
+func TestNewSyslogSink(t *testing.T) {
+	invalidConfigs := map[string]SyslogSinkConfig{
+		"no address":       {},
+		"unknown network":  {Address: "localhost:514", Network: "quic"},
+		"unknown facility": {Address: "localhost:514", Facility: "local9"},
+		"unknown severity": {Address: "localhost:514", Severities: map[string]string{EventTypeDeleted: "fatal"}},
+		"unknown body":     {Address: "localhost:514", Body: "xml"},
+		"missing CA file":  {Address: "localhost:514", Network: SyslogNetworkTLS, TLS: &SyslogTLSConfig{CAFile: "/missing/ca.pem"}},
+	}
+	for name, config := range invalidConfigs {
+		if _, err := NewSyslogSink(SinkConfig{Syslog: &config}); err == nil {
+			t.Errorf("Expected an error of a configuration with %s", name)
+		}
+	}
+}
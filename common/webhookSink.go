@@ -0,0 +1,271 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WebhookTemplateData is the data of the request body templates of webhook routes
+type WebhookTemplateData struct {
+	// Event is the parsed event log
+	Event map[string]interface{}
+	// Message is the event message
+	Message string
+	// EventType is the event type, for example "MODIFIED"
+	EventType string
+	// Kind, Namespace and Name identify the event resource
+	Kind      string
+	Namespace string
+	Name      string
+	// Diffs are the unified diffs of changed ConfigMap values, by key
+	Diffs map[string]interface{}
+	// RelatedServices are the related cluster services of the event resource, by kind
+	RelatedServices map[string]interface{}
+}
+
+// webhookTemplateFuncs are the functions of the request body templates
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value as JSON, for example to quote a string in a JSON body
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+	// truncate shortens a string to a maximal number of characters
+	"truncate": func(length int, value string) string {
+		characters := []rune(value)
+		if len(characters) <= length {
+			return value
+		}
+		return string(characters[:length]) + "..."
+	},
+	// join joins the items of a list with a separator
+	"join": func(separator string, values interface{}) string {
+		var items []string
+		switch typed := values.(type) {
+		case []string:
+			items = typed
+		case []interface{}:
+			for _, item := range typed {
+				items = append(items, fmt.Sprint(item))
+			}
+		}
+		return strings.Join(items, separator)
+	},
+}
+
+// webhookRoute is a webhook route of a webhook sink
+type webhookRoute struct {
+	name        string
+	url         string
+	method      string
+	contentType string
+	filter      SinkFilter
+	template    *template.Template
+	headers     map[string]string
+	basicAuth   *WebhookBasicAuth
+	bearerToken string
+	limiter     *rate.Limiter
+	limited     atomic.Int64
+}
+
+// WebhookSink posts event logs to webhook routes, with request bodies rendered from templates
+type WebhookSink struct {
+	name           string
+	client         *http.Client
+	routes         []*webhookRoute
+	maxElapsedTime time.Duration
+	mux            sync.Mutex
+	lastErr        error
+}
+
+// init registers the webhook sink type
+func init() {
+	RegisterSinkType(SinkTypeWebhook, NewWebhookSink)
+}
+
+// NewWebhookSink creates a webhook sink, and parses the templates of its routes
+func NewWebhookSink(config SinkConfig) (Sink, error) {
+	if config.Webhook == nil || len(config.Webhook.Routes) == 0 {
+		return nil, fmt.Errorf("no routes are configured for the webhook sink")
+	}
+	sinkConfig := *config.Webhook
+	sink := &WebhookSink{name: config.Name, maxElapsedTime: sinkConfig.MaxElapsedTime.Duration}
+	if sink.maxElapsedTime <= 0 {
+		sink.maxElapsedTime = DefaultSinkMaxElapsedTime
+	}
+	timeout := sinkConfig.Timeout.Duration
+	if timeout <= 0 {
+		timeout = DefaultSinkRequestTimeout
+	}
+	sink.client = &http.Client{Timeout: timeout}
+
+	for routeIndex, routeConfig := range sinkConfig.Routes {
+		route := &webhookRoute{
+			name:        routeConfig.Name,
+			url:         routeConfig.URL,
+			method:      routeConfig.Method,
+			contentType: routeConfig.ContentType,
+			filter:      routeConfig.Filter,
+			headers:     map[string]string{},
+			bearerToken: os.ExpandEnv(routeConfig.BearerToken),
+		}
+		if route.name == "" {
+			route.name = fmt.Sprintf("route-%d", routeIndex)
+		}
+		if route.url == "" {
+			return nil, fmt.Errorf("no URL is configured for webhook route: %s", route.name)
+		}
+		if route.method == "" {
+			route.method = http.MethodPost
+		}
+		if route.contentType == "" {
+			route.contentType = "application/json"
+		}
+		bodyTemplate := routeConfig.Template
+		if bodyTemplate == "" {
+			bodyTemplate = DefaultWebhookTemplate
+		}
+		var err error
+		if route.template, err = template.New(route.name).Funcs(webhookTemplateFuncs).Option("missingkey=zero").Parse(bodyTemplate); err != nil {
+			return nil, fmt.Errorf("failed to parse template of webhook route: %s: %w", route.name, err)
+		}
+		for key, value := range routeConfig.Headers {
+			route.headers[key] = os.ExpandEnv(value)
+		}
+		if routeConfig.BasicAuth != nil {
+			route.basicAuth = &WebhookBasicAuth{Username: os.ExpandEnv(routeConfig.BasicAuth.Username), Password: os.ExpandEnv(routeConfig.BasicAuth.Password)}
+		}
+		if rateLimit := routeConfig.RateLimit; rateLimit != nil {
+			if rateLimit.Events <= 0 || rateLimit.Interval.Duration <= 0 {
+				return nil, fmt.Errorf("invalid rate limit of webhook route: %s, events and interval must be positive", route.name)
+			}
+			route.limiter = rate.NewLimiter(rate.Every(rateLimit.Interval.Duration/time.Duration(rateLimit.Events)), rateLimit.Events)
+		}
+		sink.routes = append(sink.routes, route)
+	}
+	return sink, nil
+}
+
+// Send posts an event log to each route whose filter and rate limit it passes
+func (sink *WebhookSink) Send(event SinkEvent) error {
+	var errs []error
+	for _, route := range sink.routes {
+		if !route.filter.Matches(event) || !route.allow() {
+			continue
+		}
+		body, err := route.render(event)
+		if err == nil {
+			err = retryWithBackoff(sink.name, sink.maxElapsedTime, func() error {
+				return sink.post(route, body)
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook route: %s: %w", route.name, err))
+		}
+	}
+
+	err := errors.Join(errs...)
+	sink.mux.Lock()
+	sink.lastErr = err
+	sink.mux.Unlock()
+	return err
+}
+
+// Flush does nothing, event logs are posted as they are sent
+func (sink *WebhookSink) Flush() error {
+	return nil
+}
+
+// Close does nothing, event logs are posted as they are sent
+func (sink *WebhookSink) Close() error {
+	return nil
+}
+
+// Health returns the error of the last event log
+func (sink *WebhookSink) Health() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.lastErr
+}
+
+// allow checks the rate limit of the route, and logs when the route starts and stops dropping event logs
+func (route *webhookRoute) allow() bool {
+	if route.limiter == nil {
+		return true
+	}
+	if !route.limiter.Allow() {
+		if route.limited.Add(1) == 1 {
+			log.Printf("[ERROR] Rate limit of webhook route: %s is exceeded, dropping event logs.", route.name)
+		}
+		return false
+	}
+	if limited := route.limited.Swap(0); limited > 0 {
+		log.Printf("Rate limit of webhook route: %s dropped %d event logs.", route.name, limited)
+	}
+	return true
+}
+
+// render renders the request body of an event log from the template of the route
+func (route *webhookRoute) render(event SinkEvent) ([]byte, error) {
+	configMapData, _ := event.Fields["configMapData"].(map[string]interface{})
+	diffs, _ := configMapData["diffs"].(map[string]interface{})
+	relatedServices, _ := event.Fields["relatedClusterServices"].(map[string]interface{})
+	data := WebhookTemplateData{
+		Event:           event.Fields,
+		Message:         event.Message(),
+		EventType:       event.EventType(),
+		Kind:            event.Kind(),
+		Namespace:       event.Namespace(),
+		Name:            event.Name(),
+		Diffs:           diffs,
+		RelatedServices: relatedServices,
+	}
+
+	var body bytes.Buffer
+	if err := route.template.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+// post sends a request body to the route
+func (sink *WebhookSink) post(route *webhookRoute, body []byte) error {
+	request, err := http.NewRequest(route.method, route.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", route.contentType)
+	for key, value := range route.headers {
+		request.Header.Set(key, value)
+	}
+	if route.basicAuth != nil {
+		request.SetBasicAuth(route.basicAuth.Username, route.basicAuth.Password)
+	}
+	if route.bearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+route.bearerToken)
+	}
+
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return newNetworkSinkError(err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	return newHTTPSinkError(response, strings.TrimSpace(string(responseBody)))
+}
@@ -0,0 +1,160 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestWebhookSinkRoutes tests posting event logs to the routes whose filters they pass, with rendered bodies and authentication
+func TestWebhookSinkRoutes(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_TOKEN", "secret-token")
+	webhook := newTestHTTPReceiver(t, nil)
+	sink, err := NewWebhookSink(SinkConfig{Webhook: &WebhookSinkConfig{Routes: []WebhookRouteConfig{
+		{
+			Name:        "rbac",
+			URL:         webhook.server.URL + "/rbac",
+			Filter:      SinkFilter{Kinds: []string{"RoleBinding", "ClusterRoleBinding"}, Namespaces: []string{"prod"}},
+			BearerToken: "${TEST_WEBHOOK_TOKEN}",
+		},
+		{
+			Name:      "secrets",
+			URL:       webhook.server.URL + "/secrets",
+			Filter:    SinkFilter{Kinds: []string{"Secret"}, EventTypes: []string{EventTypeDeleted}},
+			Template:  `{"title": {{ json (printf "%s %s/%s" .EventType .Namespace .Name) }}}`,
+			BasicAuth: &WebhookBasicAuth{Username: "bot", Password: "${TEST_WEBHOOK_TOKEN}"},
+			Headers:   map[string]string{"X-Team": "platform"},
+		},
+		{
+			Name:        "configs",
+			URL:         webhook.server.URL + "/configs",
+			Filter:      SinkFilter{Kinds: []string{"ConfigMap"}},
+			ContentType: "text/plain",
+			Template:    `{{ .Name }}: {{ join ", " .RelatedServices.deployments }}{{ range $key, $diff := .Diffs }} {{ $key }}={{ truncate 10 $diff }}{{ end }}`,
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("Failed to create webhook sink: %v", err)
+	}
+
+	configMapEvent := getTestSinkEvent(EventTypeModified, "ConfigMap", "settings", "prod", nil)
+	configMapEvent.Fields["relatedClusterServices"] = map[string]interface{}{"deployments": []interface{}{"web", "worker"}}
+	configMapEvent.Fields["configMapData"] = map[string]interface{}{"diffs": map[string]interface{}{"app.yaml": "--- app.yaml\n+++ app.yaml"}}
+	events := []SinkEvent{
+		getTestSinkEvent(EventTypeModified, "RoleBinding", "admins", "prod", nil),
+		getTestSinkEvent(EventTypeModified, "RoleBinding", "admins", "staging", nil),
+		getTestSinkEvent(EventTypeModified, "Secret", "credentials", "prod", nil),
+		getTestSinkEvent(EventTypeDeleted, "Secret", "credentials", "prod", nil),
+		configMapEvent,
+	}
+	for _, event := range events {
+		if err = sink.Send(event); err != nil {
+			t.Fatalf("Failed to send event log: %v", err)
+		}
+	}
+
+	requests := webhook.received()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d: %+v", len(requests), requests)
+	}
+	var rbacBody map[string]string
+	if err = json.Unmarshal(requests[0].body, &rbacBody); err != nil || rbacBody["text"] != events[0].Message() {
+		t.Errorf("Expected the default template body, got %s", requests[0].body)
+	}
+	if requests[0].path != "/rbac" || requests[0].headers.Get("Authorization") != "Bearer secret-token" {
+		t.Errorf("Expected a bearer authenticated request, got %+v", requests[0])
+	}
+	username, password, ok := (&http.Request{Header: requests[1].headers}).BasicAuth()
+	if string(requests[1].body) != `{"title": "DELETED prod/credentials"}` || !ok || username != "bot" || password != "secret-token" || requests[1].headers.Get("X-Team") != "platform" {
+		t.Errorf("Expected a basic authenticated request with the rendered template, got %+v", requests[1])
+	}
+	if string(requests[2].body) != "settings: web, worker app.yaml=--- app.ya..." || requests[2].headers.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the related services and diffs in the body, got %+v", requests[2])
+	}
+}
+
+// TestWebhookSinkRateLimit tests dropping event logs over the rate limit of a route
+func TestWebhookSinkRateLimit(t *testing.T) {
+	webhook := newTestHTTPReceiver(t, nil)
+	sink, _ := NewWebhookSink(SinkConfig{Webhook: &WebhookSinkConfig{Routes: []WebhookRouteConfig{{
+		URL:       webhook.server.URL,
+		RateLimit: &WebhookRateLimit{Events: 2, Interval: metav1.Duration{Duration: time.Hour}},
+	}}}})
+	for range 5 {
+		if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "prod", nil)); err != nil {
+			t.Fatalf("Failed to send event log: %v", err)
+		}
+	}
+	if requests := webhook.received(); len(requests) != 2 {
+		t.Errorf("Expected the burst of 2 requests, got %d", len(requests))
+	}
+	if limited := sink.(*WebhookSink).routes[0].limited.Load(); limited != 3 {
+		t.Errorf("Expected 3 rate limited event logs, got %d", limited)
+	}
+}
+
+// TestWebhookRouteAllowConcurrent tests counting the dropped event logs of a route sent concurrently
+func TestWebhookRouteAllowConcurrent(t *testing.T) {
+	route := &webhookRoute{name: "alerts", limiter: rate.NewLimiter(rate.Every(time.Hour), 1)}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			route.allow()
+		}()
+	}
+	wg.Wait()
+	if limited := route.limited.Load(); limited != 9 {
+		t.Errorf("Expected 9 rate limited event logs, got %d", limited)
+	}
+}
+
+// TestWebhookSinkRetries tests retrying unavailable webhooks, and reporting rejected requests
+func TestWebhookSinkRetries(t *testing.T) {
+	withSinkBackoff(t)
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	webhook := newTestHTTPReceiver(t, nil, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadRequest)
+	sink, _ := NewWebhookSink(SinkConfig{Name: "webhook-test", Webhook: &WebhookSinkConfig{Routes: []WebhookRouteConfig{{URL: webhook.server.URL}}}})
+
+	if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "prod", nil)); err == nil || sink.Health() == nil {
+		t.Error("Expected an error of the rejected request")
+	}
+	if requests := webhook.received(); len(requests) != 3 {
+		t.Errorf("Expected 2 retries before the rejected request, got %d requests", len(requests))
+	}
+	if err := sink.Send(getTestSinkEvent(EventTypeModified, "Deployment", "web", "prod", nil)); err != nil || sink.Health() != nil {
+		t.Errorf("Expected the sink to recover, got %v", err)
+	}
+	if !strings.Contains(output.String(), "sink: webhook-test") || strings.Contains(output.String(), "sink: route-0") {
+		t.Errorf("Expected the retries to be logged with the sink name, got:\n%s", output.String())
+	}
+}
+
+// TestNewWebhookSink tests validating the webhook routes
+func TestNewWebhookSink(t *testing.T) {
+	invalidRoutes := map[string]WebhookRouteConfig{
+		"no URL":           {},
+		"invalid template": {URL: "http://localhost", Template: "{{ .Message "},
+		"invalid limit":    {URL: "http://localhost", RateLimit: &WebhookRateLimit{Events: 0, Interval: metav1.Duration{Duration: time.Minute}}},
+	}
+	for name, route := range invalidRoutes {
+		if _, err := NewWebhookSink(SinkConfig{Webhook: &WebhookSinkConfig{Routes: []WebhookRouteConfig{route}}}); err == nil {
+			t.Errorf("Expected an error of a route with %s", name)
+		}
+	}
+	if _, err := NewWebhookSink(SinkConfig{Webhook: &WebhookSinkConfig{}}); err == nil {
+		t.Error("Expected an error without routes")
+	}
+}
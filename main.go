@@ -1,25 +1,45 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"main.go/admission"
+	"main.go/audit"
 	"main.go/common"
 	"main.go/resources"
 )
 
 func main() {
 
-	common.ConfigureLogzioSender() // Configure logz.io logger
+	common.LoadConfig()         // Load the optional collector configuration file
+	common.ConfigureSinks()     // Configure the sinks of the event logs, defaults to the logz.io logger
+	common.StartMetricsServer() // Start the optional Prometheus metrics endpoint
+	common.StartHealthServer()  // Start the optional liveness and readiness probes
 
 	// Sending a log message indicating the start of K8S Events Logz.io Integration
 	log.Printf("Starting K8S Events Logz.io Integration.")
 
+	// Stop the informers when the pod is stopped (SIGTERM) or the process is interrupted (SIGINT)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Configuring dynamic client for kubernetes cluster
 	common.DynamicClient = common.ConfigureClusterDynamicClient()
 	if common.DynamicClient != nil {
-		// Adding event handlers if dynamic client is configured successfully
-		resources.AddEventHandlers()
+		// Configuring discovery client to find the installed optional resources, such as Argo CD and Flux
+		common.ConfigureClusterDiscoveryClient()
+		// Start the optional audit webhook receiver before the informers, so no change is missed
+		audit.StartAuditWebhook(resources.IsWatchedResource)
+		// Start the optional admission webhook, that sees change attempts before they are applied
+		admission.StartAdmissionWebhook(resources.WatchedResources())
+		// Adding event handlers if dynamic client is configured successfully, and running the informers until stopped
+		resources.AddEventHandlers(ctx)
 	}
 
-	common.LogzioSender.Stop() // Stopping the logz.io logger after the application finishes
+	log.Printf("Stopping K8S Events Logz.io Integration.")
+	common.CloseSinks(common.DefaultSinkCloseTimeout) // Sending the queued event logs and closing the sinks
 }
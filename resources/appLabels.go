@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// recommendedAppLabels are the fields of the app object of the Kubernetes recommended labels
+var recommendedAppLabels = map[string]string{
+	"app.kubernetes.io/name":       "name",
+	"app.kubernetes.io/instance":   "instance",
+	"app.kubernetes.io/version":    "version",
+	"app.kubernetes.io/component":  "component",
+	"app.kubernetes.io/part-of":    "partOf",
+	"app.kubernetes.io/managed-by": "managedBy",
+}
+
+// appLabelsFallbackKinds are the kinds that use the app labels of the workloads that reference them
+var appLabelsFallbackKinds = []string{"ConfigMap", "Secret"}
+
+// appLabelsIndex holds the app labels of the workloads in the cluster
+var appLabelsIndex = map[workloadKey]map[string]string{}
+var appLabelsMux sync.RWMutex
+
+// appLabelFields returns the app object fields of the recommended and configured custom label keys. Custom labels
+// are named after the key without its prefix, for example "example.com/cost-center" is added as "cost_center".
+func appLabelFields() (fields map[string]string) {
+	fields = map[string]string{}
+	for key, field := range recommendedAppLabels {
+		fields[key] = field
+	}
+	for _, key := range common.Config.AppLabels {
+		if _, ok := fields[key]; !ok {
+			fields[key] = sanitizeFieldName(key[strings.LastIndex(key, "/")+1:])
+		}
+	}
+	return fields
+}
+
+// objectAppLabels returns the app labels of an object, workloads fall back to the labels of their pod template
+func objectAppLabels(obj map[string]interface{}) (app map[string]string) {
+	labels, _, _ := unstructured.NestedStringMap(obj, common.Metadata, common.Labels)
+	templateLabels, _, _ := unstructured.NestedStringMap(obj, "spec", "template", common.Metadata, common.Labels)
+	for key, field := range appLabelFields() {
+		value, ok := labels[key]
+		if !ok {
+			value, ok = templateLabels[key]
+		}
+		if ok && value != "" {
+			if app == nil {
+				app = map[string]string{}
+			}
+			app[field] = value
+		}
+	}
+	return app
+}
+
+// IndexAppLabels adds the app labels of a workload to the index, or removes them if the workload was deleted
+func IndexAppLabels(eventType string, obj map[string]interface{}) {
+	workload := &unstructured.Unstructured{Object: obj}
+	if !slices.Contains(indexedWorkloadKinds, workload.GetKind()) {
+		return
+	}
+
+	key := workloadKey{kind: workload.GetKind(), namespace: workload.GetNamespace(), name: workload.GetName()}
+	app := objectAppLabels(obj)
+
+	appLabelsMux.Lock()
+	defer appLabelsMux.Unlock()
+	if eventType == common.EventTypeDeleted || len(app) == 0 {
+		delete(appLabelsIndex, key)
+	} else {
+		appLabelsIndex[key] = app
+	}
+}
+
+// IndexAppLabelsObject adds the app labels of an existing workload to the index, before the informers are synced
+func IndexAppLabelsObject(obj interface{}) {
+	if workload, ok := obj.(*unstructured.Unstructured); ok {
+		IndexAppLabels(common.EventTypeAdded, workload.Object)
+	}
+}
+
+// EventAppLabels returns the app object of an event resource from its recommended and custom labels. ConfigMaps
+// and Secrets fall back to the labels of the workloads that reference them.
+func EventAppLabels(resourceKind string, resourceNamespace string, obj map[string]interface{}, relatedServices common.RelatedClusterServices) (app map[string]string) {
+	app = objectAppLabels(obj)
+
+	var fallbackKeys []workloadKey
+	if slices.Contains(appLabelsFallbackKinds, resourceKind) {
+		fallbackKeys = relatedWorkloadKeys(resourceNamespace, relatedServices)
+	}
+
+	appLabelsMux.RLock()
+	defer appLabelsMux.RUnlock()
+	for _, key := range fallbackKeys {
+		for field, value := range appLabelsIndex[key] {
+			if app == nil {
+				app = map[string]string{}
+			}
+			if _, ok := app[field]; !ok {
+				app[field] = value
+			}
+		}
+	}
+	return app
+}
@@ -0,0 +1,50 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"main.go/common"
+)
+
+// TestEventAppLabels tests the app object of the recommended and custom labels of resources
+func TestEventAppLabels(t *testing.T) {
+	common.Config.AppLabels = []string{"example.com/cost-center"}
+	t.Cleanup(func() {
+		common.Config.AppLabels = nil
+		appLabelsIndex = map[workloadKey]map[string]string{}
+	})
+
+	deployment := getTestMarkedObject("Deployment", "web", nil, map[string]interface{}{
+		"app.kubernetes.io/name":       "web",
+		"app.kubernetes.io/part-of":    "shop",
+		"app.kubernetes.io/managed-by": "Helm",
+		"example.com/cost-center":      "retail",
+		"other.io/label":               "value",
+	})
+	deployment["spec"] = map[string]interface{}{"template": map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{
+		"app.kubernetes.io/version": "1.2.3",
+		"app.kubernetes.io/name":    "ignored",
+	}}}}
+	app := EventAppLabels("Deployment", "default", deployment, common.RelatedClusterServices{})
+	expected := map[string]string{"name": "web", "partOf": "shop", "managedBy": "Helm", "version": "1.2.3", "cost_center": "retail"}
+	if !reflect.DeepEqual(app, expected) {
+		t.Errorf("Expected app %v, got %v", expected, app)
+	}
+	IndexAppLabels(common.EventTypeAdded, deployment)
+
+	// Config maps fall back to the labels of the workloads that reference them, service accounts don't
+	configMap := getTestMarkedObject("ConfigMap", "web-config", nil, nil)
+	related := common.RelatedClusterServices{Deployments: []string{"web"}}
+	if app = EventAppLabels("ConfigMap", "default", configMap, related); app["name"] != "web" {
+		t.Errorf("Expected the config map app of its deployment, got %v", app)
+	}
+	if app = EventAppLabels("ServiceAccount", "default", getTestMarkedObject("ServiceAccount", "web", nil, nil), related); app != nil {
+		t.Errorf("Expected no service account app, got %v", app)
+	}
+
+	IndexAppLabels(common.EventTypeDeleted, deployment)
+	if app = EventAppLabels("ConfigMap", "default", configMap, related); app != nil {
+		t.Errorf("Expected no app of a deleted workload, got %v", app)
+	}
+}
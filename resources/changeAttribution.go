@@ -0,0 +1,138 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// managedFieldsEntry is the part of a metadata.managedFields entry that identifies a change
+type managedFieldsEntry struct {
+	Manager     string
+	Operation   string
+	Subresource string
+	Time        string
+	fieldsV1    interface{}
+}
+
+// managedFieldsEntries returns the managedFields entries of an object
+func managedFieldsEntries(obj map[string]interface{}) (entries []managedFieldsEntry) {
+	managedFields, _, _ := unstructured.NestedFieldNoCopy(obj, common.Metadata, common.ManagedFields)
+	managedFieldsList, _ := managedFields.([]interface{})
+	for _, entryI := range managedFieldsList {
+		entry, ok := entryI.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		managedEntry := managedFieldsEntry{fieldsV1: entry["fieldsV1"]}
+		managedEntry.Manager, _ = entry["manager"].(string)
+		managedEntry.Operation, _ = entry["operation"].(string)
+		managedEntry.Subresource, _ = entry["subresource"].(string)
+		managedEntry.Time, _ = entry["time"].(string)
+		entries = append(entries, managedEntry)
+	}
+	return entries
+}
+
+// latestChangeTime returns the time of the latest change recorded in managedFields entries
+func latestChangeTime(entries []managedFieldsEntry) (latestTime string) {
+	// RFC 3339 timestamps sort in time order
+	for _, entry := range entries {
+		if entry.Time > latestTime {
+			latestTime = entry.Time
+		}
+	}
+	return latestTime
+}
+
+// objectChangeTime returns the time of the latest change of an object as recorded in its managedFields, or its
+// deletion timestamp for deleted objects. The time is zero if it isn't recorded.
+func objectChangeTime(eventType string, obj map[string]interface{}) (changeTime time.Time) {
+	timestamp := latestChangeTime(managedFieldsEntries(obj))
+	if eventType == common.EventTypeDeleted {
+		timestamp, _, _ = unstructured.NestedString(obj, common.Metadata, "deletionTimestamp")
+	}
+	changeTime, _ = time.Parse(time.RFC3339, timestamp)
+	return changeTime
+}
+
+// changedManagedFieldsEntries returns the managedFields entries that were added or updated between the old and new
+// objects, at or after the latest change of the old object. Entries that only lost fields to other managers keep their
+// time, so they aren't attributed the change.
+func changedManagedFieldsEntries(oldObject, newObject map[string]interface{}) (changedEntries []managedFieldsEntry) {
+	oldEntries := managedFieldsEntries(oldObject)
+	previousChangeTime := latestChangeTime(oldEntries)
+	for _, newEntry := range managedFieldsEntries(newObject) {
+		if newEntry.Time < previousChangeTime {
+			continue
+		}
+		isChanged := true
+		for _, oldEntry := range oldEntries {
+			if oldEntry.Manager == newEntry.Manager && oldEntry.Operation == newEntry.Operation && oldEntry.Subresource == newEntry.Subresource {
+				isChanged = oldEntry.Time != newEntry.Time || !reflect.DeepEqual(oldEntry.fieldsV1, newEntry.fieldsV1)
+				break
+			}
+		}
+		if isChanged {
+			changedEntries = append(changedEntries, newEntry)
+		}
+	}
+	return changedEntries
+}
+
+// ChangeAttribution returns the change of a resource by its managers, as recorded in its managedFields.
+// The manager, operation, subresource (such as status) and time of the latest change are reported, preferring changes
+// of the main resource over changes of its subresources, and every manager that changed the resource since its previous
+// version is listed in managers. Arrays of objects are sent as strings, so the changes aren't sent as a list.
+func ChangeAttribution(eventType string, oldObject, newObject map[string]interface{}) (changedBy map[string]interface{}) {
+	var changedEntries []managedFieldsEntry
+	switch eventType {
+	case common.EventTypeAdded:
+		changedEntries = managedFieldsEntries(newObject)
+	case common.EventTypeModified:
+		changedEntries = changedManagedFieldsEntries(oldObject, newObject)
+	}
+	if len(changedEntries) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(changedEntries, func(i, j int) bool {
+		if (changedEntries[i].Subresource == "") != (changedEntries[j].Subresource == "") {
+			return changedEntries[i].Subresource == ""
+		}
+		if changedEntries[i].Time != changedEntries[j].Time {
+			return changedEntries[i].Time > changedEntries[j].Time
+		}
+		return changedEntries[i].Manager < changedEntries[j].Manager
+	})
+
+	latest := changedEntries[0]
+	changedBy = map[string]interface{}{
+		"manager":   latest.Manager,
+		"operation": latest.Operation,
+		"time":      latest.Time,
+	}
+	if latest.Subresource != "" {
+		changedBy["subresource"] = latest.Subresource
+	}
+	var managers []string
+	for _, entry := range changedEntries {
+		if !slices.Contains(managers, entry.Manager) {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	changedBy["managers"] = managers
+	return changedBy
+}
+
+// stripManagedFields removes metadata.managedFields from the objects of a parsed event
+func stripManagedFields(parsedEvent map[string]interface{}) {
+	for _, objectField := range []string{"newObject", "oldObject"} {
+		if obj, ok := parsedEvent[objectField].(map[string]interface{}); ok {
+			unstructured.RemoveNestedField(obj, common.Metadata, common.ManagedFields)
+		}
+	}
+}
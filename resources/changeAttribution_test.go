@@ -0,0 +1,111 @@
+package resources
+
+import (
+	"encoding/json"
+	"main.go/common"
+	"reflect"
+	"testing"
+)
+
+// getTestManagedFieldsObject returns an object with the given managedFields entries
+func getTestManagedFieldsObject(entries ...map[string]interface{}) map[string]interface{} {
+	managedFields := []interface{}{}
+	for _, entry := range entries {
+		managedFields = append(managedFields, entry)
+	}
+	return map[string]interface{}{
+		common.Metadata: map[string]interface{}{
+			"name":               "test-deployment",
+			common.ManagedFields: managedFields,
+		},
+	}
+}
+
+// parsedChangedBy returns the changedBy field of an event log, as it is sent
+func parsedChangedBy(t *testing.T, changedBy map[string]interface{}) map[string]interface{} {
+	var eventLog map[string]interface{}
+	if err := json.Unmarshal([]byte(common.ParseEventLog("[EVENT] test", map[string]interface{}{"changedBy": changedBy})), &eventLog); err != nil {
+		t.Fatalf("Failed to parse event log: %v", err)
+	}
+	parsed, _ := eventLog["changedBy"].(map[string]interface{})
+	return parsed
+}
+
+// TestChangeAttribution tests that modified events are attributed to the managers with changed managedFields entries
+func TestChangeAttribution(t *testing.T) {
+	helmEntry := map[string]interface{}{"manager": "helm", "operation": "Update", "time": "2024-01-01T00:00:00Z", "fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{}}}
+	controllerEntry := map[string]interface{}{"manager": "kube-controller-manager", "operation": "Update", "subresource": "status", "time": "2024-01-01T00:00:00Z"}
+	oldObject := getTestManagedFieldsObject(helmEntry, controllerEntry)
+
+	kubectlEntry := map[string]interface{}{"manager": "kubectl-edit", "operation": "Update", "time": "2024-01-02T00:00:00Z"}
+	newControllerEntry := map[string]interface{}{"manager": "kube-controller-manager", "operation": "Update", "subresource": "status", "time": "2024-01-03T00:00:00Z"}
+	newObject := getTestManagedFieldsObject(helmEntry, newControllerEntry, kubectlEntry)
+
+	// Changes of the resource itself are reported before changes of its status
+	changedBy := parsedChangedBy(t, ChangeAttribution(common.EventTypeModified, oldObject, newObject))
+	expected := map[string]interface{}{
+		"manager":   "kubectl-edit",
+		"operation": "Update",
+		"time":      "2024-01-02T00:00:00Z",
+		"managers":  []interface{}{"kubectl-edit", "kube-controller-manager"},
+	}
+	if !reflect.DeepEqual(changedBy, expected) {
+		t.Errorf("Expected changedBy %v, got %v", expected, changedBy)
+	}
+
+	// Status only changes are attributed to the status subresource manager
+	statusObject := getTestManagedFieldsObject(helmEntry, newControllerEntry)
+	changedBy = parsedChangedBy(t, ChangeAttribution(common.EventTypeModified, oldObject, statusObject))
+	if changedBy["manager"] != "kube-controller-manager" || changedBy["subresource"] != "status" {
+		t.Errorf("Expected the status subresource manager, got %v", changedBy)
+	}
+
+	if changedBy := ChangeAttribution(common.EventTypeDeleted, nil, newObject); changedBy != nil {
+		t.Errorf("Expected no attribution for deleted events, got %v", changedBy)
+	}
+	if changedBy := ChangeAttribution(common.EventTypeModified, oldObject, oldObject); changedBy != nil {
+		t.Errorf("Expected no attribution without changed entries, got %v", changedBy)
+	}
+}
+
+// TestChangeAttributionManagers tests that an update written by two managers is attributed to both, and not to managers
+// that only lost fields to them
+func TestChangeAttributionManagers(t *testing.T) {
+	helmEntry := map[string]interface{}{"manager": "helm", "operation": "Update", "time": "2024-01-02T00:00:00Z", "fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:replicas": map[string]interface{}{}}}}
+	kubectlEntry := map[string]interface{}{"manager": "kubectl-client-side-apply", "operation": "Update", "time": "2024-01-01T00:00:00Z", "fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:template": map[string]interface{}{}, "f:paused": map[string]interface{}{}}}}
+	oldObject := getTestManagedFieldsObject(helmEntry, kubectlEntry)
+
+	newHelmEntry := map[string]interface{}{"manager": "helm", "operation": "Update", "time": "2024-01-03T00:00:00Z", "fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:replicas": map[string]interface{}{}, "f:template": map[string]interface{}{}}}}
+	argoEntry := map[string]interface{}{"manager": "argocd-controller", "operation": "Apply", "time": "2024-01-03T00:00:00Z", "fieldsV1": map[string]interface{}{"f:metadata": map[string]interface{}{}}}
+	// kubectl lost spec.template to helm, without changing the resource itself
+	newKubectlEntry := map[string]interface{}{"manager": "kubectl-client-side-apply", "operation": "Update", "time": "2024-01-01T00:00:00Z", "fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:paused": map[string]interface{}{}}}}
+	newObject := getTestManagedFieldsObject(newHelmEntry, argoEntry, newKubectlEntry)
+
+	expected := map[string]interface{}{
+		"manager":   "argocd-controller",
+		"operation": "Apply",
+		"time":      "2024-01-03T00:00:00Z",
+		"managers":  []interface{}{"argocd-controller", "helm"},
+	}
+	if changedBy := parsedChangedBy(t, ChangeAttribution(common.EventTypeModified, oldObject, newObject)); !reflect.DeepEqual(changedBy, expected) {
+		t.Errorf("Expected changedBy %v, got %v", expected, changedBy)
+	}
+}
+
+// TestStripManagedFields tests that managedFields are removed from the shipped objects
+func TestStripManagedFields(t *testing.T) {
+	parsedEvent := map[string]interface{}{
+		"newObject": getTestManagedFieldsObject(map[string]interface{}{"manager": "helm"}),
+		"oldObject": getTestManagedFieldsObject(map[string]interface{}{"manager": "helm"}),
+	}
+	stripManagedFields(parsedEvent)
+	for _, objectField := range []string{"newObject", "oldObject"} {
+		metadata := parsedEvent[objectField].(map[string]interface{})[common.Metadata].(map[string]interface{})
+		if _, ok := metadata[common.ManagedFields]; ok {
+			t.Errorf("Expected managedFields to be removed from %s", objectField)
+		}
+		if metadata["name"] != "test-deployment" {
+			t.Errorf("Expected the other metadata fields of %s to be kept", objectField)
+		}
+	}
+}
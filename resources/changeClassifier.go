@@ -0,0 +1,181 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/strings/slices"
+	"log"
+	"main.go/common"
+)
+
+// Change categories reported in the changeCategories field of modified events
+const (
+	ChangeCategoryImage           = "image"
+	ChangeCategoryScale           = "scale"
+	ChangeCategoryEnv             = "env"
+	ChangeCategoryCommand         = "command"
+	ChangeCategoryResources       = "resources"
+	ChangeCategoryProbes          = "probes"
+	ChangeCategorySecurityContext = "securityContext"
+	ChangeCategoryVolumes         = "volumes"
+	ChangeCategoryPorts           = "ports"
+	ChangeCategoryScheduling      = "scheduling"
+	ChangeCategoryServiceAccount  = "serviceAccount"
+	ChangeCategoryStrategy        = "strategy"
+	ChangeCategorySelector        = "selector"
+	ChangeCategoryData            = "data"
+	ChangeCategoryType            = "type"
+	ChangeCategoryRBACRules       = "rbacRules"
+	ChangeCategoryRoleRef         = "roleRef"
+	ChangeCategorySubjects        = "subjects"
+	ChangeCategorySecrets         = "secrets"
+	ChangeCategoryLabels          = "labels"
+	ChangeCategoryAnnotations     = "annotations"
+	ChangeCategoryMetadataOnly    = "metadataOnly"
+	ChangeCategoryOther           = "other"
+)
+
+// changeCategoryRule maps field paths to a change category
+type changeCategoryRule struct {
+	category string
+	paths    [][]pathSegment
+}
+
+// newChangeCategoryRule creates a change category rule from field paths
+func newChangeCategoryRule(category string, fieldPaths ...string) (rule changeCategoryRule) {
+	rule.category = category
+	for _, fieldPath := range fieldPaths {
+		segments, err := parseFieldPath(fieldPath)
+		if err != nil {
+			log.Printf("[ERROR] Invalid field path: %s for change category: %s.\nERROR:\n%v", fieldPath, category, err)
+			continue
+		}
+		rule.paths = append(rule.paths, segments)
+	}
+	return rule
+}
+
+// metadataChangeRules returns the rules for the metadata of any resource kind
+func metadataChangeRules() []changeCategoryRule {
+	return []changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryLabels, "metadata.labels"),
+		newChangeCategoryRule(ChangeCategoryAnnotations, "metadata.annotations"),
+	}
+}
+
+// podSpecChangeRules returns the rules for a pod spec at the given field path
+func podSpecChangeRules(podSpecPath string) []changeCategoryRule {
+	containerPaths := func(fields ...string) (fieldPaths []string) {
+		for _, containers := range []string{"containers", "initContainers"} {
+			for _, field := range fields {
+				fieldPaths = append(fieldPaths, podSpecPath+"."+containers+"[*]."+field)
+			}
+		}
+		return fieldPaths
+	}
+	podPaths := func(fields ...string) (fieldPaths []string) {
+		for _, field := range fields {
+			fieldPaths = append(fieldPaths, podSpecPath+"."+field)
+		}
+		return fieldPaths
+	}
+
+	return []changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryImage, containerPaths("image", "imagePullPolicy")...),
+		newChangeCategoryRule(ChangeCategoryEnv, containerPaths("env", "envFrom")...),
+		newChangeCategoryRule(ChangeCategoryCommand, containerPaths("command", "args", "workingDir")...),
+		newChangeCategoryRule(ChangeCategoryResources, containerPaths("resources")...),
+		newChangeCategoryRule(ChangeCategoryProbes, containerPaths("livenessProbe", "readinessProbe", "startupProbe", "lifecycle")...),
+		newChangeCategoryRule(ChangeCategorySecurityContext, append(containerPaths("securityContext"), podPaths("securityContext", "hostNetwork", "hostPID", "hostIPC")...)...),
+		newChangeCategoryRule(ChangeCategoryVolumes, append(containerPaths("volumeMounts"), podPaths("volumes")...)...),
+		newChangeCategoryRule(ChangeCategoryPorts, containerPaths("ports")...),
+		newChangeCategoryRule(ChangeCategoryScheduling, podPaths("nodeSelector", "nodeName", "affinity", "tolerations", "topologySpreadConstraints", "priorityClassName")...),
+		newChangeCategoryRule(ChangeCategoryServiceAccount, podPaths("serviceAccountName", "serviceAccount", "automountServiceAccountToken")...),
+	}
+}
+
+// workloadChangeRules returns the rules for workloads with a pod template
+func workloadChangeRules(strategyField string) (rules []changeCategoryRule) {
+	rules = append(rules, podSpecChangeRules("spec.template.spec")...)
+	rules = append(rules,
+		newChangeCategoryRule(ChangeCategoryScale, "spec.replicas"),
+		newChangeCategoryRule(ChangeCategorySelector, "spec.selector"),
+		newChangeCategoryRule(ChangeCategoryStrategy, "spec."+strategyField, "spec.minReadySeconds"),
+		newChangeCategoryRule(ChangeCategoryLabels, "spec.template.metadata.labels"),
+		newChangeCategoryRule(ChangeCategoryAnnotations, "spec.template.metadata.annotations"),
+	)
+	return append(rules, metadataChangeRules()...)
+}
+
+// changeCategoryRules are the change category rules of each supported resource kind
+var changeCategoryRules = map[string][]changeCategoryRule{
+	"Deployment":  workloadChangeRules("strategy"),
+	"DaemonSet":   workloadChangeRules("updateStrategy"),
+	"StatefulSet": workloadChangeRules("updateStrategy"),
+	"Pod":         append(podSpecChangeRules("spec"), metadataChangeRules()...),
+	"ConfigMap": append([]changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryData, "data", "binaryData", "immutable"),
+	}, metadataChangeRules()...),
+	"Secret": append([]changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryData, "data", "stringData", "immutable"),
+		newChangeCategoryRule(ChangeCategoryType, "type"),
+	}, metadataChangeRules()...),
+	"ServiceAccount": append([]changeCategoryRule{
+		newChangeCategoryRule(ChangeCategorySecrets, "secrets", "imagePullSecrets", "automountServiceAccountToken"),
+	}, metadataChangeRules()...),
+	"ClusterRole": append([]changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryRBACRules, "rules", "aggregationRule"),
+	}, metadataChangeRules()...),
+	"ClusterRoleBinding": append([]changeCategoryRule{
+		newChangeCategoryRule(ChangeCategoryRoleRef, "roleRef"),
+		newChangeCategoryRule(ChangeCategorySubjects, "subjects"),
+	}, metadataChangeRules()...),
+}
+
+// unclassifiedPaths are fields that change together with the classified fields and are never classified
+var unclassifiedPaths = [][]pathSegment{
+	{{key: common.Metadata}, {key: "generation"}},
+}
+
+// ClassifyChanges returns the categories of the changes between the old and new versions of a resource.
+// Internal fields and fields removed by the ignore rules are not classified, and changes that don't match
+// any of the kind rules are reported as "other".
+func ClassifyChanges(kind string, oldObject, newObject map[string]interface{}) (categories []string) {
+	oldCopy := (&unstructured.Unstructured{Object: oldObject}).DeepCopy()
+	newCopy := (&unstructured.Unstructured{Object: newObject}).DeepCopy()
+	deleteInternalFields(oldCopy)
+	deleteInternalFields(newCopy)
+	applyIgnoreRules(oldCopy, newCopy)
+	for _, segments := range unclassifiedPaths {
+		removeFieldPath(oldCopy.Object, segments)
+		removeFieldPath(newCopy.Object, segments)
+	}
+
+	rules, ok := changeCategoryRules[kind]
+	if !ok {
+		rules = metadataChangeRules()
+	}
+
+	isMetadataOnly := true
+	for _, rule := range rules {
+		for _, segments := range rule.paths {
+			if !fieldPathValuesEqual(oldCopy.Object, newCopy.Object, segments) && !slices.Contains(categories, rule.category) {
+				categories = append(categories, rule.category)
+				if rule.category != ChangeCategoryLabels && rule.category != ChangeCategoryAnnotations {
+					isMetadataOnly = false
+				}
+			}
+			// Remove the classified fields, so only unclassified changes are left
+			removeFieldPath(oldCopy.Object, segments)
+			removeFieldPath(newCopy.Object, segments)
+		}
+	}
+
+	if !isSameObject(oldCopy, newCopy) {
+		categories = append(categories, ChangeCategoryOther)
+		isMetadataOnly = false
+	}
+	if isMetadataOnly && len(categories) > 0 {
+		categories = append(categories, ChangeCategoryMetadataOnly)
+	}
+	return categories
+}
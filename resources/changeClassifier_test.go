@@ -0,0 +1,181 @@
+package resources
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"main.go/common"
+	"reflect"
+	"testing"
+)
+
+// toObjectMap converts a typed Kubernetes object to an unstructured object map
+func toObjectMap(t *testing.T, obj interface{}) map[string]interface{} {
+	objectMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatalf("Failed to convert object to unstructured: %v", err)
+	}
+	return objectMap
+}
+
+// TestClassifyChanges tests the change classification of fixture pairs of each supported kind
+func TestClassifyChanges(t *testing.T) {
+	deploymentFixture := func(update func(deployment *appsv1.Deployment)) (oldObj, newObj interface{}) {
+		oldDeployment := GetTestDeployment()
+		oldDeployment.ResourceVersion = "1"
+		newDeployment := *oldDeployment.DeepCopy()
+		newDeployment.ResourceVersion = "2"
+		newDeployment.Generation = 2
+		update(&newDeployment)
+		return &oldDeployment, &newDeployment
+	}
+	statefulSetFixture := func(update func(statefulSet *appsv1.StatefulSet)) (oldObj, newObj interface{}) {
+		oldStatefulSet := GetTestStatefulSet()
+		newStatefulSet := *oldStatefulSet.DeepCopy()
+		update(&newStatefulSet)
+		return &oldStatefulSet, &newStatefulSet
+	}
+	clusterRoleBindingFixture := func(update func(clusterRoleBinding *rbacv1.ClusterRoleBinding)) (oldObj, newObj interface{}) {
+		oldClusterRoleBinding := GetTestClusterRoleBinding("test-clusterrolebinding")
+		newClusterRoleBinding := *oldClusterRoleBinding.DeepCopy()
+		update(&newClusterRoleBinding)
+		return &oldClusterRoleBinding, &newClusterRoleBinding
+	}
+	clusterRole := &rbacv1.ClusterRole{
+		Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+	}
+	configMap := &corev1.ConfigMap{Data: map[string]string{"nginx.conf": "worker_processes 1;"}}
+
+	tests := []struct {
+		name     string
+		kind     string
+		fixture  func() (oldObj, newObj interface{})
+		expected []string
+	}{
+		{
+			name: "image", kind: "Deployment",
+			fixture: func() (interface{}, interface{}) {
+				return deploymentFixture(func(deployment *appsv1.Deployment) {
+					deployment.Spec.Template.Spec.Containers[0].Image = "container-image-nginx:1.26"
+				})
+			},
+			expected: []string{ChangeCategoryImage},
+		},
+		{
+			name: "scale", kind: "Deployment",
+			fixture: func() (interface{}, interface{}) {
+				return deploymentFixture(func(deployment *appsv1.Deployment) {
+					replicas := int32(3)
+					deployment.Spec.Replicas = &replicas
+				})
+			},
+			expected: []string{ChangeCategoryScale},
+		},
+		{
+			name: "env and resources", kind: "Deployment",
+			fixture: func() (interface{}, interface{}) {
+				return deploymentFixture(func(deployment *appsv1.Deployment) {
+					container := &deployment.Spec.Template.Spec.Containers[0]
+					container.Env = append(container.Env, corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"})
+					container.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")}
+				})
+			},
+			expected: []string{ChangeCategoryEnv, ChangeCategoryResources},
+		},
+		{
+			name: "probes and security context", kind: "StatefulSet",
+			fixture: func() (interface{}, interface{}) {
+				return statefulSetFixture(func(statefulSet *appsv1.StatefulSet) {
+					statefulSet.Spec.Template.Spec.Containers[0].ReadinessProbe = &corev1.Probe{InitialDelaySeconds: 5}
+					runAsNonRoot := true
+					statefulSet.Spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}
+				})
+			},
+			expected: []string{ChangeCategoryProbes, ChangeCategorySecurityContext},
+		},
+		{
+			name: "labels only", kind: "Deployment",
+			fixture: func() (interface{}, interface{}) {
+				return deploymentFixture(func(deployment *appsv1.Deployment) {
+					deployment.Labels = map[string]string{"team": "platform"}
+				})
+			},
+			expected: []string{ChangeCategoryLabels, ChangeCategoryMetadataOnly},
+		},
+		{
+			name: "selector and other", kind: "Deployment",
+			fixture: func() (interface{}, interface{}) {
+				return deploymentFixture(func(deployment *appsv1.Deployment) {
+					deployment.Spec.Selector.MatchLabels["tier"] = "web"
+					deployment.Spec.RevisionHistoryLimit = new(int32)
+				})
+			},
+			expected: []string{ChangeCategorySelector, ChangeCategoryOther},
+		},
+		{
+			name: "status only", kind: "StatefulSet",
+			fixture: func() (interface{}, interface{}) {
+				return statefulSetFixture(func(statefulSet *appsv1.StatefulSet) {
+					statefulSet.Status.ReadyReplicas = 1
+				})
+			},
+			expected: nil,
+		},
+		{
+			name: "subjects", kind: "ClusterRoleBinding",
+			fixture: func() (interface{}, interface{}) {
+				return clusterRoleBindingFixture(func(clusterRoleBinding *rbacv1.ClusterRoleBinding) {
+					clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects, rbacv1.Subject{Kind: "User", Name: "jane"})
+				})
+			},
+			expected: []string{ChangeCategorySubjects},
+		},
+		{
+			name: "rbac rules", kind: "ClusterRole",
+			fixture: func() (interface{}, interface{}) {
+				newClusterRole := clusterRole.DeepCopy()
+				newClusterRole.Rules[0].Verbs = append(newClusterRole.Rules[0].Verbs, "delete")
+				return clusterRole, newClusterRole
+			},
+			expected: []string{ChangeCategoryRBACRules},
+		},
+		{
+			name: "configmap data and annotations", kind: "ConfigMap",
+			fixture: func() (interface{}, interface{}) {
+				newConfigMap := configMap.DeepCopy()
+				newConfigMap.Data["nginx.conf"] = "worker_processes 4;"
+				newConfigMap.Annotations = map[string]string{"owner": "platform"}
+				return configMap, newConfigMap
+			},
+			expected: []string{ChangeCategoryData, ChangeCategoryAnnotations},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldObj, newObj := test.fixture()
+			categories := ClassifyChanges(test.kind, toObjectMap(t, oldObj), toObjectMap(t, newObj))
+			if !reflect.DeepEqual(categories, test.expected) {
+				t.Errorf("Expected change categories %v, got %v", test.expected, categories)
+			}
+		})
+	}
+}
+
+// TestClassifyChangesIgnoreRules tests that fields removed by the ignore rules are not classified
+func TestClassifyChangesIgnoreRules(t *testing.T) {
+	defer func() { ignoreRules = nil }()
+	ignoreRules = compileIgnoreRules([]common.IgnoreRule{{Annotations: []string{"kubectl.kubernetes.io/*"}}})
+
+	oldDeployment := GetTestDeployment()
+	newDeployment := *oldDeployment.DeepCopy()
+	newDeployment.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2024-01-01T00:00:00Z"}
+	newDeployment.Spec.Template.Spec.Containers[0].Image = "container-image-nginx:1.26"
+
+	categories := ClassifyChanges("Deployment", toObjectMap(t, &oldDeployment), toObjectMap(t, &newDeployment))
+	if !reflect.DeepEqual(categories, []string{ChangeCategoryImage}) {
+		t.Errorf("Expected only the image change category, got %v", categories)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"log"
 	"main.go/common"
 	"reflect"
+	"time"
 )
 
 // Workload is an interface that provides a common API for Kubernetes workloads (Pod, Deployment, etc.)
@@ -25,6 +29,30 @@ type Deployment appsv1.Deployment
 type DaemonSet appsv1.DaemonSet
 type StatefulSet appsv1.StatefulSet
 
+// Rollout is the subset of an Argo Rollouts Rollout used to find its related resources
+type Rollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RolloutSpec `json:"spec,omitempty"`
+}
+
+// RolloutSpec is the subset of an Argo Rollouts Rollout spec used to find its related resources
+type RolloutSpec struct {
+	Template corev1.PodTemplateSpec `json:"template,omitempty"`
+}
+
+// rolloutGVR is the resource of Argo Rollouts Rollouts
+var rolloutGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+// kindsWithoutRelatedResources are the watched kinds that no workload references, so their events skip the related resources lookup
+var kindsWithoutRelatedResources = map[string]bool{
+	"Application":   true,
+	"Kustomization": true,
+	"HelmRelease":   true,
+	"GitRepository": true,
+	"Canary":        true,
+}
+
 func (p Pod) GetName() string                   { return p.Name }
 func (p Pod) GetContainers() []corev1.Container { return p.Spec.Containers }
 func (p Pod) GetVolumes() []corev1.Volume       { return p.Spec.Volumes }
@@ -45,6 +73,11 @@ func (s StatefulSet) GetContainers() []corev1.Container { return s.Spec.Template
 func (s StatefulSet) GetVolumes() []corev1.Volume       { return s.Spec.Template.Spec.Volumes }
 func (s StatefulSet) GetServiceAccountName() string     { return s.Spec.Template.Spec.ServiceAccountName }
 
+func (r Rollout) GetName() string                   { return r.Name }
+func (r Rollout) GetContainers() []corev1.Container { return r.Spec.Template.Spec.Containers }
+func (r Rollout) GetVolumes() []corev1.Volume       { return r.Spec.Template.Spec.Volumes }
+func (r Rollout) GetServiceAccountName() string     { return r.Spec.Template.Spec.ServiceAccountName }
+
 // GetClusterRoleBindings retrieves all ClusterRoleBindings in the cluster
 func GetClusterRoleBindings() (relatedClusterRoleBindings []rbacv1.ClusterRoleBinding) {
 
@@ -149,6 +182,68 @@ func GetStatefulSets() (relatedStatefulSets []appsv1.StatefulSet) {
 	return relatedStatefulSets
 }
 
+// rolloutsClient returns the dynamic client used to get Rollouts, or nil if the cluster doesn't serve them
+func rolloutsClient() dynamic.Interface {
+	if common.DynamicClient == nil || !IsWatchedResource(rolloutGVR.Group, rolloutGVR.Resource) {
+		return nil
+	}
+	return common.DynamicClient
+}
+
+// listRollouts lists the Rollouts in the cluster with a dynamic client
+func listRollouts(client dynamic.Interface) (relatedRollouts []Rollout) {
+	rollouts, err := client.Resource(rolloutGVR).Namespace("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		// Handle error by common the error and returning an empty list of related Rollouts.
+		log.Printf("[ERROR] Error listing Rollouts: %v", err)
+		return
+	}
+
+	for _, item := range rollouts.Items {
+		var rollout Rollout
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &rollout); err != nil {
+			log.Printf("[ERROR] Failed to convert Rollout: %s in namespace %s\nError: %v", item.GetName(), item.GetNamespace(), err)
+			continue
+		}
+		relatedRollouts = append(relatedRollouts, rollout)
+	}
+
+	return relatedRollouts
+}
+
+// GetRollouts retrieves all Argo Rollouts Rollouts in the cluster, if the cluster serves them
+func GetRollouts() (relatedRollouts []Rollout) {
+	if client := rolloutsClient(); client != nil {
+		relatedRollouts = listRollouts(client)
+	}
+	return relatedRollouts
+}
+
+// getRollout gets a specific Rollout by name and namespace with a dynamic client
+func getRollout(client dynamic.Interface, rolloutName string, namespace string) (relatedRollout Rollout) {
+	rollout, err := client.Resource(rolloutGVR).Namespace(namespace).Get(context.Background(), rolloutName, metav1.GetOptions{})
+	if err != nil {
+		// Ignore errors of resource not found, as the resource may not exist in the cluster in deletion events.
+		if !errors.IsNotFound(err) {
+			log.Printf("[ERROR] Failed to get Rollout: %s in namespace %s\nError: %v", rolloutName, namespace, err)
+		}
+		return
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(rollout.Object, &relatedRollout); err != nil {
+		log.Printf("[ERROR] Failed to convert Rollout: %s in namespace %s\nError: %v", rolloutName, namespace, err)
+	}
+
+	return relatedRollout
+}
+
+// GetRollout retrieves a specific Argo Rollouts Rollout by name and namespace, if the cluster serves them
+func GetRollout(rolloutName string, namespace string) (relatedRollout Rollout) {
+	if client := rolloutsClient(); client != nil {
+		relatedRollout = getRollout(client, rolloutName, namespace)
+	}
+	return relatedRollout
+}
+
 // GetDeployment retrieves a specific Deployment by name and namespace
 func GetDeployment(deploymentName string, namespace string) (relatedDeployment appsv1.Deployment) {
 
@@ -236,6 +331,14 @@ func GetClusterRoleBinding(clusterRoleBindingName string) (relatedClusterRoleBin
 
 // GetClusterRelatedResources retrieves all related resources for a given resource kind, name and namespace.
 func GetClusterRelatedResources(resourceKind string, resourceName string, namespace string) (relatedClusterServices common.RelatedClusterServices) {
+	if kindsWithoutRelatedResources[resourceKind] {
+		return relatedClusterServices
+	}
+
+	// Record the latency of the lookup, by kind
+	defer func(start time.Time) {
+		common.RelatedResourcesDuration.WithLabelValues(resourceKind).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
 	common.CreateClusterClient()
 
@@ -257,6 +360,8 @@ func GetClusterRelatedResources(resourceKind string, resourceName string, namesp
 			relatedClusterServices = DaemonSetRelatedResources(resourceName, namespace)
 		case "StatefulSet":
 			relatedClusterServices = StatefulSetRelatedResources(resourceName, namespace)
+		case "Rollout":
+			relatedClusterServices = RolloutRelatedResources(resourceName, namespace)
 		default:
 			log.Printf("[ERROR] Unknown resource kind %s", resourceKind)
 		}
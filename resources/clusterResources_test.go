@@ -1,10 +1,22 @@
 package resources
 
 import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"log"
+	"main.go/common"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
 )
 
 // workloadTestEnvVars returns a list of mock env vars for testing.
@@ -158,6 +170,26 @@ func GetTestDeployment() (deployment appsv1.Deployment) {
 	return deployment
 }
 
+// GetTestRollout returns a mock Argo Rollouts rollout for testing, with the pod template of the mock deployment.
+func GetTestRollout() (rollout *unstructured.Unstructured) {
+	deployment := GetTestDeployment()
+	template, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(&deployment.Spec.Template)
+	rollout = &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   map[string]interface{}{"name": "test-rollout", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": template,
+			"strategy": map[string]interface{}{"canary": map[string]interface{}{"steps": []interface{}{
+				map[string]interface{}{"setWeight": int64(20)},
+				map[string]interface{}{"pause": map[string]interface{}{}},
+				map[string]interface{}{"setWeight": int64(60)},
+			}}},
+		},
+	}}
+	return rollout
+}
+
 // GetTestDaemonSet returns a mock daemon set for testing.
 func GetTestDaemonSet() (relatedDaemonsets appsv1.DaemonSet) {
 	daemonSet := appsv1.DaemonSet{
@@ -298,3 +330,41 @@ func GetTestClusterRoleBindings() (relatedClusterRoleBindings []rbacv1.ClusterRo
 
 	return relatedClusterRoleBindings
 }
+
+// getTestMetrics returns the metrics served in the Prometheus text format
+func getTestMetrics() string {
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return recorder.Body.String()
+}
+
+// TestGetClusterRelatedResourcesLatency tests recording the latency of looking up related resources by kind
+func TestGetClusterRelatedResourcesLatency(t *testing.T) {
+	GetClusterRelatedResources("LatencyTestKind", "test", "default")
+
+	if metrics := getTestMetrics(); !strings.Contains(metrics, `logzio_k8s_events_related_resources_duration_seconds_count{kind="LatencyTestKind"} 1`) {
+		t.Errorf("Expected the latency of the lookup to be recorded, got:\n%s", metrics)
+	}
+}
+
+// TestGetClusterRelatedResourcesWithoutRelatedResources tests skipping the lookup for kinds that have no related workloads
+func TestGetClusterRelatedResourcesWithoutRelatedResources(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	for _, kind := range []string{"Application", "Kustomization", "HelmRelease", "GitRepository", "Canary"} {
+		relatedResources := GetClusterRelatedResources(kind, "test", "default")
+		if !reflect.DeepEqual(relatedResources, common.RelatedClusterServices{}) {
+			t.Errorf("Expected no related resources for kind %s, got: %v", kind, relatedResources)
+		}
+
+		if metrics := getTestMetrics(); strings.Contains(metrics, `logzio_k8s_events_related_resources_duration_seconds_count{kind="`+kind+`"}`) {
+			t.Errorf("Expected the lookup of kind %s not to be recorded, got:\n%s", kind, metrics)
+		}
+	}
+
+	if output.Len() != 0 {
+		t.Errorf("Expected nothing to be logged, got:\n%s", output.String())
+	}
+}
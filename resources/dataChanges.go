@@ -0,0 +1,171 @@
+package resources
+
+import (
+	"encoding/base64"
+	"main.go/common"
+	"sort"
+)
+
+// Key change types reported for Secret and ConfigMap keys
+const (
+	KeyChangeAdded     = "added"
+	KeyChangeRemoved   = "removed"
+	KeyChangeChanged   = "changed"
+	KeyChangeUnchanged = "unchanged"
+)
+
+// dataKey describes a single Secret or ConfigMap key without its value
+type dataKey struct {
+	Name           string `json:"name"`
+	Change         string `json:"change"`
+	Binary         bool   `json:"binary,omitempty"`
+	Length         int    `json:"length"`
+	PreviousLength int    `json:"previousLength,omitempty"`
+	Hash           string `json:"hash,omitempty"`
+}
+
+// dataValue is the decoded value of a Secret or ConfigMap key
+type dataValue struct {
+	value  []byte
+	binary bool
+}
+
+// secretDataValues returns the decoded values of the Secret data keys
+func secretDataValues(obj map[string]interface{}) (values map[string]dataValue) {
+	values = map[string]dataValue{}
+	data, _ := obj["data"].(map[string]interface{})
+	for key, valueI := range data {
+		value, _ := valueI.(string)
+		decodedValue, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			decodedValue = []byte(value)
+		}
+		values[key] = dataValue{value: decodedValue}
+	}
+	return values
+}
+
+// configMapDataValues returns the values of the ConfigMap data and binaryData keys
+func configMapDataValues(obj map[string]interface{}) (values map[string]dataValue) {
+	values = map[string]dataValue{}
+	data, _ := obj["data"].(map[string]interface{})
+	for key, valueI := range data {
+		value, _ := valueI.(string)
+		values[key] = dataValue{value: []byte(value)}
+	}
+	binaryData, _ := obj["binaryData"].(map[string]interface{})
+	for key, valueI := range binaryData {
+		value, _ := valueI.(string)
+		decodedValue, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			decodedValue = []byte(value)
+		}
+		values[key] = dataValue{value: decodedValue, binary: true}
+	}
+	return values
+}
+
+// compareDataKeys compares the old and new key values and returns the key descriptions and key names by change type
+func compareDataKeys(eventType string, oldValues, newValues map[string]dataValue) (keys []dataKey, keysByChange map[string][]string) {
+	keysByChange = map[string][]string{}
+	if eventType == common.EventTypeDeleted {
+		// The deleted object is the new object of delete events
+		oldValues, newValues = newValues, map[string]dataValue{}
+	}
+
+	var keyNames []string
+	for key := range newValues {
+		keyNames = append(keyNames, key)
+	}
+	for key := range oldValues {
+		if _, ok := newValues[key]; !ok {
+			keyNames = append(keyNames, key)
+		}
+	}
+	sort.Strings(keyNames)
+
+	for _, keyName := range keyNames {
+		oldValue, inOld := oldValues[keyName]
+		newValue, inNew := newValues[keyName]
+		key := dataKey{Name: keyName}
+		switch {
+		case !inOld:
+			key.Change = KeyChangeAdded
+		case !inNew:
+			key.Change = KeyChangeRemoved
+		case string(oldValue.value) != string(newValue.value) || oldValue.binary != newValue.binary:
+			key.Change = KeyChangeChanged
+		default:
+			key.Change = KeyChangeUnchanged
+		}
+
+		if inNew {
+			key.Binary = newValue.binary
+			key.Length = len(newValue.value)
+			key.Hash = common.KeyedHash(newValue.value)
+		} else {
+			key.Binary = oldValue.binary
+		}
+		if inOld && key.Change != KeyChangeAdded && key.Change != KeyChangeUnchanged {
+			key.PreviousLength = len(oldValue.value)
+		}
+
+		keys = append(keys, key)
+		if key.Change != KeyChangeUnchanged {
+			keysByChange[key.Change] = append(keysByChange[key.Change], keyName)
+		}
+	}
+	return keys, keysByChange
+}
+
+// dataChangesFields returns the event fields of the key changes
+func dataChangesFields(keys []dataKey, keysByChange map[string][]string) (fields map[string]interface{}) {
+	fields = map[string]interface{}{}
+	if len(keys) > 0 {
+		fields["keys"] = keys
+	}
+	for change, field := range map[string]string{KeyChangeAdded: "keysAdded", KeyChangeRemoved: "keysRemoved", KeyChangeChanged: "keysChanged"} {
+		if len(keysByChange[change]) > 0 {
+			fields[field] = keysByChange[change]
+		}
+	}
+	return fields
+}
+
+// SecretDataChanges returns the key level changes of a Secret event.
+// Secret values are never returned, only their keys, byte lengths and keyed hashes.
+func SecretDataChanges(eventType string, oldObject, newObject map[string]interface{}) (secretData map[string]interface{}) {
+	keys, keysByChange := compareDataKeys(eventType, secretDataValues(oldObject), secretDataValues(newObject))
+	secretData = dataChangesFields(keys, keysByChange)
+	if secretType, ok := newObject["type"].(string); ok {
+		secretData["type"] = secretType
+	}
+	if immutable, ok := newObject["immutable"].(bool); ok {
+		secretData["immutable"] = immutable
+	}
+	return secretData
+}
+
+// ConfigMapDataChanges returns the key level changes of a ConfigMap event, for both data and binaryData keys.
+// Changed data values are also described by a unified diff, truncated around the changes to fit the field value limit.
+func ConfigMapDataChanges(eventType string, oldObject, newObject map[string]interface{}) (configMapData map[string]interface{}) {
+	oldValues, newValues := configMapDataValues(oldObject), configMapDataValues(newObject)
+	keys, keysByChange := compareDataKeys(eventType, oldValues, newValues)
+	configMapData = dataChangesFields(keys, keysByChange)
+
+	diffs := map[string]interface{}{}
+	for _, keyName := range keysByChange[KeyChangeChanged] {
+		oldValue, newValue := oldValues[keyName], newValues[keyName]
+		if oldValue.binary || newValue.binary {
+			continue
+		}
+		diffs[keyName] = common.TruncatedUnifiedDiff(keyName, keyName, string(oldValue.value), string(newValue.value), common.DiffContextLines, common.FieldValueLengthLimit-1)
+	}
+	if len(diffs) > 0 {
+		configMapData["diffs"] = diffs
+	}
+	if immutable, ok := newObject["immutable"].(bool); ok {
+		configMapData["immutable"] = immutable
+	}
+	return configMapData
+}
@@ -0,0 +1,105 @@
+package resources
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"main.go/common"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// getTestSecretObject returns a secret object map with base64 encoded data
+func getTestSecretObject(data map[string]string) map[string]interface{} {
+	encodedData := map[string]interface{}{}
+	for key, value := range data {
+		encodedData[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return map[string]interface{}{
+		"kind":      "Secret",
+		"type":      "Opaque",
+		"immutable": false,
+		"data":      encodedData,
+	}
+}
+
+// TestSecretDataChanges tests the key level changes of a modified secret
+func TestSecretDataChanges(t *testing.T) {
+	oldSecret := getTestSecretObject(map[string]string{"password": "hunter2", "username": "admin", "token": "abc"})
+	newSecret := getTestSecretObject(map[string]string{"password": "correct-horse", "username": "admin", "tls.crt": "cert"})
+
+	secretData := SecretDataChanges(common.EventTypeModified, oldSecret, newSecret)
+
+	if !reflect.DeepEqual(secretData["keysAdded"], []string{"tls.crt"}) {
+		t.Errorf("Expected added keys [tls.crt], got %v", secretData["keysAdded"])
+	}
+	if !reflect.DeepEqual(secretData["keysRemoved"], []string{"token"}) {
+		t.Errorf("Expected removed keys [token], got %v", secretData["keysRemoved"])
+	}
+	if !reflect.DeepEqual(secretData["keysChanged"], []string{"password"}) {
+		t.Errorf("Expected changed keys [password], got %v", secretData["keysChanged"])
+	}
+	if secretData["type"] != "Opaque" || secretData["immutable"] != false {
+		t.Errorf("Expected the secret type and immutable flag, got %v", secretData)
+	}
+
+	keys := secretData["keys"].([]dataKey)
+	if len(keys) != 4 {
+		t.Fatalf("Expected 4 keys, got %v", keys)
+	}
+	password := keys[0]
+	if password.Name != "password" || password.Length != len("correct-horse") || password.PreviousLength != len("hunter2") {
+		t.Errorf("Unexpected password key description: %+v", password)
+	}
+	if password.Hash != common.KeyedHash([]byte("correct-horse")) {
+		t.Errorf("Expected a keyed hash of the new password value")
+	}
+
+	// Secret values must never be part of the event
+	secretDataJSON, _ := json.Marshal(secretData)
+	for _, value := range []string{"hunter2", "correct-horse", "admin", base64.StdEncoding.EncodeToString([]byte("hunter2"))} {
+		if strings.Contains(string(secretDataJSON), value) {
+			t.Errorf("Secret value: %s was found in the secret data changes: %s", value, secretDataJSON)
+		}
+	}
+}
+
+// TestSecretDataChangesDeleted tests that all keys of a deleted secret are reported as removed
+func TestSecretDataChangesDeleted(t *testing.T) {
+	secret := getTestSecretObject(map[string]string{"password": "hunter2"})
+	secretData := SecretDataChanges(common.EventTypeDeleted, nil, secret)
+	if !reflect.DeepEqual(secretData["keysRemoved"], []string{"password"}) {
+		t.Errorf("Expected removed keys [password], got %v", secretData["keysRemoved"])
+	}
+}
+
+// TestConfigMapDataChanges tests the key level changes of data and binaryData config map keys
+func TestConfigMapDataChanges(t *testing.T) {
+	newConfigMap := map[string]interface{}{
+		"data":       map[string]interface{}{"nginx.conf": "worker_processes 1;"},
+		"binaryData": map[string]interface{}{"logo.png": base64.StdEncoding.EncodeToString([]byte{0x89, 0x50})},
+	}
+	configMapData := ConfigMapDataChanges(common.EventTypeAdded, nil, newConfigMap)
+	if !reflect.DeepEqual(configMapData["keysAdded"], []string{"logo.png", "nginx.conf"}) {
+		t.Errorf("Expected added keys [logo.png nginx.conf], got %v", configMapData["keysAdded"])
+	}
+	keys := configMapData["keys"].([]dataKey)
+	if !keys[0].Binary || keys[0].Length != 2 || keys[1].Binary || keys[1].Length != len("worker_processes 1;") {
+		t.Errorf("Unexpected config map key descriptions: %+v", keys)
+	}
+}
+
+// TestConfigMapDataDiffs tests the unified diffs of changed config map values
+func TestConfigMapDataDiffs(t *testing.T) {
+	oldConfigMap := map[string]interface{}{"data": map[string]interface{}{"nginx.conf": "user nginx;\nworker_processes 1;\n", "mime.types": "types {}\n"}}
+	newConfigMap := map[string]interface{}{"data": map[string]interface{}{"nginx.conf": "user nginx;\nworker_processes 4;\n", "mime.types": "types {}\n"}}
+
+	configMapData := ConfigMapDataChanges(common.EventTypeModified, oldConfigMap, newConfigMap)
+	diffs, ok := configMapData["diffs"].(map[string]interface{})
+	if !ok || len(diffs) != 1 {
+		t.Fatalf("Expected a single config map diff, got %v", configMapData["diffs"])
+	}
+	if diff := diffs["nginx.conf"].(string); !strings.Contains(diff, "-worker_processes 1;\n+worker_processes 4;\n") {
+		t.Errorf("Unexpected nginx.conf diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,194 @@
+package resources
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// deployMarkerPatterns are the configured annotation and label key patterns of deploy markers
+var deployMarkerPatterns []string
+
+// indexedWorkloadKinds are the workload kinds whose metadata is indexed for the events of their related resources
+var indexedWorkloadKinds = []string{"Deployment", "DaemonSet", "StatefulSet", "Rollout"}
+
+// workloadKey identifies a workload in the deploy markers index
+type workloadKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// deployMarkersIndex holds the deploy markers of the workloads in the cluster
+var deployMarkersIndex = map[workloadKey]map[string]string{}
+var deployMarkersMux sync.RWMutex
+
+// ConfigureDeployMarkers loads the configured deploy marker key patterns, invalid patterns are skipped
+func ConfigureDeployMarkers() {
+	deployMarkerPatterns = nil
+	for _, pattern := range common.Config.DeployMarkers {
+		if _, err := globRegexp(pattern); err != nil {
+			log.Printf("[ERROR] Invalid deploy marker key pattern: '%s'.\nERROR:\n%v", pattern, err)
+			continue
+		}
+		deployMarkerPatterns = append(deployMarkerPatterns, pattern)
+	}
+	if len(deployMarkerPatterns) > 0 {
+		log.Printf("Configured %d deploy marker key patterns", len(deployMarkerPatterns))
+	}
+}
+
+// sanitizeFieldName converts an annotation or label key to a field name, for example
+// "ci.example.com/commit-sha" to "ci_example_com_commit_sha"
+func sanitizeFieldName(key string) string {
+	var fieldName strings.Builder
+	for _, char := range strings.ToLower(key) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') {
+			fieldName.WriteRune(char)
+		} else if fieldName.Len() > 0 && !strings.HasSuffix(fieldName.String(), "_") {
+			fieldName.WriteRune('_')
+		}
+	}
+	return strings.TrimSuffix(fieldName.String(), "_")
+}
+
+// objectDeployMarkers returns the deploy markers of an object by their sanitized keys, annotations take precedence over labels
+func objectDeployMarkers(obj map[string]interface{}) (markers map[string]string) {
+	if len(deployMarkerPatterns) == 0 {
+		return nil
+	}
+	for _, field := range []string{common.Labels, common.Annotations} {
+		keys, _, _ := unstructured.NestedStringMap(obj, common.Metadata, field)
+		for key, value := range keys {
+			if matchesAnyPattern(key, deployMarkerPatterns) {
+				if markers == nil {
+					markers = map[string]string{}
+				}
+				markers[sanitizeFieldName(key)] = value
+			}
+		}
+	}
+	return markers
+}
+
+// liftDeployMarkers removes the deploy markers from the annotations and labels of an object
+func liftDeployMarkers(obj map[string]interface{}) {
+	metadata, ok := obj[common.Metadata].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, field := range []string{common.Labels, common.Annotations} {
+		keys, ok := metadata[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range keys {
+			if matchesAnyPattern(key, deployMarkerPatterns) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(metadata, field)
+		}
+	}
+}
+
+// LiftEventDeployMarkers removes the deploy markers from the objects of a parsed event, as they are sent in the deployMarkers field
+func LiftEventDeployMarkers(parsedEvent map[string]interface{}) {
+	if len(deployMarkerPatterns) == 0 {
+		return
+	}
+	for _, objectField := range []string{"newObject", "oldObject"} {
+		if obj, ok := parsedEvent[objectField].(map[string]interface{}); ok {
+			liftDeployMarkers(obj)
+		}
+	}
+}
+
+// IndexDeployMarkers adds the deploy markers of a workload to the index, or removes them if the workload was deleted
+func IndexDeployMarkers(eventType string, obj map[string]interface{}) {
+	if len(deployMarkerPatterns) == 0 {
+		return
+	}
+	workload := &unstructured.Unstructured{Object: obj}
+	if !slices.Contains(indexedWorkloadKinds, workload.GetKind()) {
+		return
+	}
+
+	key := workloadKey{kind: workload.GetKind(), namespace: workload.GetNamespace(), name: workload.GetName()}
+	markers := objectDeployMarkers(obj)
+
+	deployMarkersMux.Lock()
+	defer deployMarkersMux.Unlock()
+	if eventType == common.EventTypeDeleted || len(markers) == 0 {
+		delete(deployMarkersIndex, key)
+	} else {
+		deployMarkersIndex[key] = markers
+	}
+}
+
+// IndexDeployMarkersObject adds the deploy markers of an existing workload to the index, before the informers are synced
+func IndexDeployMarkersObject(obj interface{}) {
+	if workload, ok := obj.(*unstructured.Unstructured); ok {
+		IndexDeployMarkers(common.EventTypeAdded, workload.Object)
+	}
+}
+
+// relatedWorkloadKeys returns the index keys of the related workloads of a resource in its namespace, by kind and name
+func relatedWorkloadKeys(namespace string, relatedServices common.RelatedClusterServices) (keys []workloadKey) {
+	relatedWorkloads := map[string][]string{
+		"Deployment":  relatedServices.Deployments,
+		"DaemonSet":   relatedServices.DaemonSets,
+		"StatefulSet": relatedServices.StatefulSets,
+		"Rollout":     relatedServices.Rollouts,
+	}
+	for _, kind := range indexedWorkloadKinds {
+		names := append([]string{}, relatedWorkloads[kind]...)
+		sort.Strings(names)
+		for _, name := range names {
+			keys = append(keys, workloadKey{kind: kind, namespace: namespace, name: name})
+		}
+	}
+	return keys
+}
+
+// relatedDeployMarkers returns the indexed deploy markers of the related workloads of a resource. When workloads
+// have different values for a key, the value of the first workload by kind and name is used.
+func relatedDeployMarkers(namespace string, relatedServices common.RelatedClusterServices) (markers map[string]string) {
+	deployMarkersMux.RLock()
+	defer deployMarkersMux.RUnlock()
+	for _, key := range relatedWorkloadKeys(namespace, relatedServices) {
+		for markerKey, value := range deployMarkersIndex[key] {
+			if markers == nil {
+				markers = map[string]string{}
+			}
+			if _, ok := markers[markerKey]; !ok {
+				markers[markerKey] = value
+			}
+		}
+	}
+	return markers
+}
+
+// EventDeployMarkers returns the deploy markers of an event resource, with the markers of its related workloads for the
+// keys the resource doesn't set, so a ConfigMap change carries the commit of the workload that uses it
+func EventDeployMarkers(resourceNamespace string, obj map[string]interface{}, relatedServices common.RelatedClusterServices) (markers map[string]string) {
+	if len(deployMarkerPatterns) == 0 {
+		return nil
+	}
+	markers = objectDeployMarkers(obj)
+	for key, value := range relatedDeployMarkers(resourceNamespace, relatedServices) {
+		if markers == nil {
+			markers = map[string]string{}
+		}
+		if _, ok := markers[key]; !ok {
+			markers[key] = value
+		}
+	}
+	return markers
+}
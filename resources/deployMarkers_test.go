@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"main.go/common"
+)
+
+// withDeployMarkerPatterns configures deploy marker key patterns for a test
+func withDeployMarkerPatterns(t *testing.T, patterns ...string) {
+	common.Config.DeployMarkers = patterns
+	ConfigureDeployMarkers()
+	t.Cleanup(func() {
+		common.Config.DeployMarkers = nil
+		ConfigureDeployMarkers()
+		deployMarkersIndex = map[workloadKey]map[string]string{}
+	})
+}
+
+// getTestMarkedObject returns an object of a kind with annotations and labels
+func getTestMarkedObject(kind, name string, annotations, labels map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{"name": name, "namespace": "default"}
+	if annotations != nil {
+		metadata["annotations"] = annotations
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return map[string]interface{}{"kind": kind, "metadata": metadata}
+}
+
+// TestSanitizeFieldName tests converting annotation and label keys to field names
+func TestSanitizeFieldName(t *testing.T) {
+	testCases := map[string]string{
+		"ci.example.com/commit-sha": "ci_example_com_commit_sha",
+		"logzio.io/Pipeline_URL":    "logzio_io_pipeline_url",
+		"--ticket--id--":            "ticket_id",
+	}
+	for key, expected := range testCases {
+		if fieldName := sanitizeFieldName(key); fieldName != expected {
+			t.Errorf("Expected field name %s for key %s, got %s", expected, key, fieldName)
+		}
+	}
+}
+
+// TestEventDeployMarkers tests lifting the deploy markers of resources and passing them to related resources
+func TestEventDeployMarkers(t *testing.T) {
+	withDeployMarkerPatterns(t, "ci.example.com/*", "logzio.io/*", "[invalid")
+	if len(deployMarkerPatterns) != 2 {
+		t.Fatalf("Expected the invalid pattern to be skipped, got %v", deployMarkerPatterns)
+	}
+
+	deployment := getTestMarkedObject("Deployment", "web",
+		map[string]interface{}{"ci.example.com/commit-sha": "abc123", "ci.example.com/deployer": "jane", "other.io/key": "value"},
+		map[string]interface{}{"logzio.io/ticket": "OPS-1"})
+	markers := EventDeployMarkers("default", deployment, common.RelatedClusterServices{})
+	expected := map[string]string{"ci_example_com_commit_sha": "abc123", "ci_example_com_deployer": "jane", "logzio_io_ticket": "OPS-1"}
+	if !reflect.DeepEqual(markers, expected) {
+		t.Errorf("Expected deploy markers %v, got %v", expected, markers)
+	}
+
+	// Patterns without a prefix match prefixed keys
+	withDeployMarkerPatterns(t, "*commit-sha")
+	if markers = EventDeployMarkers("default", deployment, common.RelatedClusterServices{}); !reflect.DeepEqual(markers, map[string]string{"ci_example_com_commit_sha": "abc123"}) {
+		t.Errorf("Expected the commit SHA deploy marker, got %v", markers)
+	}
+	withDeployMarkerPatterns(t, "ci.example.com/*", "logzio.io/*")
+
+	// A config map used by the deployment carries its markers, its own markers take precedence
+	IndexDeployMarkers(common.EventTypeModified, deployment)
+	configMap := getTestMarkedObject("ConfigMap", "web-config", map[string]interface{}{"ci.example.com/deployer": "john"}, nil)
+	related := common.RelatedClusterServices{Deployments: []string{"web"}}
+	markers = EventDeployMarkers("default", configMap, related)
+	expected = map[string]string{"ci_example_com_commit_sha": "abc123", "ci_example_com_deployer": "john", "logzio_io_ticket": "OPS-1"}
+	if !reflect.DeepEqual(markers, expected) {
+		t.Errorf("Expected deploy markers %v, got %v", expected, markers)
+	}
+
+	// Workloads in other namespaces and deleted workloads aren't related
+	if markers = EventDeployMarkers("other", getTestMarkedObject("ConfigMap", "web-config", nil, nil), related); markers != nil {
+		t.Errorf("Expected no deploy markers in another namespace, got %v", markers)
+	}
+	IndexDeployMarkers(common.EventTypeDeleted, deployment)
+	if markers = EventDeployMarkers("default", getTestMarkedObject("ConfigMap", "web-config", nil, nil), related); markers != nil {
+		t.Errorf("Expected no deploy markers of a deleted workload, got %v", markers)
+	}
+}
+
+// TestLiftEventDeployMarkers tests removing the deploy markers from the objects of sent events
+func TestLiftEventDeployMarkers(t *testing.T) {
+	withDeployMarkerPatterns(t, "ci.example.com/*")
+
+	parsedEvent := map[string]interface{}{
+		"newObject": getTestMarkedObject("Deployment", "web", map[string]interface{}{"ci.example.com/commit-sha": "abc123", "other.io/key": "value"}, map[string]interface{}{"ci.example.com/ticket": "OPS-1"}),
+		"oldObject": getTestMarkedObject("Deployment", "web", map[string]interface{}{"ci.example.com/commit-sha": "def456"}, nil),
+	}
+	LiftEventDeployMarkers(parsedEvent)
+
+	expected := map[string]interface{}{"name": "web", "namespace": "default", "annotations": map[string]interface{}{"other.io/key": "value"}}
+	if metadata := parsedEvent["newObject"].(map[string]interface{})["metadata"]; !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected new object metadata %v, got %v", expected, metadata)
+	}
+	expected = map[string]interface{}{"name": "web", "namespace": "default"}
+	if metadata := parsedEvent["oldObject"].(map[string]interface{})["metadata"]; !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected old object metadata %v, got %v", expected, metadata)
+	}
+}
@@ -0,0 +1,170 @@
+package resources
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a single step of a field path, either a map key, an array index or a wildcard
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseFieldPath parses a field path in a simple JSONPath form, for example:
+// "$.spec.template.metadata.annotations['kubectl.kubernetes.io/restartedAt']" or "spec.containers[*].image".
+// Dots inside unquoted keys can be escaped with a backslash.
+func parseFieldPath(path string) (segments []pathSegment, err error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	var key strings.Builder
+	flushKey := func() {
+		if key.Len() > 0 {
+			if key.String() == "*" {
+				segments = append(segments, pathSegment{wildcard: true})
+			} else {
+				segments = append(segments, pathSegment{key: key.String()})
+			}
+			key.Reset()
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			// Escaped character, take the next character literally
+			if i+1 >= len(path) {
+				return nil, fmt.Errorf("field path '%s' ends with an escape character", path)
+			}
+			i++
+			key.WriteByte(path[i])
+		case '.':
+			flushKey()
+		case '[':
+			flushKey()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("field path '%s' has an unclosed bracket", path)
+			}
+			inner := path[i+1 : i+end]
+			i += end
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, convErr := strconv.Atoi(inner)
+				if convErr != nil || index < 0 {
+					return nil, fmt.Errorf("field path '%s' has an invalid index: '%s'", path, inner)
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	flushKey()
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("field path '%s' has no fields", path)
+	}
+	return segments, nil
+}
+
+// lookupFieldPath returns all values in the object that match the field path
+func lookupFieldPath(obj interface{}, segments []pathSegment) (values []interface{}) {
+	if len(segments) == 0 {
+		return []interface{}{obj}
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch typedObj := obj.(type) {
+	case map[string]interface{}:
+		if segment.wildcard {
+			for _, key := range sortedKeys(typedObj) {
+				values = append(values, lookupFieldPath(typedObj[key], rest)...)
+			}
+		} else if value, ok := typedObj[segment.key]; ok && !segment.isIndex {
+			values = lookupFieldPath(value, rest)
+		}
+	case []interface{}:
+		if segment.wildcard {
+			for _, item := range typedObj {
+				values = append(values, lookupFieldPath(item, rest)...)
+			}
+		} else if segment.isIndex && segment.index < len(typedObj) {
+			values = lookupFieldPath(typedObj[segment.index], rest)
+		}
+	}
+	return values
+}
+
+// removeFieldPath deletes all fields in the object that match the field path.
+// Maps that are left empty by the removal are removed as well.
+func removeFieldPath(obj interface{}, segments []pathSegment) (removed bool) {
+	if len(segments) == 0 {
+		return false
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch typedObj := obj.(type) {
+	case map[string]interface{}:
+		var keys []string
+		if segment.wildcard {
+			keys = sortedKeys(typedObj)
+		} else if _, ok := typedObj[segment.key]; ok && !segment.isIndex {
+			keys = []string{segment.key}
+		}
+		for _, key := range keys {
+			if len(rest) == 0 {
+				delete(typedObj, key)
+				removed = true
+				continue
+			}
+			if removeFieldPath(typedObj[key], rest) {
+				removed = true
+				if nested, ok := typedObj[key].(map[string]interface{}); ok && len(nested) == 0 {
+					delete(typedObj, key)
+				}
+			}
+		}
+	case []interface{}:
+		// Array items are never deleted, to keep the indexes of the other items stable
+		if len(rest) == 0 {
+			return false
+		}
+		for index, item := range typedObj {
+			if segment.wildcard || (segment.isIndex && segment.index == index) {
+				if removeFieldPath(item, rest) {
+					removed = true
+				}
+			}
+		}
+	}
+	return removed
+}
+
+// sortedKeys returns the keys of a map in a stable order
+func sortedKeys(obj map[string]interface{}) (keys []string) {
+	keys = make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldPathValuesEqual checks whether a field path matches the same values in two objects
+func fieldPathValuesEqual(oldObj, newObj interface{}, segments []pathSegment) bool {
+	return reflect.DeepEqual(lookupFieldPath(oldObj, segments), lookupFieldPath(newObj, segments))
+}
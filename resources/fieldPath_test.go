@@ -0,0 +1,103 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+)
+
+// getTestFieldPathObject returns an object for testing field paths
+func getTestFieldPathObject() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/restartedAt": "2024-01-01T00:00:00Z",
+			},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "nginx:1.25"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy:1.29"},
+			},
+		},
+	}
+}
+
+// TestParseFieldPath tests parsing of the supported field path forms
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []pathSegment
+	}{
+		{"spec.replicas", []pathSegment{{key: "spec"}, {key: "replicas"}}},
+		{"$.spec.containers[*].image", []pathSegment{{key: "spec"}, {key: "containers"}, {wildcard: true}, {key: "image"}}},
+		{".spec.containers[1]", []pathSegment{{key: "spec"}, {key: "containers"}, {index: 1, isIndex: true}}},
+		{"metadata.annotations['kubectl.kubernetes.io/restartedAt']", []pathSegment{{key: "metadata"}, {key: "annotations"}, {key: "kubectl.kubernetes.io/restartedAt"}}},
+		{`metadata.annotations.argocd\.argoproj\.io/tracking-id`, []pathSegment{{key: "metadata"}, {key: "annotations"}, {key: "argocd.argoproj.io/tracking-id"}}},
+		{"metadata.labels.*", []pathSegment{{key: "metadata"}, {key: "labels"}, {wildcard: true}}},
+	}
+	for _, test := range tests {
+		segments, err := parseFieldPath(test.path)
+		if err != nil {
+			t.Errorf("Failed to parse field path: %s\nERROR: %v", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(segments, test.expected) {
+			t.Errorf("Unexpected segments for field path: %s, expected %+v, got %+v", test.path, test.expected, segments)
+		}
+	}
+
+	for _, invalidPath := range []string{"", "$", "spec.containers[", "spec.containers[x]", `spec\`} {
+		if _, err := parseFieldPath(invalidPath); err == nil {
+			t.Errorf("Expected an error for invalid field path: '%s'", invalidPath)
+		}
+	}
+}
+
+// TestLookupFieldPath tests looking up values by field path
+func TestLookupFieldPath(t *testing.T) {
+	obj := getTestFieldPathObject()
+	segments, _ := parseFieldPath("spec.containers[*].image")
+	images := lookupFieldPath(obj, segments)
+	if !reflect.DeepEqual(images, []interface{}{"nginx:1.25", "envoy:1.29"}) {
+		t.Errorf("Unexpected container images: %v", images)
+	}
+
+	segments, _ = parseFieldPath("spec.containers[1].name")
+	names := lookupFieldPath(obj, segments)
+	if !reflect.DeepEqual(names, []interface{}{"sidecar"}) {
+		t.Errorf("Unexpected container name: %v", names)
+	}
+
+	segments, _ = parseFieldPath("spec.missing")
+	if values := lookupFieldPath(obj, segments); len(values) != 0 {
+		t.Errorf("Expected no values for a missing field, got %v", values)
+	}
+}
+
+// TestRemoveFieldPath tests removing fields by field path
+func TestRemoveFieldPath(t *testing.T) {
+	obj := getTestFieldPathObject()
+	segments, _ := parseFieldPath("metadata.annotations['kubectl.kubernetes.io/restartedAt']")
+	if !removeFieldPath(obj, segments) {
+		t.Errorf("Expected the restartedAt annotation to be removed")
+	}
+	if _, ok := obj["metadata"]; ok {
+		t.Errorf("Expected the emptied metadata map to be removed, got %v", obj["metadata"])
+	}
+
+	segments, _ = parseFieldPath("spec.containers[*].image")
+	if !removeFieldPath(obj, segments) {
+		t.Errorf("Expected the container images to be removed")
+	}
+	containers := obj["spec"].(map[string]interface{})["containers"].([]interface{})
+	if len(containers) != 2 {
+		t.Fatalf("Expected the containers to be kept, got %v", containers)
+	}
+	if _, ok := containers[0].(map[string]interface{})["image"]; ok {
+		t.Errorf("Expected the container image to be removed, got %v", containers[0])
+	}
+
+	if removeFieldPath(obj, segments) {
+		t.Errorf("Expected nothing to be removed on the second attempt")
+	}
+}
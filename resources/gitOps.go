@@ -0,0 +1,346 @@
+package resources
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"main.go/common"
+)
+
+// GitOps tools that apply resources
+const (
+	GitOpsToolArgoCD = "argocd"
+	GitOpsToolFlux   = "flux"
+)
+
+// GitOps tracking labels and annotations
+const (
+	argoCDTrackingIDAnnotation = "argocd.argoproj.io/tracking-id"
+	fluxKustomizationName      = "kustomize.toolkit.fluxcd.io/name"
+	fluxKustomizationNamespace = "kustomize.toolkit.fluxcd.io/namespace"
+	fluxHelmReleaseName        = "helm.toolkit.fluxcd.io/name"
+	fluxHelmReleaseNamespace   = "helm.toolkit.fluxcd.io/namespace"
+)
+
+// GitOps object resources, in the order their versions are looked up
+var (
+	argoCDApplicationGVRs = []schema.GroupVersionResource{
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+	}
+	fluxKustomizationGVRs = []schema.GroupVersionResource{
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"},
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Resource: "kustomizations"},
+	}
+	fluxHelmReleaseGVRs = []schema.GroupVersionResource{
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Resource: "helmreleases"},
+	}
+	fluxSourceGVRs = map[string][]schema.GroupVersionResource{
+		"GitRepository": {
+			{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "gitrepositories"},
+			{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "gitrepositories"},
+		},
+		"HelmRepository": {
+			{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "helmrepositories"},
+			{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "helmrepositories"},
+		},
+		"OCIRepository": {
+			{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "ocirepositories"},
+		},
+		"Bucket": {
+			{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "buckets"},
+			{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "buckets"},
+		},
+	}
+)
+
+// GitOpsMetadata describes the GitOps object that applied a resource
+type GitOpsMetadata struct {
+	Tool         string `json:"tool"`
+	Kind         string `json:"kind"`
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	SourceRepo   string `json:"sourceRepo,omitempty"`
+	SourcePath   string `json:"sourcePath,omitempty"`
+	Revision     string `json:"revision,omitempty"`
+	SyncStatus   string `json:"syncStatus,omitempty"`
+	HealthStatus string `json:"healthStatus,omitempty"`
+	// SyncStartedAt identifies the Argo CD sync, as reported in GITOPS_TRANSITION events
+	SyncStartedAt string `json:"syncStartedAt,omitempty"`
+}
+
+// gitOpsOwner identifies the GitOps object that applied a resource
+type gitOpsOwner struct {
+	tool      string
+	kind      string
+	name      string
+	namespace string
+}
+
+// gitOpsCacheEntry is a looked up GitOps object, a nil metadata caches objects that were not found
+type gitOpsCacheEntry struct {
+	owner    gitOpsOwner
+	metadata *GitOpsMetadata
+	expires  time.Time
+}
+
+// gitOpsCache caches the looked up GitOps objects, the most recently used first
+var gitOpsCache = list.New()
+
+// gitOpsCacheIndex indexes the elements of gitOpsCache by owner
+var gitOpsCacheIndex = map[gitOpsOwner]*list.Element{}
+
+// gitOpsCacheMux guards gitOpsCache and gitOpsCacheIndex
+var gitOpsCacheMux sync.Mutex
+
+// cachedGitOpsMetadata returns the cached metadata of an owner, if it is cached and didn't expire
+func cachedGitOpsMetadata(owner gitOpsOwner) (metadata *GitOpsMetadata, ok bool) {
+	gitOpsCacheMux.Lock()
+	defer gitOpsCacheMux.Unlock()
+	element, cached := gitOpsCacheIndex[owner]
+	if !cached {
+		return nil, false
+	}
+	entry := element.Value.(*gitOpsCacheEntry)
+	if !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+	gitOpsCache.MoveToFront(element)
+	return entry.metadata, true
+}
+
+// cacheGitOpsMetadata caches the metadata of an owner, evicting the least recently used owners over the cache size
+func cacheGitOpsMetadata(owner gitOpsOwner, metadata *GitOpsMetadata) {
+	cacheTTL := common.Config.GitOps.CacheTTL.Duration
+	if cacheTTL <= 0 {
+		cacheTTL = common.DefaultGitOpsCacheTTL
+	}
+	cacheSize := common.Config.GitOps.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = common.DefaultGitOpsCacheSize
+	}
+
+	gitOpsCacheMux.Lock()
+	defer gitOpsCacheMux.Unlock()
+	entry := &gitOpsCacheEntry{owner: owner, metadata: metadata, expires: time.Now().Add(cacheTTL)}
+	if element, cached := gitOpsCacheIndex[owner]; cached {
+		element.Value = entry
+		gitOpsCache.MoveToFront(element)
+	} else {
+		gitOpsCacheIndex[owner] = gitOpsCache.PushFront(entry)
+	}
+	for gitOpsCache.Len() > cacheSize {
+		oldest := gitOpsCache.Back()
+		gitOpsCache.Remove(oldest)
+		delete(gitOpsCacheIndex, oldest.Value.(*gitOpsCacheEntry).owner)
+	}
+}
+
+// gitOpsClient returns the dynamic client used to look up GitOps objects, or nil if it isn't configured
+func gitOpsClient() dynamic.Interface {
+	if common.DynamicClient == nil || common.Config.GitOps.Disabled {
+		return nil
+	}
+	return common.DynamicClient
+}
+
+// argoCDApplicationOwner returns the Argo CD Application of an application name,
+// which is prefixed by the Application namespace for Applications outside the Argo CD namespace
+func argoCDApplicationOwner(appName string) gitOpsOwner {
+	namespace := common.Config.GitOps.ArgoCDNamespace
+	if namespace == "" {
+		namespace = common.DefaultArgoCDNamespace
+	}
+	if appNamespace, name, found := strings.Cut(appName, "_"); found {
+		namespace, appName = appNamespace, name
+	}
+	return gitOpsOwner{tool: GitOpsToolArgoCD, kind: "Application", name: appName, namespace: namespace}
+}
+
+// resourceGitOpsOwner returns the GitOps object that applied a resource, according to its tracking labels and annotations
+func resourceGitOpsOwner(obj map[string]interface{}) (owner gitOpsOwner, ok bool) {
+	labels, _, _ := unstructured.NestedStringMap(obj, common.Metadata, common.Labels)
+	annotations, _, _ := unstructured.NestedStringMap(obj, common.Metadata, common.Annotations)
+
+	// The tracking ID format is <application>:<group>/<kind>:<namespace>/<name>
+	if trackingID := annotations[argoCDTrackingIDAnnotation]; trackingID != "" {
+		appName, _, _ := strings.Cut(trackingID, ":")
+		return argoCDApplicationOwner(appName), true
+	}
+	if name := labels[fluxKustomizationName]; name != "" {
+		return gitOpsOwner{tool: GitOpsToolFlux, kind: "Kustomization", name: name, namespace: labels[fluxKustomizationNamespace]}, true
+	}
+	if name := labels[fluxHelmReleaseName]; name != "" {
+		return gitOpsOwner{tool: GitOpsToolFlux, kind: "HelmRelease", name: name, namespace: labels[fluxHelmReleaseNamespace]}, true
+	}
+
+	// Helm charts set the same label, so these owners are only reported if the Application exists
+	instanceLabel := common.Config.GitOps.ArgoCDInstanceLabel
+	if instanceLabel == "" {
+		instanceLabel = common.DefaultArgoCDInstanceLabel
+	}
+	if appName := labels[instanceLabel]; appName != "" {
+		return argoCDApplicationOwner(appName), true
+	}
+	return owner, false
+}
+
+// getGitOpsObject gets an object from the cache of its informer, or if its resource isn't watched by trying each version
+// of its resource, returning nil if it isn't found
+func getGitOpsObject(client dynamic.Interface, gvrs []schema.GroupVersionResource, namespace, name string) (obj *unstructured.Unstructured, err error) {
+	if obj, ok := informerObject(gvrs, namespace, name); ok {
+		return obj, nil
+	}
+	for _, gvr := range gvrs {
+		obj, err = client.Resource(gvr).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err == nil {
+			return obj, nil
+		}
+		// Also returned when this version of the resource isn't served
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// fluxCondition returns the status and reason of a condition of a Flux object
+func fluxCondition(obj *unstructured.Unstructured, conditionType string) (status string, reason string) {
+	condition := objectConditions(obj)[conditionType]
+	return conditionField(condition, "status"), conditionField(condition, "reason")
+}
+
+// argoCDApplicationMetadata returns the GitOps metadata of an Argo CD Application
+func argoCDApplicationMetadata(app *unstructured.Unstructured) *GitOpsMetadata {
+	metadata := &GitOpsMetadata{Tool: GitOpsToolArgoCD, Kind: "Application", Name: app.GetName(), Namespace: app.GetNamespace()}
+	source, found, _ := unstructured.NestedMap(app.Object, "spec", "source")
+	if !found {
+		// Multiple source Applications report their first source
+		if sources, _, _ := unstructured.NestedSlice(app.Object, "spec", "sources"); len(sources) > 0 {
+			source, _ = sources[0].(map[string]interface{})
+		}
+	}
+	metadata.SourceRepo, _, _ = unstructured.NestedString(source, "repoURL")
+	metadata.SourcePath, _, _ = unstructured.NestedString(source, "path")
+	if metadata.SourcePath == "" {
+		metadata.SourcePath, _, _ = unstructured.NestedString(source, "chart")
+	}
+
+	// A running sync applies the revision of its operation
+	phase, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "phase")
+	if phase == "Running" {
+		metadata.Revision, _, _ = unstructured.NestedString(app.Object, "status", "operationState", "operation", "sync", "revision")
+	}
+	if metadata.Revision == "" {
+		metadata.Revision, _, _ = unstructured.NestedString(app.Object, "status", "sync", "revision")
+	}
+	if metadata.Revision == "" {
+		if revisions, _, _ := unstructured.NestedStringSlice(app.Object, "status", "sync", "revisions"); len(revisions) > 0 {
+			metadata.Revision = revisions[0]
+		}
+	}
+	metadata.SyncStartedAt, _, _ = unstructured.NestedString(app.Object, "status", "operationState", "startedAt")
+	metadata.SyncStatus, _, _ = unstructured.NestedString(app.Object, "status", "sync", "status")
+	metadata.HealthStatus, _, _ = unstructured.NestedString(app.Object, "status", "health", "status")
+	return metadata
+}
+
+// fluxSourceURL returns the URL of a Flux source
+func fluxSourceURL(client dynamic.Interface, sourceRef map[string]interface{}, defaultNamespace string) string {
+	kind, _, _ := unstructured.NestedString(sourceRef, "kind")
+	name, _, _ := unstructured.NestedString(sourceRef, "name")
+	namespace, _, _ := unstructured.NestedString(sourceRef, "namespace")
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	gvrs, ok := fluxSourceGVRs[kind]
+	if !ok || name == "" {
+		return ""
+	}
+	source, err := getGitOpsObject(client, gvrs, namespace, name)
+	if err != nil || source == nil {
+		return ""
+	}
+	url, _, _ := unstructured.NestedString(source.Object, "spec", "url")
+	return url
+}
+
+// fluxMetadata returns the GitOps metadata of a Flux Kustomization or HelmRelease
+func fluxMetadata(client dynamic.Interface, obj *unstructured.Unstructured) *GitOpsMetadata {
+	metadata := &GitOpsMetadata{Tool: GitOpsToolFlux, Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace()}
+	var sourceRef map[string]interface{}
+	if obj.GetKind() == "HelmRelease" {
+		sourceRef, _, _ = unstructured.NestedMap(obj.Object, "spec", "chart", "spec", "sourceRef")
+		if sourceRef == nil {
+			sourceRef, _, _ = unstructured.NestedMap(obj.Object, "spec", "chartRef")
+		}
+		metadata.SourcePath, _, _ = unstructured.NestedString(obj.Object, "spec", "chart", "spec", "chart")
+		metadata.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "lastAppliedRevision")
+		if history, _, _ := unstructured.NestedSlice(obj.Object, "status", "history"); metadata.Revision == "" && len(history) > 0 {
+			latest, _ := history[0].(map[string]interface{})
+			metadata.Revision, _, _ = unstructured.NestedString(latest, "chartVersion")
+		}
+	} else {
+		sourceRef, _, _ = unstructured.NestedMap(obj.Object, "spec", "sourceRef")
+		metadata.SourcePath, _, _ = unstructured.NestedString(obj.Object, "spec", "path")
+		metadata.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "lastAppliedRevision")
+	}
+	metadata.SourceRepo = fluxSourceURL(client, sourceRef, obj.GetNamespace())
+
+	// Flux reports the reconciliation result in the Ready condition reason, such as ReconciliationSucceeded
+	_, metadata.SyncStatus = fluxCondition(obj, "Ready")
+	metadata.HealthStatus, _ = fluxCondition(obj, "Healthy")
+	return metadata
+}
+
+// lookupGitOpsMetadata looks up the GitOps object of an owner in the cache of its informer. Owners whose resource isn't
+// watched aren't found, since tracking labels such as the instance label are common on objects without owners.
+func lookupGitOpsMetadata(client dynamic.Interface, owner gitOpsOwner) (metadata *GitOpsMetadata) {
+	var gvrs []schema.GroupVersionResource
+	switch owner.kind {
+	case "Application":
+		gvrs = argoCDApplicationGVRs
+	case "Kustomization":
+		gvrs = fluxKustomizationGVRs
+	case "HelmRelease":
+		gvrs = fluxHelmReleaseGVRs
+	}
+	obj, _ := informerObject(gvrs, owner.namespace, owner.name)
+	if obj == nil {
+		return nil
+	}
+	if owner.tool == GitOpsToolArgoCD {
+		return argoCDApplicationMetadata(obj)
+	}
+	return fluxMetadata(client, obj)
+}
+
+// ResourceGitOpsMetadata returns the metadata of the Argo CD Application or Flux Kustomization or HelmRelease
+// that applied a resource, including its source repository, revision and sync status. Lookups are cached, up to the
+// cache size.
+func ResourceGitOpsMetadata(client dynamic.Interface, obj map[string]interface{}) *GitOpsMetadata {
+	if client == nil {
+		return nil
+	}
+	owner, ok := resourceGitOpsOwner(obj)
+	if !ok || owner.name == "" {
+		return nil
+	}
+
+	if metadata, cached := cachedGitOpsMetadata(owner); cached {
+		return metadata
+	}
+
+	metadata := lookupGitOpsMetadata(client, owner)
+	cacheGitOpsMetadata(owner, metadata)
+	return metadata
+}
@@ -0,0 +1,192 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// GitOps transitions reported in GITOPS_TRANSITION events
+const (
+	GitOpsTransitionSyncStarted          = "sync-started"
+	GitOpsTransitionSynced               = "synced"
+	GitOpsTransitionOutOfSync            = "out-of-sync"
+	GitOpsTransitionDegraded             = "degraded"
+	GitOpsTransitionReconciliationFailed = "reconciliation-failed"
+)
+
+// GitOpsTransition describes a sync or health transition of an Argo CD or Flux object
+type GitOpsTransition struct {
+	Tool             string `json:"tool"`
+	Transition       string `json:"transition"`
+	Revision         string `json:"revision,omitempty"`
+	PreviousRevision string `json:"previousRevision,omitempty"`
+	SyncStatus       string `json:"syncStatus,omitempty"`
+	HealthStatus     string `json:"healthStatus,omitempty"`
+	Message          string `json:"message,omitempty"`
+	SyncStartedAt    string `json:"syncStartedAt,omitempty"`
+}
+
+// gitOpsKinds are the kinds with GitOps transitions, by API group
+var gitOpsKinds = map[string]string{
+	"argoproj.io":                 "Application",
+	"kustomize.toolkit.fluxcd.io": "Kustomization",
+	"helm.toolkit.fluxcd.io":      "HelmRelease",
+	"source.toolkit.fluxcd.io":    "GitRepository",
+}
+
+// isGitOpsKind checks whether an object is an Argo CD or Flux object with transitions
+func isGitOpsKind(obj *unstructured.Unstructured) bool {
+	kind, ok := gitOpsKinds[obj.GroupVersionKind().Group]
+	return ok && kind == obj.GetKind()
+}
+
+// nestedString returns a nested string field of an object, or an empty string
+func nestedString(obj *unstructured.Unstructured, fields ...string) string {
+	value, _, _ := unstructured.NestedString(obj.Object, fields...)
+	return value
+}
+
+// argoCDTransitions returns the sync and health transitions of an Argo CD Application
+func argoCDTransitions(oldApp, newApp *unstructured.Unstructured) (transitions []GitOpsTransition) {
+	oldPhase, phase := nestedString(oldApp, "status", "operationState", "phase"), nestedString(newApp, "status", "operationState", "phase")
+	oldStartedAt, startedAt := nestedString(oldApp, "status", "operationState", "startedAt"), nestedString(newApp, "status", "operationState", "startedAt")
+	oldSyncStatus, syncStatus := nestedString(oldApp, "status", "sync", "status"), nestedString(newApp, "status", "sync", "status")
+	oldHealthStatus, healthStatus := nestedString(oldApp, "status", "health", "status"), nestedString(newApp, "status", "health", "status")
+	previousRevision := nestedString(oldApp, "status", "sync", "revision")
+	revision := nestedString(newApp, "status", "sync", "revision")
+	operationMessage := nestedString(newApp, "status", "operationState", "message")
+
+	newTransition := func(transition string, message string) GitOpsTransition {
+		return GitOpsTransition{
+			Tool:             GitOpsToolArgoCD,
+			Transition:       transition,
+			Revision:         revision,
+			PreviousRevision: previousRevision,
+			SyncStatus:       syncStatus,
+			HealthStatus:     healthStatus,
+			Message:          message,
+			SyncStartedAt:    startedAt,
+		}
+	}
+
+	isNewOperation := startedAt != oldStartedAt
+	if phase == "Running" && (oldPhase != "Running" || isNewOperation) {
+		transition := newTransition(GitOpsTransitionSyncStarted, operationMessage)
+		// The sync applies the revision of its operation
+		if operationRevision := nestedString(newApp, "status", "operationState", "operation", "sync", "revision"); operationRevision != "" {
+			transition.Revision = operationRevision
+		}
+		transitions = append(transitions, transition)
+	}
+	if (phase == "Failed" || phase == "Error") && (phase != oldPhase || isNewOperation) {
+		transitions = append(transitions, newTransition(GitOpsTransitionReconciliationFailed, operationMessage))
+	}
+	if syncStatus != oldSyncStatus {
+		switch syncStatus {
+		case "Synced":
+			transitions = append(transitions, newTransition(GitOpsTransitionSynced, operationMessage))
+		case "OutOfSync":
+			transitions = append(transitions, newTransition(GitOpsTransitionOutOfSync, ""))
+		}
+	}
+	if healthStatus == "Degraded" && oldHealthStatus != "Degraded" {
+		transitions = append(transitions, newTransition(GitOpsTransitionDegraded, nestedString(newApp, "status", "health", "message")))
+	}
+	return transitions
+}
+
+// fluxRevision returns the revision of a Flux object, the artifact revision of sources
+// and the last applied revision of reconcilers
+func fluxRevision(obj *unstructured.Unstructured) string {
+	if obj.GetKind() == "GitRepository" {
+		return nestedString(obj, "status", "artifact", "revision")
+	}
+	return nestedString(obj, "status", "lastAppliedRevision")
+}
+
+// fluxTransitions returns the reconciliation transitions of a Flux Kustomization, HelmRelease or GitRepository
+func fluxTransitions(oldObj, newObj *unstructured.Unstructured) (transitions []GitOpsTransition) {
+	oldConditions, newConditions := objectConditions(oldObj), objectConditions(newObj)
+	oldReady, ready := conditionField(oldConditions["Ready"], "status"), conditionField(newConditions["Ready"], "status")
+	oldReconciling, reconciling := conditionField(oldConditions["Reconciling"], "status"), conditionField(newConditions["Reconciling"], "status")
+	oldHealthy, healthy := conditionField(oldConditions["Healthy"], "status"), conditionField(newConditions["Healthy"], "status")
+	previousRevision, revision := fluxRevision(oldObj), fluxRevision(newObj)
+	readyMessage := conditionField(newConditions["Ready"], "message")
+
+	newTransition := func(transition string, message string) GitOpsTransition {
+		return GitOpsTransition{
+			Tool:             GitOpsToolFlux,
+			Transition:       transition,
+			Revision:         revision,
+			PreviousRevision: previousRevision,
+			SyncStatus:       conditionField(newConditions["Ready"], "reason"),
+			HealthStatus:     healthy,
+			Message:          message,
+		}
+	}
+
+	if newObj.GetKind() == "GitRepository" {
+		// A new source revision is out of sync until its dependents reconcile it
+		if revision != previousRevision && previousRevision != "" {
+			transitions = append(transitions, newTransition(GitOpsTransitionOutOfSync, readyMessage))
+		}
+	} else {
+		if reconciling == "True" && oldReconciling != "True" {
+			transition := newTransition(GitOpsTransitionSyncStarted, conditionField(newConditions["Reconciling"], "message"))
+			if attemptedRevision := nestedString(newObj, "status", "lastAttemptedRevision"); attemptedRevision != "" {
+				transition.Revision = attemptedRevision
+			}
+			transitions = append(transitions, transition)
+		}
+		if ready == "True" && (oldReady != "True" || revision != previousRevision) {
+			transitions = append(transitions, newTransition(GitOpsTransitionSynced, readyMessage))
+		}
+		if healthy == "False" && oldHealthy != "False" {
+			transitions = append(transitions, newTransition(GitOpsTransitionDegraded, conditionField(newConditions["Healthy"], "message")))
+		}
+	}
+	if ready == "False" && oldReady != "False" {
+		transitions = append(transitions, newTransition(GitOpsTransitionReconciliationFailed, readyMessage))
+	}
+	return transitions
+}
+
+// GitOpsTransitions returns the sync and health transitions between the old and new versions of an Argo CD
+// Application or a Flux Kustomization, HelmRelease or GitRepository
+func GitOpsTransitions(oldObj, newObj interface{}) (transitions []GitOpsTransition) {
+	oldUnst, ok1 := oldObj.(*unstructured.Unstructured)
+	newUnst, ok2 := newObj.(*unstructured.Unstructured)
+	if !ok1 || !ok2 || !isGitOpsKind(newUnst) {
+		return nil
+	}
+
+	// The cached metadata of the object is outdated
+	forgetGitOpsMetadata(newUnst.GetKind(), newUnst.GetNamespace(), newUnst.GetName())
+	if newUnst.GetKind() == "Application" {
+		return argoCDTransitions(oldUnst, newUnst)
+	}
+	return fluxTransitions(oldUnst, newUnst)
+}
+
+// forgetGitOpsMetadata removes the cached metadata of a GitOps object
+func forgetGitOpsMetadata(kind, namespace, name string) {
+	owner := gitOpsOwner{tool: GitOpsToolFlux, kind: kind, name: name, namespace: namespace}
+	if kind == "Application" {
+		owner.tool = GitOpsToolArgoCD
+	}
+	gitOpsCacheMux.Lock()
+	defer gitOpsCacheMux.Unlock()
+	if element, cached := gitOpsCacheIndex[owner]; cached {
+		gitOpsCache.Remove(element)
+		delete(gitOpsCacheIndex, owner)
+	}
+}
+
+// gitOpsTransitionEvent returns the event of a GitOps transition
+func gitOpsTransitionEvent(newObj interface{}, transition GitOpsTransition) map[string]interface{} {
+	return map[string]interface{}{
+		"newObject":  newObj,
+		"eventType":  common.EventTypeGitOpsTransition,
+		"transition": transition,
+	}
+}
@@ -0,0 +1,148 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// getTestApplication returns an Argo CD Application with the given operation phase, sync and health statuses
+func getTestApplication(phase, startedAt, syncStatus, healthStatus, revision string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "argocd"},
+		"status": map[string]interface{}{
+			"operationState": map[string]interface{}{
+				"phase":     phase,
+				"startedAt": startedAt,
+				"message":   "operation message",
+				"operation": map[string]interface{}{"sync": map[string]interface{}{"revision": "target"}},
+			},
+			"sync":   map[string]interface{}{"status": syncStatus, "revision": revision},
+			"health": map[string]interface{}{"status": healthStatus, "message": "health message"},
+		},
+	}}
+}
+
+// getTestKustomization returns a Flux Kustomization with the given condition statuses
+func getTestKustomization(ready, reconciling, healthy, revision string) *unstructured.Unstructured {
+	var conditions []interface{}
+	for conditionType, status := range map[string]string{"Ready": ready, "Reconciling": reconciling, "Healthy": healthy} {
+		if status != "" {
+			conditions = append(conditions, map[string]interface{}{"type": conditionType, "status": status, "reason": conditionType + status, "message": conditionType + " message"})
+		}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+		"kind":       "Kustomization",
+		"metadata":   map[string]interface{}{"name": "infra", "namespace": "flux-system"},
+		"status": map[string]interface{}{
+			"lastAppliedRevision":   revision,
+			"lastAttemptedRevision": "attempted",
+			"conditions":            conditions,
+		},
+	}}
+}
+
+// transitionNames returns the names of transitions
+func transitionNames(transitions []GitOpsTransition) (names []string) {
+	for _, transition := range transitions {
+		names = append(names, transition.Transition)
+	}
+	return names
+}
+
+// TestArgoCDTransitions tests the sync and health transitions of Argo CD Applications
+func TestArgoCDTransitions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		oldApp   *unstructured.Unstructured
+		newApp   *unstructured.Unstructured
+		expected []string
+	}{
+		{"sync started", getTestApplication("Succeeded", "t1", "OutOfSync", "Healthy", "a"), getTestApplication("Running", "t2", "OutOfSync", "Healthy", "a"), []string{GitOpsTransitionSyncStarted}},
+		{"synced", getTestApplication("Running", "t2", "OutOfSync", "Progressing", "a"), getTestApplication("Succeeded", "t2", "Synced", "Progressing", "b"), []string{GitOpsTransitionSynced}},
+		{"out of sync", getTestApplication("Succeeded", "t2", "Synced", "Healthy", "b"), getTestApplication("Succeeded", "t2", "OutOfSync", "Healthy", "b"), []string{GitOpsTransitionOutOfSync}},
+		{"failed and degraded", getTestApplication("Running", "t3", "OutOfSync", "Healthy", "b"), getTestApplication("Failed", "t3", "OutOfSync", "Degraded", "b"), []string{GitOpsTransitionReconciliationFailed, GitOpsTransitionDegraded}},
+		{"unchanged", getTestApplication("Succeeded", "t2", "Synced", "Healthy", "b"), getTestApplication("Succeeded", "t2", "Synced", "Healthy", "b"), nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transitions := GitOpsTransitions(testCase.oldApp, testCase.newApp)
+			if names := transitionNames(transitions); !reflect.DeepEqual(names, testCase.expected) {
+				t.Errorf("Expected transitions %v, got %v", testCase.expected, names)
+			}
+		})
+	}
+
+	transitions := GitOpsTransitions(getTestApplication("Succeeded", "t1", "OutOfSync", "Healthy", "a"), getTestApplication("Running", "t2", "OutOfSync", "Healthy", "a"))
+	if transitions[0].Revision != "target" || transitions[0].PreviousRevision != "a" || transitions[0].SyncStartedAt != "t2" || transitions[0].Tool != GitOpsToolArgoCD {
+		t.Errorf("Expected the sync to start at the operation revision, got %+v", transitions[0])
+	}
+}
+
+// TestFluxTransitions tests the reconciliation transitions of Flux objects
+func TestFluxTransitions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		oldObj   *unstructured.Unstructured
+		newObj   *unstructured.Unstructured
+		expected []string
+	}{
+		{"reconciling", getTestKustomization("True", "", "True", "a"), getTestKustomization("Unknown", "True", "True", "a"), []string{GitOpsTransitionSyncStarted}},
+		{"synced", getTestKustomization("Unknown", "True", "True", "a"), getTestKustomization("True", "", "True", "b"), []string{GitOpsTransitionSynced}},
+		{"failed", getTestKustomization("Unknown", "True", "True", "a"), getTestKustomization("False", "", "False", "a"), []string{GitOpsTransitionDegraded, GitOpsTransitionReconciliationFailed}},
+		{"unchanged", getTestKustomization("True", "", "True", "b"), getTestKustomization("True", "", "True", "b"), nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transitions := GitOpsTransitions(testCase.oldObj, testCase.newObj)
+			if names := transitionNames(transitions); !reflect.DeepEqual(names, testCase.expected) {
+				t.Errorf("Expected transitions %v, got %v", testCase.expected, names)
+			}
+		})
+	}
+
+	gitRepository := func(revision string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "source.toolkit.fluxcd.io/v1",
+			"kind":       "GitRepository",
+			"metadata":   map[string]interface{}{"name": "fleet", "namespace": "flux-system"},
+			"status":     map[string]interface{}{"artifact": map[string]interface{}{"revision": revision}},
+		}}
+	}
+	transitions := GitOpsTransitions(gitRepository("main@sha1:a"), gitRepository("main@sha1:b"))
+	if len(transitions) != 1 || transitions[0].Transition != GitOpsTransitionOutOfSync || transitions[0].Revision != "main@sha1:b" {
+		t.Errorf("Expected a new source revision to be out of sync, got %+v", transitions)
+	}
+
+	// Other kinds have no GitOps transitions
+	testDeployment := GetTestDeployment()
+	deployment := &unstructured.Unstructured{Object: toObjectMap(t, &testDeployment)}
+	if transitions = GitOpsTransitions(deployment, deployment); transitions != nil {
+		t.Errorf("Expected no transitions, got %+v", transitions)
+	}
+}
+
+// TestGitOpsTransitionsForgetMetadata tests that updates of Applications and Kustomizations remove only their own
+// cached metadata
+func TestGitOpsTransitionsForgetMetadata(t *testing.T) {
+	resetGitOpsCache(t)
+	application := gitOpsOwner{tool: GitOpsToolArgoCD, kind: "Application", name: "web", namespace: "argocd"}
+	kustomization := gitOpsOwner{tool: GitOpsToolFlux, kind: "Kustomization", name: "web", namespace: "argocd"}
+	cacheGitOpsMetadata(application, &GitOpsMetadata{Name: "web"})
+	cacheGitOpsMetadata(kustomization, &GitOpsMetadata{Name: "web"})
+
+	GitOpsTransitions(getTestApplication("Succeeded", "t1", "Synced", "Healthy", "a"), getTestApplication("Succeeded", "t1", "Synced", "Healthy", "a"))
+	if _, ok := cachedGitOpsMetadata(application); ok {
+		t.Error("Expected the metadata of the updated Application to be removed")
+	}
+	if _, ok := cachedGitOpsMetadata(kustomization); !ok {
+		t.Error("Expected the metadata of the Kustomization to be kept")
+	}
+	if gitOpsCache.Len() != 1 || len(gitOpsCacheIndex) != 1 {
+		t.Errorf("Expected 1 cached owner, got %d", gitOpsCache.Len())
+	}
+}
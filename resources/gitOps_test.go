@@ -0,0 +1,192 @@
+package resources
+
+import (
+	"container/list"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+	"main.go/common"
+)
+
+// getTestGitOpsObjects returns an Argo CD Application, a Flux Kustomization and its GitRepository
+func getTestGitOpsObjects() []runtime.Object {
+	application := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "argocd"},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{"repoURL": "https://github.com/example/apps.git", "path": "web"},
+		},
+		"status": map[string]interface{}{
+			"sync":   map[string]interface{}{"status": "Synced", "revision": "abc123"},
+			"health": map[string]interface{}{"status": "Healthy"},
+		},
+	}}
+	kustomization := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1beta2",
+		"kind":       "Kustomization",
+		"metadata":   map[string]interface{}{"name": "infra", "namespace": "flux-system"},
+		"spec": map[string]interface{}{
+			"path":      "./infra",
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "fleet"},
+		},
+		"status": map[string]interface{}{
+			"lastAppliedRevision": "main@sha1:def456",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True", "reason": "ReconciliationSucceeded"},
+			},
+		},
+	}}
+	gitRepository := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "source.toolkit.fluxcd.io/v1",
+		"kind":       "GitRepository",
+		"metadata":   map[string]interface{}{"name": "fleet", "namespace": "flux-system"},
+		"spec":       map[string]interface{}{"url": "https://github.com/example/fleet.git"},
+	}}
+	return []runtime.Object{application, kustomization, gitRepository}
+}
+
+// resetGitOpsCache clears the cached GitOps objects during a test
+func resetGitOpsCache(t *testing.T) {
+	reset := func() {
+		gitOpsCacheMux.Lock()
+		defer gitOpsCacheMux.Unlock()
+		gitOpsCache = list.New()
+		gitOpsCacheIndex = map[gitOpsOwner]*list.Element{}
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// watchTestObjects records informer caches with the given objects during a test, for the resources of their kinds
+func watchTestObjects(t *testing.T, objects ...runtime.Object) {
+	for _, object := range objects {
+		obj := object.(*unstructured.Unstructured)
+		gvr := obj.GroupVersionKind().GroupVersion().WithResource(common.KindToResource(obj.GetKind()))
+		informerStoresMux.RLock()
+		store, ok := informerStores[gvr]
+		informerStoresMux.RUnlock()
+		if !ok {
+			store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+			registerInformerStore(gvr, store)
+		}
+		store.Add(obj)
+	}
+	t.Cleanup(func() {
+		informerStoresMux.Lock()
+		defer informerStoresMux.Unlock()
+		informerStores = map[schema.GroupVersionResource]cache.Store{}
+	})
+}
+
+// getTestGitOpsManagedObject returns a deployment with the given labels and annotations
+func getTestGitOpsManagedObject(labels, annotations map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"name": "web", "namespace": "default", "labels": labels, "annotations": annotations},
+	}
+}
+
+// TestResourceGitOpsMetadata tests looking up the GitOps objects that applied resources
+func TestResourceGitOpsMetadata(t *testing.T) {
+	// Owners are read from the informer caches, and the GitRepository source with the client
+	objects := getTestGitOpsObjects()
+	client := fakeDynamic.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	watchTestObjects(t, objects[0], objects[1])
+	resetGitOpsCache(t)
+
+	argoObject := getTestGitOpsManagedObject(nil, map[string]interface{}{argoCDTrackingIDAnnotation: "web:apps/Deployment:default/web"})
+	metadata := ResourceGitOpsMetadata(client, argoObject)
+	expected := GitOpsMetadata{Tool: GitOpsToolArgoCD, Kind: "Application", Name: "web", Namespace: "argocd", SourceRepo: "https://github.com/example/apps.git", SourcePath: "web", Revision: "abc123", SyncStatus: "Synced", HealthStatus: "Healthy"}
+	if metadata == nil || *metadata != expected {
+		t.Errorf("Expected Argo CD metadata %+v, got %+v", expected, metadata)
+	}
+
+	fluxObject := getTestGitOpsManagedObject(map[string]interface{}{fluxKustomizationName: "infra", fluxKustomizationNamespace: "flux-system"}, nil)
+	metadata = ResourceGitOpsMetadata(client, fluxObject)
+	expected = GitOpsMetadata{Tool: GitOpsToolFlux, Kind: "Kustomization", Name: "infra", Namespace: "flux-system", SourceRepo: "https://github.com/example/fleet.git", SourcePath: "./infra", Revision: "main@sha1:def456", SyncStatus: "ReconciliationSucceeded"}
+	if metadata == nil || *metadata != expected {
+		t.Errorf("Expected Flux metadata %+v, got %+v", expected, metadata)
+	}
+
+	// Helm charts set the instance label too, so it is only reported for existing Applications
+	helmObject := getTestGitOpsManagedObject(map[string]interface{}{"app.kubernetes.io/instance": "api"}, nil)
+	if metadata = ResourceGitOpsMetadata(client, helmObject); metadata != nil {
+		t.Errorf("Expected no metadata for a missing Application, got %+v", metadata)
+	}
+	if metadata = ResourceGitOpsMetadata(client, getTestGitOpsManagedObject(nil, nil)); metadata != nil {
+		t.Errorf("Expected no metadata for an unmanaged object, got %+v", metadata)
+	}
+	if metadata = ResourceGitOpsMetadata(nil, argoObject); metadata != nil {
+		t.Errorf("Expected no metadata without a client, got %+v", metadata)
+	}
+}
+
+// TestResourceGitOpsMetadataUnwatched tests that owners whose resource isn't watched aren't looked up with the client
+func TestResourceGitOpsMetadataUnwatched(t *testing.T) {
+	client := fakeDynamic.NewSimpleDynamicClient(runtime.NewScheme(), getTestGitOpsObjects()...)
+	resetGitOpsCache(t)
+	argoObject := getTestGitOpsManagedObject(nil, map[string]interface{}{argoCDTrackingIDAnnotation: "web:apps/Deployment:default/web"})
+	if metadata := ResourceGitOpsMetadata(client, argoObject); metadata != nil {
+		t.Errorf("Expected no metadata without an Application informer, got %+v", metadata)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("Expected no API requests, got %v", actions)
+	}
+}
+
+// TestGitOpsCacheSize tests that the least recently used owners are evicted from the cache over its size
+func TestGitOpsCacheSize(t *testing.T) {
+	resetGitOpsCache(t)
+	defer func() { common.Config.GitOps.CacheSize = 0 }()
+	common.Config.GitOps.CacheSize = 2
+
+	owner := func(name string) gitOpsOwner {
+		return gitOpsOwner{tool: GitOpsToolArgoCD, kind: "Application", name: name, namespace: "argocd"}
+	}
+	cacheGitOpsMetadata(owner("web"), &GitOpsMetadata{Name: "web"})
+	cacheGitOpsMetadata(owner("api"), nil)
+	// Using web makes api the least recently used owner
+	if metadata, ok := cachedGitOpsMetadata(owner("web")); !ok || metadata.Name != "web" {
+		t.Errorf("Expected web to be cached, got %+v", metadata)
+	}
+	cacheGitOpsMetadata(owner("worker"), nil)
+
+	if _, ok := cachedGitOpsMetadata(owner("api")); ok {
+		t.Error("Expected the least recently used owner to be evicted")
+	}
+	for _, name := range []string{"web", "worker"} {
+		if _, ok := cachedGitOpsMetadata(owner(name)); !ok {
+			t.Errorf("Expected %s to be cached", name)
+		}
+	}
+	if gitOpsCache.Len() != 2 || len(gitOpsCacheIndex) != 2 {
+		t.Errorf("Expected 2 cached owners, got %d", gitOpsCache.Len())
+	}
+}
+
+// TestResourceGitOpsOwner tests recognizing the Argo CD and Flux tracking labels and annotations
+func TestResourceGitOpsOwner(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labels      map[string]interface{}
+		annotations map[string]interface{}
+		expected    gitOpsOwner
+	}{
+		{"argocd tracking id", nil, map[string]interface{}{argoCDTrackingIDAnnotation: "team-a_web:apps/Deployment:default/web"}, gitOpsOwner{GitOpsToolArgoCD, "Application", "web", "team-a"}},
+		{"argocd instance label", map[string]interface{}{"app.kubernetes.io/instance": "web"}, nil, gitOpsOwner{GitOpsToolArgoCD, "Application", "web", "argocd"}},
+		{"flux helm release", map[string]interface{}{fluxHelmReleaseName: "web", fluxHelmReleaseNamespace: "apps", "app.kubernetes.io/instance": "web"}, nil, gitOpsOwner{GitOpsToolFlux, "HelmRelease", "web", "apps"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			owner, ok := resourceGitOpsOwner(getTestGitOpsManagedObject(testCase.labels, testCase.annotations))
+			if !ok || owner != testCase.expected {
+				t.Errorf("Expected owner %+v, got %+v", testCase.expected, owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package resources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+	"sigs.k8s.io/yaml"
+)
+
+// maxHelmReleaseBytes limits the decompressed size of a Helm release
+const maxHelmReleaseBytes = 64 << 20
+
+// Helm release statuses, as stored in the release Secrets
+const (
+	HelmStatusDeployed        = "deployed"
+	HelmStatusFailed          = "failed"
+	HelmStatusSuperseded      = "superseded"
+	HelmStatusUninstalling    = "uninstalling"
+	HelmStatusUninstalled     = "uninstalled"
+	HelmStatusPendingInstall  = "pending-install"
+	HelmStatusPendingUpgrade  = "pending-upgrade"
+	HelmStatusPendingRollback = "pending-rollback"
+)
+
+// Helm release actions reported in HELM_RELEASE events
+const (
+	HelmActionInstall   = "install"
+	HelmActionUpgrade   = "upgrade"
+	HelmActionRollback  = "rollback"
+	HelmActionUninstall = "uninstall"
+)
+
+// helmRelease is the part of a Helm release used in events. Chart values and user supplied values
+// are intentionally not decoded, since they might contain secrets.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		FirstDeployed string `json:"first_deployed,omitempty"`
+		LastDeployed  string `json:"last_deployed,omitempty"`
+		Description   string `json:"description,omitempty"`
+		Status        string `json:"status,omitempty"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name,omitempty"`
+			Version    string `json:"version,omitempty"`
+			AppVersion string `json:"appVersion,omitempty"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// HelmReleaseObject identifies an object of a Helm release manifest
+type HelmReleaseObject struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// HelmReleaseRef identifies the Helm release revision that manages an object
+type HelmReleaseRef struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Revision     int    `json:"revision,omitempty"`
+	Chart        string `json:"chart,omitempty"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+}
+
+// HelmReleaseEvent describes a Helm release action
+type HelmReleaseEvent struct {
+	HelmReleaseRef
+	Action        string              `json:"action"`
+	AppVersion    string              `json:"appVersion,omitempty"`
+	Status        string              `json:"status"`
+	Description   string              `json:"description,omitempty"`
+	FirstDeployed string              `json:"firstDeployed,omitempty"`
+	LastDeployed  string              `json:"lastDeployed,omitempty"`
+	Objects       []HelmReleaseObject `json:"objects,omitempty"`
+}
+
+// helmReleaseIndex maps the objects of the deployed Helm releases to their releases
+var helmReleaseIndex = map[HelmReleaseObject]HelmReleaseRef{}
+
+// helmReleaseIndexMux guards helmReleaseIndex
+var helmReleaseIndexMux sync.RWMutex
+
+// isHelmReleaseSecret checks whether a Secret stores a Helm release
+func isHelmReleaseSecret(obj map[string]interface{}) bool {
+	secretType, _ := obj["type"].(string)
+	return obj["kind"] == "Secret" && secretType == common.HelmReleaseSecretType
+}
+
+// decodeHelmRelease decodes the Helm release stored in a release Secret.
+// The Secret data holds the base64 encoding of the gzip compressed release JSON.
+func decodeHelmRelease(secretObj map[string]interface{}) (release *helmRelease, err error) {
+	releaseData, found, err := unstructured.NestedString(secretObj, "data", "release")
+	if err != nil || !found {
+		return nil, fmt.Errorf("release data not found in Secret: %v", err)
+	}
+	// Secret data values are base64 encoded, on top of the Helm encoding
+	helmData, err := base64.StdEncoding.DecodeString(releaseData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Secret data: %w", err)
+	}
+	releaseBytes, err := base64.StdEncoding.DecodeString(string(helmData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode release data: %w", err)
+	}
+
+	// Releases are gzip compressed by default
+	if bytes.HasPrefix(releaseBytes, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(releaseBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+		defer gzipReader.Close()
+		releaseBytes, err = io.ReadAll(io.LimitReader(gzipReader, maxHelmReleaseBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+	}
+
+	release = &helmRelease{}
+	if err = json.Unmarshal(releaseBytes, release); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal release: %w", err)
+	}
+	return release, nil
+}
+
+// manifestObjects returns the objects of a Helm release manifest, without their contents
+func manifestObjects(manifest string, releaseNamespace string) (objects []HelmReleaseObject) {
+	for _, document := range strings.Split("\n"+manifest, "\n---") {
+		var object struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(document), &object); err != nil || object.Kind == "" || object.Metadata.Name == "" {
+			continue
+		}
+		namespace := object.Metadata.Namespace
+		if namespace == "" && !isClusterScopedKind(object.Kind) {
+			namespace = releaseNamespace
+		}
+		objects = append(objects, HelmReleaseObject{APIVersion: object.APIVersion, Kind: object.Kind, Name: object.Metadata.Name, Namespace: namespace})
+	}
+	return objects
+}
+
+// isClusterScopedKind checks whether a kind of a release manifest is cluster scoped
+func isClusterScopedKind(kind string) bool {
+	switch kind {
+	case "ClusterRole", "ClusterRoleBinding", "Namespace", "CustomResourceDefinition", "PersistentVolume", "StorageClass",
+		"PriorityClass", "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration", "APIService", "IngressClass":
+		return true
+	}
+	return false
+}
+
+// ref returns the reference of a decoded release
+func (release *helmRelease) ref() HelmReleaseRef {
+	return HelmReleaseRef{
+		Name:         release.Name,
+		Namespace:    release.Namespace,
+		Revision:     release.Version,
+		Chart:        release.Chart.Metadata.Name,
+		ChartVersion: release.Chart.Metadata.Version,
+	}
+}
+
+// helmReleaseAction returns the action that a release Secret change completed, if any.
+// Helm first stores releases with a pending status and then updates them to their final status,
+// while previous revisions are updated to superseded. Only added, modified and deleted Secrets complete actions.
+func helmReleaseAction(eventType string, previousStatus string, release *helmRelease) (action string, ok bool) {
+	status := release.Info.Status
+	switch eventType {
+	case common.EventTypeAdded:
+	case common.EventTypeDeleted:
+		// Uninstalling marks the current revision as uninstalling and then deletes all the revisions,
+		// so the uninstall is reported for the current revision only
+		if status == HelmStatusUninstalling {
+			return HelmActionUninstall, true
+		}
+		return "", false
+	case common.EventTypeModified:
+		if status == previousStatus {
+			return "", false
+		}
+		if status == HelmStatusUninstalled {
+			return HelmActionUninstall, true
+		}
+	default:
+		return "", false
+	}
+	if status != HelmStatusDeployed && status != HelmStatusFailed {
+		return "", false
+	}
+
+	switch {
+	case previousStatus == HelmStatusPendingInstall:
+		return HelmActionInstall, true
+	case previousStatus == HelmStatusPendingUpgrade:
+		return HelmActionUpgrade, true
+	case previousStatus == HelmStatusPendingRollback:
+		return HelmActionRollback, true
+	case strings.HasPrefix(release.Info.Description, "Rollback"):
+		return HelmActionRollback, true
+	case release.Version <= 1:
+		return HelmActionInstall, true
+	}
+	return HelmActionUpgrade, true
+}
+
+// HelmReleaseChange returns the Helm release event of a release Secret change, and updates the release objects index.
+// Events are only returned for completed install, upgrade, rollback and uninstall actions.
+func HelmReleaseChange(eventType string, oldObject, newObject map[string]interface{}) (releaseEvent *HelmReleaseEvent) {
+	if !isHelmReleaseSecret(newObject) {
+		return nil
+	}
+	release, err := decodeHelmRelease(newObject)
+	if err != nil {
+		log.Printf("[ERROR] Failed to decode Helm release Secret.\nERROR:\n%v", err)
+		return nil
+	}
+
+	previousStatus := ""
+	if eventType == common.EventTypeModified {
+		// The status label is cheaper to read than decoding the previous release
+		previousStatus, _, _ = unstructured.NestedString(oldObject, common.Metadata, common.Labels, "status")
+	}
+	action, ok := helmReleaseAction(eventType, previousStatus, release)
+	if !ok {
+		return nil
+	}
+
+	objects := manifestObjects(release.Manifest, release.Namespace)
+	if action == HelmActionUninstall {
+		unindexHelmRelease(release.Name, release.Namespace)
+	} else if release.Info.Status == HelmStatusDeployed {
+		indexHelmRelease(release.ref(), objects)
+	}
+
+	return &HelmReleaseEvent{
+		HelmReleaseRef: release.ref(),
+		Action:         action,
+		AppVersion:     release.Chart.Metadata.AppVersion,
+		Status:         release.Info.Status,
+		Description:    release.Info.Description,
+		FirstDeployed:  release.Info.FirstDeployed,
+		LastDeployed:   release.Info.LastDeployed,
+		Objects:        objects,
+	}
+}
+
+// IndexHelmReleaseSecret indexes the objects of an existing deployed release, so the events of those objects
+// are linked to their release from startup
+func IndexHelmReleaseSecret(obj interface{}) {
+	unst, ok := obj.(*unstructured.Unstructured)
+	if !ok || !isHelmReleaseSecret(unst.Object) {
+		return
+	}
+	if status, _, _ := unstructured.NestedString(unst.Object, common.Metadata, common.Labels, "status"); status != HelmStatusDeployed {
+		return
+	}
+	release, err := decodeHelmRelease(unst.Object)
+	if err != nil {
+		log.Printf("[ERROR] Failed to decode Helm release Secret.\nERROR:\n%v", err)
+		return
+	}
+	indexHelmRelease(release.ref(), manifestObjects(release.Manifest, release.Namespace))
+}
+
+// indexHelmRelease replaces the indexed objects of a release with the objects of its deployed revision
+func indexHelmRelease(ref HelmReleaseRef, objects []HelmReleaseObject) {
+	helmReleaseIndexMux.Lock()
+	defer helmReleaseIndexMux.Unlock()
+	for object, indexedRef := range helmReleaseIndex {
+		if indexedRef.Name == ref.Name && indexedRef.Namespace == ref.Namespace {
+			delete(helmReleaseIndex, object)
+		}
+	}
+	for _, object := range objects {
+		// The API version isn't part of the index key, since events may use another version of the same kind
+		object.APIVersion = ""
+		helmReleaseIndex[object] = ref
+	}
+}
+
+// unindexHelmRelease removes the objects of an uninstalled release from the index
+func unindexHelmRelease(name, namespace string) {
+	helmReleaseIndexMux.Lock()
+	defer helmReleaseIndexMux.Unlock()
+	for object, indexedRef := range helmReleaseIndex {
+		if indexedRef.Name == name && indexedRef.Namespace == namespace {
+			delete(helmReleaseIndex, object)
+		}
+	}
+}
+
+// ObjectHelmRelease returns the Helm release that manages an object. Objects that are not indexed yet
+// are matched by the Helm ownership annotations, without a revision.
+func ObjectHelmRelease(kind string, obj map[string]interface{}) (ref HelmReleaseRef, ok bool) {
+	name, _, _ := unstructured.NestedString(obj, common.Metadata, "name")
+	namespace, _, _ := unstructured.NestedString(obj, common.Metadata, "namespace")
+	helmReleaseIndexMux.RLock()
+	ref, ok = helmReleaseIndex[HelmReleaseObject{Kind: kind, Name: name, Namespace: namespace}]
+	helmReleaseIndexMux.RUnlock()
+	if ok {
+		return ref, true
+	}
+
+	annotations, _, _ := unstructured.NestedStringMap(obj, common.Metadata, common.Annotations)
+	if annotations["meta.helm.sh/release-name"] != "" {
+		return HelmReleaseRef{Name: annotations["meta.helm.sh/release-name"], Namespace: annotations["meta.helm.sh/release-namespace"]}, true
+	}
+	return ref, false
+}
+
+// sendHelmReleaseLog sends the event of a Helm release action
+func sendHelmReleaseLog(releaseEvent *HelmReleaseEvent) {
+	msg := common.ParseHelmReleaseMessage(releaseEvent.Action, releaseEvent.Name, releaseEvent.Namespace, releaseEvent.Chart, releaseEvent.ChartVersion, strconv.Itoa(releaseEvent.Revision), releaseEvent.Status)
+	var parsedEvent map[string]interface{}
+	jsonString, _ := json.Marshal(map[string]interface{}{
+		"eventType":   common.EventTypeHelmRelease,
+		"helmRelease": releaseEvent,
+	})
+	if err := json.Unmarshal(jsonString, &parsedEvent); err != nil {
+		log.Printf("[ERROR] Failed to parse Helm release event.\nERROR:\n%v", err)
+		return
+	}
+	common.SendLog(msg, parsedEvent)
+}
@@ -0,0 +1,183 @@
+package resources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// testHelmManifest is the manifest of the test Helm release
+const testHelmManifest = `---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+# Source: web/templates/clusterrole.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web-reader
+`
+
+// getTestHelmReleaseSecret returns a Helm release Secret, encoded like Helm stores it
+func getTestHelmReleaseSecret(revision int, status string, description string) map[string]interface{} {
+	release := map[string]interface{}{
+		"name":      "web",
+		"namespace": "default",
+		"version":   revision,
+		"info":      map[string]interface{}{"status": status, "description": description, "last_deployed": "2024-01-01T00:00:00Z"},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "version": "1.2.3", "appVersion": "2.0.0"},
+			"values":   map[string]interface{}{"password": "chart-default-password"},
+		},
+		"config":   map[string]interface{}{"password": "user-password"},
+		"manifest": testHelmManifest,
+	}
+	releaseJSON, _ := json.Marshal(release)
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	gzipWriter.Write(releaseJSON)
+	gzipWriter.Close()
+	helmData := base64.StdEncoding.EncodeToString(compressed.Bytes())
+
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       common.HelmReleaseSecretType,
+		"metadata": map[string]interface{}{
+			"name":      "sh.helm.release.v1.web.v1",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"owner": "helm", "name": "web", "status": status},
+		},
+		"data": map[string]interface{}{"release": base64.StdEncoding.EncodeToString([]byte(helmData))},
+	}
+}
+
+// TestHelmReleaseChange tests the Helm release actions completed by release Secret changes
+func TestHelmReleaseChange(t *testing.T) {
+	t.Cleanup(func() { unindexHelmRelease("web", "default") })
+	testCases := []struct {
+		name           string
+		eventType      string
+		previousStatus string
+		revision       int
+		status         string
+		description    string
+		expectedAction string
+	}{
+		{"install pending", common.EventTypeAdded, "", 1, HelmStatusPendingInstall, "Initial install underway", ""},
+		{"install", common.EventTypeModified, HelmStatusPendingInstall, 1, HelmStatusDeployed, "Install complete", HelmActionInstall},
+		{"upgrade", common.EventTypeModified, HelmStatusPendingUpgrade, 2, HelmStatusDeployed, "Upgrade complete", HelmActionUpgrade},
+		{"failed upgrade", common.EventTypeModified, HelmStatusPendingUpgrade, 3, HelmStatusFailed, "Upgrade failed", HelmActionUpgrade},
+		{"superseded", common.EventTypeModified, HelmStatusDeployed, 2, HelmStatusSuperseded, "Upgrade complete", ""},
+		{"rollback", common.EventTypeModified, HelmStatusPendingRollback, 4, HelmStatusDeployed, "Rollback to 2", HelmActionRollback},
+		{"uninstall with history", common.EventTypeModified, HelmStatusUninstalling, 4, HelmStatusUninstalled, "Uninstallation complete", HelmActionUninstall},
+		{"uninstall", common.EventTypeDeleted, "", 4, HelmStatusUninstalling, "Deletion in progress", HelmActionUninstall},
+		{"history pruning", common.EventTypeDeleted, "", 1, HelmStatusSuperseded, "Install complete", ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			oldObject := getTestHelmReleaseSecret(testCase.revision, testCase.previousStatus, "")
+			newObject := getTestHelmReleaseSecret(testCase.revision, testCase.status, testCase.description)
+			releaseEvent := HelmReleaseChange(testCase.eventType, oldObject, newObject)
+			if testCase.expectedAction == "" {
+				if releaseEvent != nil {
+					t.Errorf("Expected no release event, got %+v", releaseEvent)
+				}
+				return
+			}
+			if releaseEvent == nil || releaseEvent.Action != testCase.expectedAction {
+				t.Fatalf("Expected a %s release event, got %+v", testCase.expectedAction, releaseEvent)
+			}
+			if releaseEvent.Revision != testCase.revision || releaseEvent.Status != testCase.status || releaseEvent.Chart != "web" || releaseEvent.ChartVersion != "1.2.3" || releaseEvent.AppVersion != "2.0.0" {
+				t.Errorf("Unexpected release event: %+v", releaseEvent)
+			}
+			if len(releaseEvent.Objects) != 3 {
+				t.Errorf("Expected 3 manifest objects, got %v", releaseEvent.Objects)
+			}
+
+			// Release values are never part of the event
+			eventJSON, _ := json.Marshal(releaseEvent)
+			if strings.Contains(string(eventJSON), "password") {
+				t.Errorf("Expected no release values in the event, got %s", eventJSON)
+			}
+		})
+	}
+}
+
+// TestHelmReleaseChangeAttempts tests that attempted release Secret changes neither complete actions nor index the
+// release objects
+func TestHelmReleaseChangeAttempts(t *testing.T) {
+	t.Cleanup(func() { unindexHelmRelease("web", "default") })
+	for _, eventType := range []string{common.EventTypeRejected, common.EventTypeDryRun} {
+		oldObject := getTestHelmReleaseSecret(2, HelmStatusPendingUpgrade, "")
+		newObject := getTestHelmReleaseSecret(2, HelmStatusDeployed, "Upgrade complete")
+		if releaseEvent := HelmReleaseChange(eventType, oldObject, newObject); releaseEvent != nil {
+			t.Errorf("Expected no release event of a %s change, got %+v", eventType, releaseEvent)
+		}
+		deployment := map[string]interface{}{"metadata": map[string]interface{}{"name": "web", "namespace": "default"}}
+		if ref, ok := ObjectHelmRelease("Deployment", deployment); ok {
+			t.Errorf("Expected the release objects not to be indexed by a %s change, got %+v", eventType, ref)
+		}
+	}
+}
+
+// TestManifestObjects tests parsing the objects of a release manifest
+func TestManifestObjects(t *testing.T) {
+	objects := manifestObjects(testHelmManifest, "apps")
+	expected := []HelmReleaseObject{
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "web-config", Namespace: "apps"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "default"},
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "web-reader"},
+	}
+	if len(objects) != len(expected) {
+		t.Fatalf("Expected objects %v, got %v", expected, objects)
+	}
+	for index := range expected {
+		if objects[index] != expected[index] {
+			t.Errorf("Expected object %+v, got %+v", expected[index], objects[index])
+		}
+	}
+}
+
+// TestObjectHelmRelease tests linking objects to the deployed Helm releases
+func TestObjectHelmRelease(t *testing.T) {
+	t.Cleanup(func() { unindexHelmRelease("web", "default") })
+	IndexHelmReleaseSecret(&unstructured.Unstructured{Object: getTestHelmReleaseSecret(5, HelmStatusDeployed, "Upgrade complete")})
+
+	deployment := map[string]interface{}{"metadata": map[string]interface{}{"name": "web", "namespace": "default"}}
+	ref, ok := ObjectHelmRelease("Deployment", deployment)
+	if !ok || ref.Name != "web" || ref.Revision != 5 || ref.Chart != "web" {
+		t.Errorf("Expected the deployment to be linked to revision 5 of the web release, got %+v %v", ref, ok)
+	}
+
+	// Objects that are not indexed are linked by the Helm ownership annotations
+	annotated := map[string]interface{}{"metadata": map[string]interface{}{
+		"name":        "api",
+		"namespace":   "default",
+		"annotations": map[string]interface{}{"meta.helm.sh/release-name": "api", "meta.helm.sh/release-namespace": "default"},
+	}}
+	if ref, ok = ObjectHelmRelease("Deployment", annotated); !ok || ref.Name != "api" || ref.Revision != 0 {
+		t.Errorf("Expected the annotated deployment to be linked to the api release, got %+v %v", ref, ok)
+	}
+
+	unindexHelmRelease("web", "default")
+	if ref, ok = ObjectHelmRelease("Deployment", deployment); ok {
+		t.Errorf("Expected no release after uninstall, got %+v", ref)
+	}
+}
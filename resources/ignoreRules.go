@@ -0,0 +1,262 @@
+package resources
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"log"
+	"main.go/common"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ignoreRule is a compiled common.IgnoreRule
+type ignoreRule struct {
+	name        string
+	kinds       []string
+	paths       [][]pathSegment
+	annotations []string
+	labels      []string
+}
+
+// metadataPaths are the object metadata maps that annotation and label patterns are applied to
+var metadataPaths = [][]string{
+	{common.Metadata},
+	{"spec", "template", common.Metadata},
+}
+
+var ignoreRules []ignoreRule
+var ignoreRuleStats = map[string]int64{}
+var ignoreRuleStatsMux sync.Mutex
+
+// ConfigureIgnoreRules compiles the ignore rules from the collector configuration
+func ConfigureIgnoreRules() {
+	ignoreRules = compileIgnoreRules(common.Config.IgnoreRules)
+	if len(ignoreRules) > 0 {
+		log.Printf("Configured %d ignore rules for resource updates", len(ignoreRules))
+	}
+}
+
+// compileIgnoreRules parses the configured ignore rules, rules with invalid field paths or key patterns are skipped
+func compileIgnoreRules(rules []common.IgnoreRule) (compiledRules []ignoreRule) {
+	for ruleIndex, rule := range rules {
+		compiledRule := ignoreRule{name: rule.Name, kinds: rule.Kinds, annotations: rule.Annotations, labels: rule.Labels}
+		if compiledRule.name == "" {
+			compiledRule.name = fmt.Sprintf("rule-%d", ruleIndex)
+		}
+
+		isValid := true
+		for _, fieldPath := range rule.Paths {
+			segments, err := parseFieldPath(fieldPath)
+			if err != nil {
+				log.Printf("[ERROR] Invalid field path in ignore rule: %s.\nERROR:\n%v", compiledRule.name, err)
+				isValid = false
+				break
+			}
+			compiledRule.paths = append(compiledRule.paths, segments)
+		}
+		for _, pattern := range append(append([]string{}, rule.Annotations...), rule.Labels...) {
+			if _, err := globRegexp(pattern); err != nil {
+				log.Printf("[ERROR] Invalid key pattern: '%s' in ignore rule: %s.\nERROR:\n%v", pattern, compiledRule.name, err)
+				isValid = false
+				break
+			}
+		}
+
+		if isValid {
+			compiledRules = append(compiledRules, compiledRule)
+		}
+	}
+	return compiledRules
+}
+
+// appliesTo checks whether the rule applies to a resource kind
+func (rule ignoreRule) appliesTo(kind string) bool {
+	if len(rule.kinds) == 0 {
+		return true
+	}
+	for _, ruleKind := range rule.kinds {
+		if ruleKind == "*" || ruleKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// apply removes the ignored fields from both objects.
+// It returns true if any of the removed fields differed between the objects.
+func (rule ignoreRule) apply(oldObj, newObj *unstructured.Unstructured) (removedChanges bool) {
+	for _, segments := range rule.paths {
+		if !fieldPathValuesEqual(oldObj.Object, newObj.Object, segments) {
+			removedChanges = true
+		}
+		removeFieldPath(oldObj.Object, segments)
+		removeFieldPath(newObj.Object, segments)
+	}
+
+	for _, metadataPath := range metadataPaths {
+		if removeMatchingKeys(oldObj.Object, newObj.Object, append(metadataPath, common.Annotations), rule.annotations) {
+			removedChanges = true
+		}
+		if removeMatchingKeys(oldObj.Object, newObj.Object, append(metadataPath, common.Labels), rule.labels) {
+			removedChanges = true
+		}
+	}
+	return removedChanges
+}
+
+// removeMatchingKeys removes the keys matching any of the patterns from a string map in both objects.
+// It returns true if any of the removed keys differed between the objects.
+func removeMatchingKeys(oldObj, newObj map[string]interface{}, fields []string, patterns []string) (removedChanges bool) {
+	if len(patterns) == 0 {
+		return false
+	}
+	oldMap, _, _ := unstructured.NestedMap(oldObj, fields...)
+	newMap, _, _ := unstructured.NestedMap(newObj, fields...)
+
+	for _, obj := range []map[string]interface{}{oldObj, newObj} {
+		keysMap, found, _ := unstructured.NestedMap(obj, fields...)
+		if !found {
+			continue
+		}
+		for key := range keysMap {
+			if matchesAnyPattern(key, patterns) {
+				if !reflect.DeepEqual(oldMap[key], newMap[key]) {
+					removedChanges = true
+				}
+				delete(keysMap, key)
+			}
+		}
+		if len(keysMap) == 0 {
+			unstructured.RemoveNestedField(obj, fields...)
+		} else {
+			_ = unstructured.SetNestedMap(obj, keysMap, fields...)
+		}
+	}
+	return removedChanges
+}
+
+// globPatterns caches the regular expressions of glob patterns, invalid patterns are cached as nil
+var globPatterns = map[string]*regexp.Regexp{}
+var globPatternsMux sync.RWMutex
+
+// globRegexp converts a shell glob pattern to an anchored regular expression. Unlike path.Match, "*" also matches
+// "/", so "*restartedAt" matches "kubectl.kubernetes.io/restartedAt".
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, fmt.Errorf("trailing backslash in pattern: %s", pattern)
+			}
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := i + 1
+			if end < len(pattern) && (pattern[end] == '!' || pattern[end] == '^') {
+				end++
+			}
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			end += strings.IndexByte(pattern[end:], ']')
+			if end < i+1 {
+				return nil, fmt.Errorf("unterminated character class in pattern: %s", pattern)
+			}
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expression.WriteString("[" + class + "]")
+			i = end
+		default:
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.Compile(expression.String())
+}
+
+// matchesAnyPattern checks whether a key matches any of the glob patterns
+func matchesAnyPattern(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		globPatternsMux.RLock()
+		patternRegexp, ok := globPatterns[pattern]
+		globPatternsMux.RUnlock()
+		if !ok {
+			// Patterns are validated when they are configured
+			patternRegexp, _ = globRegexp(pattern)
+			globPatternsMux.Lock()
+			globPatterns[pattern] = patternRegexp
+			globPatternsMux.Unlock()
+		}
+		if patternRegexp != nil && patternRegexp.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyIgnoreRules applies the ignore rules of the object kind to both objects.
+// It returns the names of the rules that removed differing fields.
+func applyIgnoreRules(oldObj, newObj *unstructured.Unstructured) (appliedRules []string) {
+	kind := newObj.GetKind()
+	for _, rule := range ignoreRules {
+		if rule.appliesTo(kind) && rule.apply(oldObj, newObj) {
+			appliedRules = append(appliedRules, rule.name)
+		}
+	}
+	return appliedRules
+}
+
+// countIgnoreRuleSuppression counts an event suppressed by the given ignore rules
+func countIgnoreRuleSuppression(ruleNames []string) {
+	ignoreRuleStatsMux.Lock()
+	defer ignoreRuleStatsMux.Unlock()
+	for _, ruleName := range ruleNames {
+		ignoreRuleStats[ruleName]++
+	}
+}
+
+// IgnoreRuleStats returns the number of events suppressed by each ignore rule
+func IgnoreRuleStats() (stats map[string]int64) {
+	ignoreRuleStatsMux.Lock()
+	defer ignoreRuleStatsMux.Unlock()
+	stats = make(map[string]int64, len(ignoreRuleStats))
+	for ruleName, count := range ignoreRuleStats {
+		stats[ruleName] = count
+	}
+	return stats
+}
+
+// reportIgnoreRuleStats periodically sends the number of events suppressed by each ignore rule
+func reportIgnoreRuleStats(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastStats map[string]int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			stats := IgnoreRuleStats()
+			if len(stats) == 0 || reflect.DeepEqual(stats, lastStats) {
+				continue
+			}
+			lastStats = stats
+			statsFields := map[string]interface{}{}
+			for ruleName, count := range stats {
+				statsFields[ruleName] = count
+			}
+			common.SendLog("[STATS] Resource updates suppressed by ignore rules.", map[string]interface{}{"ignoreRuleSuppressions": statsFields})
+		}
+	}
+}
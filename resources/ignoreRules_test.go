@@ -0,0 +1,116 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+	"testing"
+)
+
+// getTestRestartedDeployments returns a deployment before and after a rollout restart and a cert-manager annotation update
+func getTestRestartedDeployments() (oldObj, newObj *unstructured.Unstructured) {
+	oldObj = &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind": "Deployment",
+			common.Metadata: map[string]interface{}{
+				"name": "test-deployment",
+				common.Annotations: map[string]interface{}{
+					"cert-manager.io/issued-at": "1",
+				},
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					common.Metadata: map[string]interface{}{
+						common.Labels: map[string]interface{}{"app": "nginx"},
+					},
+				},
+			},
+		},
+	}
+	newObj = oldObj.DeepCopy()
+	_ = unstructured.SetNestedField(newObj.Object, "2", common.Metadata, common.Annotations, "cert-manager.io/issued-at")
+	_ = unstructured.SetNestedField(newObj.Object, "2024-01-01T00:00:00Z", "spec", "template", common.Metadata, common.Annotations, "kubectl.kubernetes.io/restartedAt")
+	return oldObj, newObj
+}
+
+// TestCompileIgnoreRules tests that invalid ignore rules are skipped
+func TestCompileIgnoreRules(t *testing.T) {
+	rules := compileIgnoreRules([]common.IgnoreRule{
+		{Paths: []string{"spec.template"}},
+		{Name: "invalid-path", Paths: []string{"spec.containers["}},
+		{Name: "invalid-pattern", Annotations: []string{"[a-"}},
+	})
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 valid ignore rule, got %d", len(rules))
+	}
+	if rules[0].name != "rule-0" {
+		t.Errorf("Expected a default rule name of rule-0, got %s", rules[0].name)
+	}
+}
+
+// TestIgnoreRulesSuppression tests that updates are suppressed only when the ignore rules cover all the changes
+func TestIgnoreRulesSuppression(t *testing.T) {
+	defer func() { ignoreRules = nil }()
+	oldObj, newObj := getTestRestartedDeployments()
+
+	ignoreRules = compileIgnoreRules([]common.IgnoreRule{
+		{Name: "restarts", Kinds: []string{"Deployment"}, Paths: []string{"spec.template.metadata.annotations['kubectl.kubernetes.io/restartedAt']"}},
+	})
+	if IgnoreInternalChanges(oldObj, newObj) {
+		t.Errorf("Expected the update not to be ignored while the cert-manager annotation change is not covered")
+	}
+
+	ignoreRules = append(ignoreRules, compileIgnoreRules([]common.IgnoreRule{
+		{Name: "cert-manager", Annotations: []string{"cert-manager.io/*"}},
+		{Name: "statefulsets", Kinds: []string{"StatefulSet"}, Labels: []string{"*"}},
+	})...)
+	before := IgnoreRuleStats()
+	if !IgnoreInternalChanges(oldObj, newObj) {
+		t.Fatalf("Expected the update to be ignored")
+	}
+	after := IgnoreRuleStats()
+	if after["restarts"] != before["restarts"]+1 || after["cert-manager"] != before["cert-manager"]+1 {
+		t.Errorf("Expected the restarts and cert-manager rules to be counted, got %v", after)
+	}
+	if after["statefulsets"] != 0 {
+		t.Errorf("Expected the statefulsets rule not to be counted, got %v", after)
+	}
+
+	// The original objects must not be modified by the ignore rules
+	if _, found, _ := unstructured.NestedString(newObj.Object, "spec", "template", common.Metadata, common.Annotations, "kubectl.kubernetes.io/restartedAt"); !found {
+		t.Errorf("Expected the original object to keep the restartedAt annotation")
+	}
+}
+
+// TestMatchesAnyPattern tests matching keys with glob patterns, where "*" also matches the "/" of key prefixes
+func TestMatchesAnyPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{pattern: "*restartedAt", key: "kubectl.kubernetes.io/restartedAt", expected: true},
+		{pattern: "*", key: "argocd.argoproj.io/tracking-id", expected: true},
+		{pattern: "argocd.argoproj.io/*", key: "argocd.argoproj.io/tracking-id", expected: true},
+		{pattern: "argocd.argoproj.io/*", key: "example.com/argocd.argoproj.io", expected: false},
+		{pattern: "*restartedAt", key: "kubectl.kubernetes.io/restartedAt-by", expected: false},
+		{pattern: "app?", key: "apps", expected: true},
+		{pattern: "[!x]y", key: "xy", expected: false},
+		{pattern: "[!x]y", key: "ay", expected: true},
+		{pattern: "example.com/cost\\*", key: "example.com/cost*", expected: true},
+		{pattern: "example.com/cost\\*", key: "example.com/costs", expected: false},
+		{pattern: "[a-", key: "[a-", expected: false},
+	}
+	for _, test := range tests {
+		if matched := matchesAnyPattern(test.key, []string{test.pattern}); matched != test.expected {
+			t.Errorf("Expected pattern %s to match key %s: %t, got %t", test.pattern, test.key, test.expected, matched)
+		}
+	}
+
+	// Patterns without a prefix cover prefixed annotations of the pod template
+	defer func() { ignoreRules = nil }()
+	oldObj, newObj := getTestRestartedDeployments()
+	ignoreRules = compileIgnoreRules([]common.IgnoreRule{{Name: "restarts", Annotations: []string{"*restartedAt", "*issued-at"}}})
+	if !IgnoreInternalChanges(oldObj, newObj) {
+		t.Error("Expected the update to be ignored by patterns without a prefix")
+	}
+}
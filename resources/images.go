@@ -0,0 +1,147 @@
+package resources
+
+import (
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// Defaults of image references without a registry or tag
+const (
+	defaultImageRegistry  = "docker.io"
+	defaultImageNamespace = "library"
+	defaultImageTag       = "latest"
+)
+
+// ImageReference is a container image reference parsed into its parts
+type ImageReference struct {
+	Image      string `json:"image"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	// Mutable is set for references that aren't pinned to a digest and use the latest tag or no tag
+	Mutable bool `json:"mutable"`
+}
+
+// ImageChange is the image change of a container, an added container has no before image and a removed container has no after image
+type ImageChange struct {
+	Container string          `json:"container"`
+	Before    *ImageReference `json:"before,omitempty"`
+	After     *ImageReference `json:"after,omitempty"`
+}
+
+// podSpecFields are the fields of the pod spec of each kind with containers
+var podSpecFields = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"Rollout":     {"spec", "template", "spec"},
+}
+
+// ParseImageReference parses a container image reference such as "registry.example.com:5000/team/app:1.2@sha256:..."
+// into its registry, repository, tag and digest, filling in the Docker Hub defaults
+func ParseImageReference(image string) (ref ImageReference) {
+	ref.Image = image
+	remainder := image
+	if digestIndex := strings.Index(remainder, "@"); digestIndex >= 0 {
+		ref.Digest = remainder[digestIndex+1:]
+		remainder = remainder[:digestIndex]
+	}
+	// A colon after the last slash separates the tag, other colons are registry ports
+	if tagIndex := strings.LastIndex(remainder, ":"); tagIndex > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[tagIndex+1:]
+		remainder = remainder[:tagIndex]
+	}
+
+	// The first component is a registry if it looks like a host name
+	ref.Registry = defaultImageRegistry
+	if slashIndex := strings.Index(remainder, "/"); slashIndex >= 0 {
+		host := remainder[:slashIndex]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry = host
+			remainder = remainder[slashIndex+1:]
+		}
+	}
+	if ref.Registry == defaultImageRegistry && !strings.Contains(remainder, "/") {
+		remainder = defaultImageNamespace + "/" + remainder
+	}
+	ref.Repository = remainder
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultImageTag
+	}
+	ref.Mutable = ref.Digest == "" && ref.Tag == defaultImageTag
+	return ref
+}
+
+// containerImages returns the parsed image references of the containers and init containers of an object, by container name
+func containerImages(obj map[string]interface{}) (images map[string]ImageReference) {
+	if obj == nil {
+		return nil
+	}
+	kind, _, _ := unstructured.NestedString(obj, "kind")
+	podSpec, ok := podSpecFields[kind]
+	if !ok {
+		return nil
+	}
+	for _, containersField := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj, append(append([]string{}, podSpec...), containersField)...)
+		for _, containerI := range containers {
+			container, ok := containerI.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			if name == "" || image == "" {
+				continue
+			}
+			if images == nil {
+				images = map[string]ImageReference{}
+			}
+			images[name] = ParseImageReference(image)
+		}
+	}
+	return images
+}
+
+// ImageChanges returns the image references of the containers of a new object, the image changes of its containers
+// compared to the old object, and the names of the containers with mutable image references.
+// The images are keyed by container name, as arrays of objects are sent as strings.
+func ImageChanges(eventType string, oldObj, newObj map[string]interface{}) (images map[string]ImageReference, changes map[string]ImageChange, mutableImages []string) {
+	images = containerImages(newObj)
+	for name, image := range images {
+		if image.Mutable {
+			mutableImages = append(mutableImages, name)
+		}
+	}
+	slices.Sort(mutableImages)
+
+	if eventType != common.EventTypeModified {
+		return images, nil, mutableImages
+	}
+	oldImages := containerImages(oldObj)
+	addChange := func(name string, before, after *ImageReference) {
+		if changes == nil {
+			changes = map[string]ImageChange{}
+		}
+		changes[name] = ImageChange{Container: name, Before: before, After: after}
+	}
+	for name, image := range images {
+		if oldImage, ok := oldImages[name]; !ok {
+			addChange(name, nil, &image)
+		} else if oldImage.Image != image.Image {
+			addChange(name, &oldImage, &image)
+		}
+	}
+	for name, oldImage := range oldImages {
+		if _, ok := images[name]; !ok {
+			addChange(name, &oldImage, nil)
+		}
+	}
+	return images, changes, mutableImages
+}
@@ -0,0 +1,74 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"main.go/common"
+)
+
+// TestParseImageReference tests parsing image references into their parts
+func TestParseImageReference(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected ImageReference
+	}{
+		{"nginx", ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest", Mutable: true}},
+		{"nginx:1.27", ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"}},
+		{"bitnami/redis:latest", ImageReference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "latest", Mutable: true}},
+		{"ghcr.io/org/team/app:v2", ImageReference{Registry: "ghcr.io", Repository: "org/team/app", Tag: "v2"}},
+		{"registry.local:5000/app", ImageReference{Registry: "registry.local:5000", Repository: "app", Tag: "latest", Mutable: true}},
+		{"localhost/app:dev", ImageReference{Registry: "localhost", Repository: "app", Tag: "dev"}},
+		{"quay.io/app@sha256:abc", ImageReference{Registry: "quay.io", Repository: "app", Digest: "sha256:abc"}},
+		{"app:latest@sha256:abc", ImageReference{Registry: "docker.io", Repository: "library/app", Tag: "latest", Digest: "sha256:abc"}},
+	}
+	for _, testCase := range testCases {
+		testCase.expected.Image = testCase.image
+		if ref := ParseImageReference(testCase.image); ref != testCase.expected {
+			t.Errorf("Expected image %s to be parsed to %+v, got %+v", testCase.image, testCase.expected, ref)
+		}
+	}
+}
+
+// TestImageChanges tests the image changes of workload containers
+func TestImageChanges(t *testing.T) {
+	oldDeployment := GetTestDeployment()
+	oldDeployment.Spec.Template.Spec.Containers = append(oldDeployment.Spec.Template.Spec.Containers, oldDeployment.Spec.Template.Spec.Containers[0])
+	oldDeployment.Spec.Template.Spec.Containers[1].Name = "sidecar"
+	oldDeployment.Spec.Template.Spec.Containers[1].Image = "envoy:v1"
+	newDeployment := *oldDeployment.DeepCopy()
+	newDeployment.Spec.Template.Spec.Containers = newDeployment.Spec.Template.Spec.Containers[1:]
+	newDeployment.Spec.Template.Spec.Containers[0].Image = "envoy:v2"
+	newDeployment.Spec.Template.Spec.InitContainers = append(newDeployment.Spec.Template.Spec.InitContainers, oldDeployment.Spec.Template.Spec.Containers[0])
+	newDeployment.Spec.Template.Spec.InitContainers[0].Name = "migrate"
+	newDeployment.Spec.Template.Spec.InitContainers[0].Image = "migrate"
+
+	images, changes, mutableImages := ImageChanges(common.EventTypeModified, toObjectMap(t, &oldDeployment), toObjectMap(t, &newDeployment))
+	if len(images) != 2 || images["sidecar"].Tag != "v2" || images["migrate"].Repository != "library/migrate" {
+		t.Errorf("Unexpected images: %+v", images)
+	}
+	if !reflect.DeepEqual(mutableImages, []string{"migrate"}) {
+		t.Errorf("Expected the migrate image to be mutable, got %v", mutableImages)
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 image changes, got %+v", changes)
+	}
+	if change := changes["sidecar"]; change.Before.Tag != "v1" || change.After.Tag != "v2" || change.Container != "sidecar" {
+		t.Errorf("Unexpected sidecar image change: %+v", change)
+	}
+	if change := changes["container-nginx"]; change.Before == nil || change.After != nil {
+		t.Errorf("Expected the removed container to have only a before image, got %+v", change)
+	}
+	if change := changes["migrate"]; change.Before != nil || change.After == nil {
+		t.Errorf("Expected the added container to have only an after image, got %+v", change)
+	}
+
+	// Added resources have images but no image changes, other kinds have no images
+	if _, changes, _ = ImageChanges(common.EventTypeAdded, nil, toObjectMap(t, &newDeployment)); changes != nil {
+		t.Errorf("Expected no image changes of an added resource, got %+v", changes)
+	}
+	if images, _, _ = ImageChanges(common.EventTypeAdded, nil, map[string]interface{}{"kind": "ConfigMap"}); images != nil {
+		t.Errorf("Expected no images of a config map, got %+v", images)
+	}
+}
@@ -0,0 +1,218 @@
+package resources
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+)
+
+// Progressive delivery tools
+const (
+	DeliveryToolArgoRollouts = "argo-rollouts"
+	DeliveryToolFlagger      = "flagger"
+)
+
+// Progressive delivery transitions reported in PROGRESSIVE_DELIVERY events
+const (
+	DeliveryTransitionStepProgressed   = "step-progressed"
+	DeliveryTransitionWeightChanged    = "weight-changed"
+	DeliveryTransitionAnalysisFinished = "analysis-finished"
+	DeliveryTransitionPromoted         = "promoted"
+	DeliveryTransitionAborted          = "aborted"
+)
+
+// rolloutAnalysisRunStatuses are the status fields of the analysis runs of a Rollout
+var rolloutAnalysisRunStatuses = [][]string{
+	{"status", "canary", "currentStepAnalysisRunStatus"},
+	{"status", "canary", "currentBackgroundAnalysisRunStatus"},
+	{"status", "blueGreen", "prePromotionAnalysisRunStatus"},
+	{"status", "blueGreen", "postPromotionAnalysisRunStatus"},
+}
+
+// finishedAnalysisPhases are the phases of finished Argo Rollouts analysis runs
+var finishedAnalysisPhases = []string{"Successful", "Failed", "Error", "Inconclusive"}
+
+// DeliveryTransition describes a step, traffic weight, analysis, promotion or abort transition of an
+// Argo Rollouts Rollout or a Flagger Canary
+type DeliveryTransition struct {
+	Tool           string `json:"tool"`
+	Transition     string `json:"transition"`
+	Step           *int64 `json:"step,omitempty"`
+	TotalSteps     *int64 `json:"totalSteps,omitempty"`
+	Weight         *int64 `json:"weight,omitempty"`
+	PreviousWeight *int64 `json:"previousWeight,omitempty"`
+	Analysis       string `json:"analysis,omitempty"`
+	AnalysisStatus string `json:"analysisStatus,omitempty"`
+	Revision       string `json:"revision,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// detail returns the description of the step, weight or analysis of a transition, for its event message
+func (transition DeliveryTransition) detail() string {
+	switch transition.Transition {
+	case DeliveryTransitionStepProgressed:
+		if transition.Step != nil && transition.TotalSteps != nil {
+			return fmt.Sprintf("step: %d/%d", *transition.Step, *transition.TotalSteps)
+		} else if transition.Step != nil {
+			return fmt.Sprintf("step: %d", *transition.Step)
+		}
+	case DeliveryTransitionWeightChanged:
+		if transition.Weight != nil {
+			return fmt.Sprintf("weight: %d", *transition.Weight)
+		}
+	case DeliveryTransitionAnalysisFinished:
+		if transition.Analysis != "" {
+			return fmt.Sprintf("analysis: %s status: %s", transition.Analysis, transition.AnalysisStatus)
+		}
+		return "analysis status: " + transition.AnalysisStatus
+	}
+	return ""
+}
+
+// deliveryKinds are the kinds with progressive delivery transitions, by API group
+var deliveryKinds = map[string]string{
+	"argoproj.io": "Rollout",
+	"flagger.app": "Canary",
+}
+
+// isDeliveryKind checks whether an object is an Argo Rollouts Rollout or a Flagger Canary
+func isDeliveryKind(obj *unstructured.Unstructured) bool {
+	kind, ok := deliveryKinds[obj.GroupVersionKind().Group]
+	return ok && kind == obj.GetKind()
+}
+
+// nestedInt64 returns a nested integer field of an object, or nil if it isn't set
+func nestedInt64(obj *unstructured.Unstructured, fields ...string) *int64 {
+	value, found, err := unstructured.NestedInt64(obj.Object, fields...)
+	if !found || err != nil {
+		return nil
+	}
+	return &value
+}
+
+// isInt64Changed checks whether an integer field was set or changed
+func isInt64Changed(oldValue, newValue *int64) bool {
+	return newValue != nil && (oldValue == nil || *oldValue != *newValue)
+}
+
+// rolloutTransitions returns the step, weight, analysis, promotion and abort transitions of an Argo Rollouts Rollout
+func rolloutTransitions(oldRollout, newRollout *unstructured.Unstructured) (transitions []DeliveryTransition) {
+	revision := nestedString(newRollout, "status", "currentPodHash")
+	message := nestedString(newRollout, "status", "message")
+
+	newTransition := func(transition string) DeliveryTransition {
+		return DeliveryTransition{Tool: DeliveryToolArgoRollouts, Transition: transition, Revision: revision, Message: message}
+	}
+
+	if oldStep, step := nestedInt64(oldRollout, "status", "currentStepIndex"), nestedInt64(newRollout, "status", "currentStepIndex"); isInt64Changed(oldStep, step) {
+		transition := newTransition(DeliveryTransitionStepProgressed)
+		transition.Step = step
+		if steps, found, _ := unstructured.NestedSlice(newRollout.Object, "spec", "strategy", "canary", "steps"); found {
+			totalSteps := int64(len(steps))
+			transition.TotalSteps = &totalSteps
+		}
+		transitions = append(transitions, transition)
+	}
+
+	oldWeight, weight := nestedInt64(oldRollout, "status", "canary", "weights", "canary", "weight"), nestedInt64(newRollout, "status", "canary", "weights", "canary", "weight")
+	if isInt64Changed(oldWeight, weight) {
+		transition := newTransition(DeliveryTransitionWeightChanged)
+		transition.Weight, transition.PreviousWeight = weight, oldWeight
+		transitions = append(transitions, transition)
+	}
+
+	for _, fields := range rolloutAnalysisRunStatuses {
+		analysisName := nestedString(newRollout, append(fields, "name")...)
+		oldStatus, status := nestedString(oldRollout, append(fields, "status")...), nestedString(newRollout, append(fields, "status")...)
+		isNewRun := analysisName != nestedString(oldRollout, append(fields, "name")...)
+		if slices.Contains(finishedAnalysisPhases, status) && (status != oldStatus || isNewRun) {
+			transition := newTransition(DeliveryTransitionAnalysisFinished)
+			transition.Analysis, transition.AnalysisStatus = analysisName, status
+			if analysisMessage := nestedString(newRollout, append(fields, "message")...); analysisMessage != "" {
+				transition.Message = analysisMessage
+			}
+			transitions = append(transitions, transition)
+		}
+	}
+
+	// The new revision is promoted once it becomes the stable revision
+	oldStable, stable := nestedString(oldRollout, "status", "stableRS"), nestedString(newRollout, "status", "stableRS")
+	if oldStable != "" && stable != oldStable && stable == revision {
+		transitions = append(transitions, newTransition(DeliveryTransitionPromoted))
+	}
+
+	oldAborted, _, _ := unstructured.NestedBool(oldRollout.Object, "status", "abort")
+	if aborted, _, _ := unstructured.NestedBool(newRollout.Object, "status", "abort"); aborted && !oldAborted {
+		transitions = append(transitions, newTransition(DeliveryTransitionAborted))
+	}
+	return transitions
+}
+
+// canaryTransitions returns the step, weight, analysis, promotion and abort transitions of a Flagger Canary
+func canaryTransitions(oldCanary, newCanary *unstructured.Unstructured) (transitions []DeliveryTransition) {
+	oldPhase, phase := nestedString(oldCanary, "status", "phase"), nestedString(newCanary, "status", "phase")
+	revision := nestedString(newCanary, "status", "lastAppliedSpec")
+	message := conditionField(objectConditions(newCanary)["Promoted"], "message")
+
+	newTransition := func(transition string) DeliveryTransition {
+		return DeliveryTransition{Tool: DeliveryToolFlagger, Transition: transition, Revision: revision, Message: message}
+	}
+
+	// Iterations are the steps of A/B testing and Blue/Green analysis
+	if oldIterations, iterations := nestedInt64(oldCanary, "status", "iterations"), nestedInt64(newCanary, "status", "iterations"); isInt64Changed(oldIterations, iterations) && *iterations > 0 {
+		transition := newTransition(DeliveryTransitionStepProgressed)
+		transition.Step, transition.TotalSteps = iterations, nestedInt64(newCanary, "spec", "analysis", "iterations")
+		transitions = append(transitions, transition)
+	}
+
+	oldWeight, weight := nestedInt64(oldCanary, "status", "canaryWeight"), nestedInt64(newCanary, "status", "canaryWeight")
+	if isInt64Changed(oldWeight, weight) && oldWeight != nil {
+		transition := newTransition(DeliveryTransitionWeightChanged)
+		transition.Weight, transition.PreviousWeight = weight, oldWeight
+		transitions = append(transitions, transition)
+	}
+
+	// Flagger doesn't keep analysis results, only counts the failed metric checks
+	oldFailedChecks, failedChecks := nestedInt64(oldCanary, "status", "failedChecks"), nestedInt64(newCanary, "status", "failedChecks")
+	if failedChecks != nil && *failedChecks > 0 && isInt64Changed(oldFailedChecks, failedChecks) {
+		transition := newTransition(DeliveryTransitionAnalysisFinished)
+		transition.AnalysisStatus = "Failed"
+		transitions = append(transitions, transition)
+	}
+
+	if phase != oldPhase {
+		switch phase {
+		case "Succeeded":
+			transitions = append(transitions, newTransition(DeliveryTransitionPromoted))
+		case "Failed":
+			transitions = append(transitions, newTransition(DeliveryTransitionAborted))
+		}
+	}
+	return transitions
+}
+
+// DeliveryTransitions returns the progressive delivery transitions between the old and new versions of an
+// Argo Rollouts Rollout or a Flagger Canary
+func DeliveryTransitions(oldObj, newObj interface{}) (transitions []DeliveryTransition) {
+	oldUnst, ok1 := oldObj.(*unstructured.Unstructured)
+	newUnst, ok2 := newObj.(*unstructured.Unstructured)
+	if !ok1 || !ok2 || !isDeliveryKind(newUnst) {
+		return nil
+	}
+
+	if newUnst.GetKind() == "Rollout" {
+		return rolloutTransitions(oldUnst, newUnst)
+	}
+	return canaryTransitions(oldUnst, newUnst)
+}
+
+// deliveryTransitionEvent returns the event of a progressive delivery transition
+func deliveryTransitionEvent(newObj interface{}, transition DeliveryTransition) map[string]interface{} {
+	return map[string]interface{}{
+		"newObject":  newObj,
+		"eventType":  common.EventTypeProgressiveDelivery,
+		"transition": transition,
+	}
+}
@@ -0,0 +1,101 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// getTestRolloutStatus returns the mock rollout with a status
+func getTestRolloutStatus(status map[string]interface{}) *unstructured.Unstructured {
+	rollout := GetTestRollout()
+	rollout.Object["status"] = status
+	return rollout
+}
+
+// getTestCanary returns a Flagger canary with a status
+func getTestCanary(status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "flagger.app/v1beta1",
+		"kind":       "Canary",
+		"metadata":   map[string]interface{}{"name": "podinfo", "namespace": "test"},
+		"spec":       map[string]interface{}{"analysis": map[string]interface{}{"iterations": int64(10)}},
+		"status":     status,
+	}}
+}
+
+// deliveryTransitionNames returns the names of transitions
+func deliveryTransitionNames(transitions []DeliveryTransition) (names []string) {
+	for _, transition := range transitions {
+		names = append(names, transition.Transition)
+	}
+	return names
+}
+
+// TestRolloutTransitions tests the progressive delivery transitions of Argo Rollouts rollouts
+func TestRolloutTransitions(t *testing.T) {
+	canaryWeight := func(weight int64) map[string]interface{} {
+		return map[string]interface{}{"weights": map[string]interface{}{"canary": map[string]interface{}{"weight": weight}}}
+	}
+	testCases := []struct {
+		name      string
+		oldStatus map[string]interface{}
+		newStatus map[string]interface{}
+		expected  []string
+	}{
+		{"step and weight", map[string]interface{}{"currentStepIndex": int64(0), "canary": canaryWeight(0)}, map[string]interface{}{"currentStepIndex": int64(1), "canary": canaryWeight(20)}, []string{DeliveryTransitionStepProgressed, DeliveryTransitionWeightChanged}},
+		{"analysis", map[string]interface{}{"canary": map[string]interface{}{"currentStepAnalysisRunStatus": map[string]interface{}{"name": "run-1", "status": "Running"}}}, map[string]interface{}{"canary": map[string]interface{}{"currentStepAnalysisRunStatus": map[string]interface{}{"name": "run-1", "status": "Successful"}}}, []string{DeliveryTransitionAnalysisFinished}},
+		{"promoted", map[string]interface{}{"stableRS": "a", "currentPodHash": "b"}, map[string]interface{}{"stableRS": "b", "currentPodHash": "b"}, []string{DeliveryTransitionPromoted}},
+		{"aborted", map[string]interface{}{"currentPodHash": "b"}, map[string]interface{}{"currentPodHash": "b", "abort": true, "message": "RolloutAborted"}, []string{DeliveryTransitionAborted}},
+		{"unchanged", map[string]interface{}{"currentStepIndex": int64(1), "stableRS": "a"}, map[string]interface{}{"currentStepIndex": int64(1), "stableRS": "a"}, nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transitions := DeliveryTransitions(getTestRolloutStatus(testCase.oldStatus), getTestRolloutStatus(testCase.newStatus))
+			if names := deliveryTransitionNames(transitions); !reflect.DeepEqual(names, testCase.expected) {
+				t.Errorf("Expected transitions %v, got %v", testCase.expected, names)
+			}
+		})
+	}
+
+	transitions := DeliveryTransitions(getTestRolloutStatus(map[string]interface{}{"currentStepIndex": int64(0)}), getTestRolloutStatus(map[string]interface{}{"currentStepIndex": int64(2), "currentPodHash": "b"}))
+	if detail := transitions[0].detail(); detail != "step: 2/3" || transitions[0].Revision != "b" {
+		t.Errorf("Expected step 2 of 3 of revision b, got %s of %+v", detail, transitions[0])
+	}
+}
+
+// TestCanaryTransitions tests the progressive delivery transitions of Flagger canaries
+func TestCanaryTransitions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		oldStatus map[string]interface{}
+		newStatus map[string]interface{}
+		expected  []string
+	}{
+		{"weight", map[string]interface{}{"phase": "Progressing", "canaryWeight": int64(10)}, map[string]interface{}{"phase": "Progressing", "canaryWeight": int64(20)}, []string{DeliveryTransitionWeightChanged}},
+		{"iteration", map[string]interface{}{"phase": "Progressing", "iterations": int64(1)}, map[string]interface{}{"phase": "Progressing", "iterations": int64(2)}, []string{DeliveryTransitionStepProgressed}},
+		{"failed check", map[string]interface{}{"phase": "Progressing", "failedChecks": int64(0)}, map[string]interface{}{"phase": "Progressing", "failedChecks": int64(1)}, []string{DeliveryTransitionAnalysisFinished}},
+		{"promoted", map[string]interface{}{"phase": "Finalising"}, map[string]interface{}{"phase": "Succeeded"}, []string{DeliveryTransitionPromoted}},
+		{"aborted", map[string]interface{}{"phase": "Progressing", "canaryWeight": int64(20)}, map[string]interface{}{"phase": "Failed", "canaryWeight": int64(0)}, []string{DeliveryTransitionWeightChanged, DeliveryTransitionAborted}},
+		{"unchanged", map[string]interface{}{"phase": "Succeeded", "canaryWeight": int64(0)}, map[string]interface{}{"phase": "Succeeded", "canaryWeight": int64(0)}, nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transitions := DeliveryTransitions(getTestCanary(testCase.oldStatus), getTestCanary(testCase.newStatus))
+			if names := deliveryTransitionNames(transitions); !reflect.DeepEqual(names, testCase.expected) {
+				t.Errorf("Expected transitions %v, got %v", testCase.expected, names)
+			}
+		})
+	}
+
+	transitions := DeliveryTransitions(getTestCanary(map[string]interface{}{"canaryWeight": int64(10)}), getTestCanary(map[string]interface{}{"canaryWeight": int64(20)}))
+	if detail := transitions[0].detail(); detail != "weight: 20" || *transitions[0].PreviousWeight != 10 || transitions[0].Tool != DeliveryToolFlagger {
+		t.Errorf("Expected a weight change from 10 to 20, got %s of %+v", detail, transitions[0])
+	}
+
+	// Other kinds have no progressive delivery transitions
+	if transitions = DeliveryTransitions(getTestApplication("Running", "t", "Synced", "Healthy", "a"), getTestApplication("Running", "t", "Synced", "Healthy", "a")); transitions != nil {
+		t.Errorf("Expected no transitions, got %+v", transitions)
+	}
+}
@@ -5,24 +5,29 @@ import (
 	"encoding/json"
 	"fmt"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/tools/cache"
 	"log"
 	"main.go/common"
-	"os"
-	"os/signal"
 	"reflect"
+	"slices"
+	"sort"
 	"sync"
 )
 
 // createResourceInformer creates a dynamic resource informer for a given resource GVR.
 // It will return nil if the informer fails to create.
-func createResourceInformer(resourceGVR schema.GroupVersionResource, clusterClient *dynamic.DynamicClient) (resourceInformer cache.SharedIndexInformer) {
-	// Creates a Kubernetes dynamic informer for the cluster API resources
-	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(clusterClient, 0, corev1.NamespaceAll, nil)
+func createResourceInformer(resourceGVR schema.GroupVersionResource, clusterClient dynamic.Interface) (resourceInformer cache.SharedIndexInformer) {
+	// Creates a Kubernetes dynamic informer for the cluster API resources,
+	// which records a heartbeat of its watch loop with each list and watch request
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(clusterClient, 0, corev1.NamespaceAll, func(*metav1.ListOptions) {
+		common.InformerHeartbeat(resourceGVR)
+	})
 	resourceInformer = factory.ForResource(resourceGVR).Informer()
 
 	// If the informer is nil, log the failure and return nil
@@ -78,18 +83,32 @@ func IgnoreInternalChanges(oldObj, newObj interface{}) bool {
 
 		deleteInternalFields(oldCopy)
 		deleteInternalFields(newCopy)
-		newJson, _ := json.Marshal(newCopy)
-		oldJson, _ := json.Marshal(oldCopy)
+		if isSameObject(oldCopy, newCopy) {
+			return true
+		}
 
-		return string(oldJson) == string(newJson)
+		// Apply the configured ignore rules and count the events they suppressed
+		appliedRules := applyIgnoreRules(oldCopy, newCopy)
+		if len(appliedRules) > 0 && isSameObject(oldCopy, newCopy) {
+			countIgnoreRuleSuppression(appliedRules)
+			return true
+		}
 	}
 
 	return false
 }
 
-// addInformerEventHandler adds event handlers to the informer.
-// It handles add, update, and delete events.
-func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
+// isSameObject checks whether two Kubernetes objects are identical
+func isSameObject(oldObj, newObj *unstructured.Unstructured) bool {
+	newJson, _ := json.Marshal(newObj)
+	oldJson, _ := json.Marshal(oldObj)
+
+	return string(oldJson) == string(newJson)
+}
+
+// addInformerEventHandler adds event handlers to the informer of a resource, and runs the informer until the context
+// is done. It handles add, update, and delete events.
+func addInformerEventHandler(ctx context.Context, resourceGVR schema.GroupVersionResource, resourceInformer cache.SharedIndexInformer) {
 	var event map[string]interface{}
 	synced := false
 
@@ -101,8 +120,15 @@ func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
 			mux.RLock()
 			defer mux.RUnlock()
 			if !synced {
+				// Link the objects of the existing Helm releases to their releases
+				IndexHelmReleaseSecret(obj)
+				// Index the deploy markers of the existing workloads for their related resources
+				IndexDeployMarkersObject(obj)
+				// Index the app labels of the existing workloads for their pods and related resources
+				IndexAppLabelsObject(obj)
 				return
 			}
+			common.ObserveEvent(resourceGVR, common.EventTypeAdded)
 
 			event = map[string]interface{}{
 				"newObject": obj,
@@ -118,8 +144,32 @@ func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
 			if !synced {
 				return
 			}
+			common.ObserveEvent(resourceGVR, common.EventTypeModified)
+
+			// Send status condition transitions of the kinds that opted in
+			for _, transition := range StatusTransitions(oldObj, newObj) {
+				statusEvent := map[string]interface{}{
+					"newObject": newObj,
+					"eventType": common.EventTypeStatusChanged,
+					"condition": transition,
+				}
+				go StructResourceLog(statusEvent)
+			}
+
+			// Send the sync and health transitions of Argo CD and Flux objects
+			for _, transition := range GitOpsTransitions(oldObj, newObj) {
+				go StructResourceLog(gitOpsTransitionEvent(newObj, transition))
+			}
+
+			// Send the progressive delivery transitions of Argo Rollouts and Flagger canaries
+			for _, transition := range DeliveryTransitions(oldObj, newObj) {
+				go StructResourceLog(deliveryTransitionEvent(newObj, transition))
+			}
 
 			if IgnoreInternalChanges(oldObj, newObj) {
+				common.SuppressEvent(resourceGVR, common.EventTypeModified)
+				// Discard the identity of the ignored request, so it isn't attributed to another event
+				go discardRequestIdentity(newObj)
 				return // ignore internal cluster updates
 			} else {
 				event = map[string]interface{}{
@@ -138,6 +188,7 @@ func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
 			if !synced {
 				return
 			}
+			common.ObserveEvent(resourceGVR, common.EventTypeDeleted)
 
 			event = map[string]interface{}{
 				"newObject": obj,
@@ -150,15 +201,18 @@ func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
 
 	if err != nil {
 		msg := fmt.Sprintf("[ERROR] Failed to add event handler for informer.\nERROR:\n%v", err)
+		common.InformerNotCreated(resourceGVR, "failed to add event handler")
 		common.SendLog(msg)
 		return
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
-
-	// Start the informer
-	go resourceInformer.Run(ctx.Done())
+	// Start the informer, and record when it stops
+	common.SetInformerSynced(resourceGVR, false)
+	common.InformerCreated(resourceGVR, resourceInformer.HasSynced)
+	go func() {
+		resourceInformer.Run(ctx.Done())
+		common.InformerStopped(resourceGVR)
+	}()
 
 	// Wait for all caches to sync
 	cache.WaitForCacheSync(ctx.Done(), resourceInformer.HasSynced)
@@ -167,60 +221,179 @@ func addInformerEventHandler(resourceInformer cache.SharedIndexInformer) {
 	mux.Lock()
 	synced = true
 	mux.Unlock()
+	common.SetInformerSynced(resourceGVR, resourceInformer.HasSynced())
 
-	// If the informer failed to sync, log the error and terminate the program
-	if !resourceInformer.HasSynced() {
+	// If the informer failed to sync before shutdown, log the error and terminate the program
+	if !resourceInformer.HasSynced() && ctx.Err() == nil {
 		log.Fatal("Informer event handler failed to sync.")
 	}
 
-	// Wait for the process to be interrupted (e.g. by a SIGINT signal)
+	// Wait for the process to be stopped (e.g. by a SIGTERM or SIGINT signal)
 	<-ctx.Done()
 
 }
 
-// AddEventHandlers creates informers and adds event handlers for the specified Kubernetes resources.
-func AddEventHandlers() {
-
-	// Define the Kubernetes resources for which to create informers and add event handlers
-	resourceAPIList := map[string]string{
-		"configmaps":          "",
-		"deployments":         "apps",
-		"daemonsets":          "apps",
-		"secrets":             "",
-		"serviceaccounts":     "",
-		"statefulsets":        "apps",
-		"clusterroles":        "rbac.authorization.k8s.io",
-		"clusterrolebindings": "rbac.authorization.k8s.io",
+// resourceAPIList defines the Kubernetes resources for which to create informers and add event handlers, by resource group
+var resourceAPIList = map[string]string{
+	"configmaps":          "",
+	"deployments":         "apps",
+	"daemonsets":          "apps",
+	"secrets":             "",
+	"serviceaccounts":     "",
+	"statefulsets":        "apps",
+	"clusterroles":        "rbac.authorization.k8s.io",
+	"clusterrolebindings": "rbac.authorization.k8s.io",
+}
+
+// optionalResource is a custom resource that is watched if the cluster serves one of its versions
+type optionalResource struct {
+	Group    string
+	Resource string
+	// Versions are the supported versions, in order of preference
+	Versions []string
+}
+
+// optionalResourceList defines the custom resources for which to create informers, if their CRDs are installed
+var optionalResourceList = []optionalResource{
+	{Group: "argoproj.io", Resource: "applications", Versions: []string{"v1alpha1"}},
+	{Group: "kustomize.toolkit.fluxcd.io", Resource: "kustomizations", Versions: []string{"v1", "v1beta2"}},
+	{Group: "helm.toolkit.fluxcd.io", Resource: "helmreleases", Versions: []string{"v2", "v2beta2", "v2beta1"}},
+	{Group: "source.toolkit.fluxcd.io", Resource: "gitrepositories", Versions: []string{"v1", "v1beta2"}},
+	{Group: "argoproj.io", Resource: "rollouts", Versions: []string{"v1alpha1"}},
+	{Group: "flagger.app", Resource: "canaries", Versions: []string{"v1beta1"}},
+}
+
+// servedOptionalResources are the optional resources served by the cluster, resolved once
+var servedOptionalResources []schema.GroupVersionResource
+var servedOptionalResourcesOnce sync.Once
+
+// servedResources returns the preferred served version of each optional resource, skipping resources that aren't served
+func servedResources(discoveryClient discovery.DiscoveryInterface, resources []optionalResource) (served []schema.GroupVersionResource) {
+	for _, resource := range resources {
+		for _, version := range resource.Versions {
+			groupVersion := schema.GroupVersion{Group: resource.Group, Version: version}
+			resourceList, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion.String())
+			if err != nil {
+				// The group version isn't served, the CRD isn't installed or has another version
+				continue
+			}
+			if slices.ContainsFunc(resourceList.APIResources, func(apiResource metav1.APIResource) bool { return apiResource.Name == resource.Resource }) {
+				served = append(served, groupVersion.WithResource(resource.Resource))
+				break
+			}
+		}
 	}
-	var eventHandlerSync sync.WaitGroup
-	resourceIndex := 0
+	return served
+}
+
+// informerStores are the caches of the created informers, by resource
+var informerStores = map[schema.GroupVersionResource]cache.Store{}
+var informerStoresMux sync.RWMutex
+
+// registerInformerStore records the cache of the informer of a resource, so its objects are read without API requests
+func registerInformerStore(resourceGVR schema.GroupVersionResource, store cache.Store) {
+	informerStoresMux.Lock()
+	defer informerStoresMux.Unlock()
+	informerStores[resourceGVR] = store
+}
+
+// informerObject gets an object from the cache of the informer of one of the versions of a resource.
+// It isn't ok if no informer watches the resource. The object is shared with the informer and must not be modified.
+func informerObject(gvrs []schema.GroupVersionResource, namespace, name string) (obj *unstructured.Unstructured, ok bool) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	informerStoresMux.RLock()
+	defer informerStoresMux.RUnlock()
+	for _, gvr := range gvrs {
+		store, isWatched := informerStores[gvr]
+		if !isWatched {
+			continue
+		}
+		item, exists, err := store.GetByKey(key)
+		if err != nil || !exists {
+			return nil, true
+		}
+		obj, _ = item.(*unstructured.Unstructured)
+		return obj, true
+	}
+	return nil, false
+}
 
-	// Loop over the defined resources
+// IsWatchedResource checks whether informers are created for a resource of an API group
+func IsWatchedResource(group, resource string) bool {
+	for _, watchedResource := range WatchedResources() {
+		if watchedResource.Group == group && watchedResource.Resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchedResources returns the resources for which informers are created,
+// including the optional resources served by the cluster
+func WatchedResources() (watchedResources []schema.GroupVersionResource) {
+	servedOptionalResourcesOnce.Do(func() {
+		if common.DiscoveryClient != nil {
+			servedOptionalResources = servedResources(common.DiscoveryClient, optionalResourceList)
+		}
+	})
 	for resourceType, resourceGroup := range resourceAPIList {
-		resourceIndex = resourceIndex + 1
+		watchedResources = append(watchedResources, schema.GroupVersionResource{Group: resourceGroup, Version: "v1", Resource: resourceType})
+	}
+	watchedResources = append(watchedResources, servedOptionalResources...)
+	sort.Slice(watchedResources, func(i, j int) bool {
+		return watchedResources[i].String() < watchedResources[j].String()
+	})
+	return watchedResources
+}
+
+// AddEventHandlers creates informers and adds event handlers for the specified Kubernetes resources.
+// It runs the informers until the context is done, and returns when they stopped.
+func AddEventHandlers(ctx context.Context) {
+	var eventHandlerSync sync.WaitGroup
 
-		resourceAPI := fmt.Sprintf("%s/v1/%s", resourceGroup, resourceType)
-		resourceGVR := schema.GroupVersionResource{Group: resourceGroup, Version: "v1", Resource: resourceType}
+	// Load the configured deploy marker key patterns
+	ConfigureDeployMarkers()
+
+	// Compile the configured ignore rules and periodically report the events they suppressed
+	ConfigureIgnoreRules()
+	if len(ignoreRules) > 0 {
+		reportInterval := common.Config.IgnoreRulesReportInterval.Duration
+		if reportInterval <= 0 {
+			reportInterval = common.DefaultIgnoreRulesReportInterval
+		}
+		go reportIgnoreRuleStats(reportInterval, nil)
+	}
+
+	// Loop over the defined resources, which must all have synced informers for the collector to be ready
+	watchedResources := WatchedResources()
+	common.ExpectInformers(watchedResources)
+	for _, resourceGVR := range watchedResources {
+		resourceAPI := fmt.Sprintf("%s/%s/%s", resourceGVR.Group, resourceGVR.Version, resourceGVR.Resource)
 
 		// Attempt to create an informer for the resource
 		log.Printf("Attempting to create informer for resource API: '%s'", resourceAPI)
 		resourceInformer := createResourceInformer(resourceGVR, common.DynamicClient)
 		if resourceInformer != nil {
+			registerInformerStore(resourceGVR, resourceInformer.GetStore())
 			// If the informer was successfully created, attempt to add an event handler to it
 			log.Printf("Attempting to add event handler to informer for resource API: '%s'", resourceAPI)
-			eventHandlerSync.Add(resourceIndex)
-			go addInformerEventHandler(resourceInformer)
-			{
+			eventHandlerSync.Add(1)
+			go func() {
 				defer eventHandlerSync.Done()
-				log.Printf("Finished adding event handler to informer for resource API: '%s'", resourceAPI)
-			}
+				addInformerEventHandler(ctx, resourceGVR, resourceInformer)
+			}()
+			log.Printf("Finished adding event handler to informer for resource API: '%s'", resourceAPI)
 		} else {
-			// If the informer could not be created, log the failure
+			// If the informer could not be created, log the failure and report it in the readiness probe
+			common.InformerNotCreated(resourceGVR, "failed to create informer")
 			common.SendLog(fmt.Sprintf("Failed to create informer for resource API: '%s'", resourceAPI))
 		}
 	}
 
-	// Wait for all event handlers to be added
+	// Wait for all informers to stop
 	eventHandlerSync.Wait()
 }
 
@@ -241,6 +414,38 @@ func EventObject(rawObject map[string]interface{}) (resourceEventObject common.K
 
 }
 
+// eventObjectReference returns the reference of an event resource, for matching it to the request that changed it
+func eventObjectReference(eventType string, resourceObj common.KubernetesEvent, object map[string]interface{}) (ref common.ObjectReference) {
+	ref = common.ObjectReference{
+		Resource:   common.KindToResource(resourceObj.Kind),
+		Namespace:  resourceObj.KubernetesMetadata.Namespace,
+		Name:       resourceObj.KubernetesMetadata.Name,
+		UID:        resourceObj.KubernetesMetadata.UID,
+		EventType:  eventType,
+		ChangeTime: objectChangeTime(eventType, object),
+	}
+	// The deleted object may have a different resource version than the delete request response
+	if eventType != common.EventTypeDeleted {
+		ref.ResourceVersion = resourceObj.KubernetesMetadata.ResourceVersion
+	}
+	return ref
+}
+
+// discardRequestIdentity removes the buffered identity of the request that caused an ignored update
+func discardRequestIdentity(newObj interface{}) {
+	newUnst, ok := newObj.(*unstructured.Unstructured)
+	if common.Attributions == nil || !ok {
+		return
+	}
+	resourceObj := common.KubernetesEvent{Kind: newUnst.GetKind(), KubernetesMetadata: common.KubernetesMetadata{
+		Name:            newUnst.GetName(),
+		Namespace:       newUnst.GetNamespace(),
+		ResourceVersion: newUnst.GetResourceVersion(),
+		UID:             string(newUnst.GetUID()),
+	}}
+	common.Attributions.Match(eventObjectReference(common.EventTypeModified, resourceObj, newUnst.Object), 0)
+}
+
 // StructResourceLog structures the event log and sends it.
 func StructResourceLog(event map[string]interface{}) (isStructured bool, parsedEvent map[string]interface{}) {
 	var msg string
@@ -264,7 +469,22 @@ func StructResourceLog(event map[string]interface{}) (isStructured bool, parsedE
 	resourceName := newResourceObj.KubernetesMetadata.Name
 	resourceNamespace := newResourceObj.KubernetesMetadata.Namespace
 	newResourceVersion := newResourceObj.KubernetesMetadata.ResourceVersion
-	msg = common.ParseEventMessage(eventType, resourceName, resourceKind, resourceNamespace, newResourceVersion)
+	if eventType == common.EventTypeStatusChanged {
+		condition, _ := event["condition"].(common.StatusCondition)
+		msg = common.ParseStatusChangeMessage(resourceName, resourceKind, resourceNamespace, condition)
+	} else if eventType == common.EventTypeGitOpsTransition {
+		transition, _ := event["transition"].(GitOpsTransition)
+		msg = common.ParseGitOpsTransitionMessage(resourceName, resourceKind, resourceNamespace, transition.Transition, transition.Revision)
+	} else if eventType == common.EventTypeProgressiveDelivery {
+		transition, _ := event["transition"].(DeliveryTransition)
+		msg = common.ParseProgressiveDeliveryMessage(resourceName, resourceKind, resourceNamespace, transition.Transition, transition.detail())
+	} else if eventType == common.EventTypeRejected || eventType == common.EventTypeDryRun {
+		operation, _ := event["operation"].(string)
+		requestedBy, _ := event["requestedBy"].(common.RequestIdentity)
+		msg = common.ParseAdmissionMessage(eventType, operation, resourceName, resourceKind, resourceNamespace, requestedBy.Username)
+	} else {
+		msg = common.ParseEventMessage(eventType, resourceName, resourceKind, resourceNamespace, newResourceVersion)
+	}
 	if eventType == common.EventTypeModified {
 		oldResourceObj := EventObject(logEvent.OldObject)
 		oldResourceName := oldResourceObj.KubernetesMetadata.Name
@@ -272,6 +492,61 @@ func StructResourceLog(event map[string]interface{}) (isStructured bool, parsedE
 		oldResourceVersion := oldResourceObj.KubernetesMetadata.ResourceVersion
 		msg = common.ParseEventMessage(eventType, oldResourceName, resourceKind, oldResourceNamespace, newResourceVersion, oldResourceVersion)
 
+		// Classify the changes between the old and new objects
+		if changeCategories := ClassifyChanges(resourceKind, logEvent.OldObject, logEvent.NewObject); len(changeCategories) > 0 {
+			event["changeCategories"] = changeCategories
+		}
+	}
+
+	// Add the parsed container images of workloads, their image changes and mutable image references
+	isChangeEvent := eventType == common.EventTypeAdded || eventType == common.EventTypeModified || eventType == common.EventTypeDeleted
+	if isChangeEvent {
+		images, imagesChanged, mutableImages := ImageChanges(eventType, logEvent.OldObject, logEvent.NewObject)
+		if len(images) > 0 {
+			event["images"] = images
+		}
+		if len(imagesChanged) > 0 {
+			event["imagesChanged"] = imagesChanged
+		}
+		if len(mutableImages) > 0 {
+			event["mutableImages"] = mutableImages
+		}
+	}
+
+	// Add the key level changes of secrets and config maps, without their values
+	switch resourceKind {
+	case "Secret":
+		event["secretData"] = SecretDataChanges(eventType, logEvent.OldObject, logEvent.NewObject)
+		// Send the Helm release actions stored in release Secrets, attempted changes didn't complete an action
+		if isChangeEvent {
+			if releaseEvent := HelmReleaseChange(eventType, logEvent.OldObject, logEvent.NewObject); releaseEvent != nil {
+				go sendHelmReleaseLog(releaseEvent)
+			}
+		}
+	case "ConfigMap":
+		event["configMapData"] = ConfigMapDataChanges(eventType, logEvent.OldObject, logEvent.NewObject)
+	}
+
+	// Link the objects of Helm releases to their release
+	if releaseRef, ok := ObjectHelmRelease(resourceKind, logEvent.NewObject); ok {
+		event["helmRelease"] = releaseRef
+	}
+
+	// Add the Argo CD or Flux object that applied the resource
+	if gitOps := ResourceGitOpsMetadata(gitOpsClient(), logEvent.NewObject); gitOps != nil {
+		event["gitops"] = gitOps
+	}
+
+	// Attribute the change to the user that requested it, if a request identity source is configured
+	if common.Attributions != nil && isChangeEvent {
+		if requestedBy, ok := common.Attributions.Match(eventObjectReference(eventType, newResourceObj, logEvent.NewObject), common.AttributionMatchWait); ok {
+			event["requestedBy"] = requestedBy
+		}
+	}
+
+	// Attribute the change to the managers recorded in managedFields, before they are stripped
+	if changedBy := ChangeAttribution(eventType, logEvent.OldObject, logEvent.NewObject); changedBy != nil {
+		event["changedBy"] = changedBy
 	}
 
 	// Get cluster related resources
@@ -281,6 +556,22 @@ func StructResourceLog(event map[string]interface{}) (isStructured bool, parsedE
 		event["relatedClusterServices"] = clusterRelatedResources
 	}
 
+	// Add the deploy markers of the resource and of its related workloads
+	if isChangeEvent {
+		IndexDeployMarkers(eventType, logEvent.NewObject)
+	}
+	if markers := EventDeployMarkers(resourceNamespace, logEvent.NewObject, clusterRelatedResources); len(markers) > 0 {
+		event["deployMarkers"] = markers
+	}
+
+	// Add the app object of the recommended labels of the resource, or of its owning or related workloads
+	if isChangeEvent {
+		IndexAppLabels(eventType, logEvent.NewObject)
+	}
+	if app := EventAppLabels(resourceKind, resourceNamespace, logEvent.NewObject, clusterRelatedResources); len(app) > 0 {
+		event["app"] = app
+	}
+
 	jsonString, _ = json.Marshal(event)
 	err = json.Unmarshal(jsonString, &parsedEvent)
 
@@ -288,6 +579,8 @@ func StructResourceLog(event map[string]interface{}) (isStructured bool, parsedE
 	if err != nil {
 		log.Printf("[ERROR] Failed to parse resource event logs.\nERROR:\n%v", err)
 	} else {
+		stripManagedFields(parsedEvent)
+		LiftEventDeployMarkers(parsedEvent)
 		isStructured = true
 	}
 	// Send the parsed event log
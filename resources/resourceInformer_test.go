@@ -3,15 +3,20 @@ package resources
 import (
 	"encoding/json"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"log"
 	"main.go/common"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // createFakeResourceInformer creates a fake informer for testing purposes
@@ -37,6 +42,39 @@ func TestCreateResourceInformer(t *testing.T) {
 	}
 }
 
+// TestCreateResourceInformerHeartbeat tests that the list and watch requests of the informer are recorded as heartbeats
+// of its watch loop
+func TestCreateResourceInformerHeartbeat(t *testing.T) {
+	resourceGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "heartbeats"}
+	fakeDynamicClient := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{resourceGVR: "HeartbeatList"})
+	informer := createResourceInformer(resourceGVR, fakeDynamicClient)
+	common.InformerCreated(resourceGVR, informer.HasSynced)
+	isStalled := func() bool {
+		for _, check := range common.Liveness(30 * time.Millisecond) {
+			if check.Name == "watch apps/v1/heartbeats" {
+				return !check.Healthy
+			}
+		}
+		t.Fatal("Expected a liveness check of the informer")
+		return false
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if !isStalled() {
+		t.Fatal("Expected the watch loop to be stalled before the informer runs")
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for isStalled() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the list request of the informer to record a heartbeat")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 // TestEventObject tests the creation of an event object from a map
 func TestEventObject(t *testing.T) {
 	testDeployment := GetTestDeployment()
@@ -185,3 +223,40 @@ func TestIgnoreInternalChanges(t *testing.T) {
 		t.Errorf("IgnoreInternalChanges did not ignore internal changes")
 	}
 }
+
+// TestEventObjectReference tests the references used to match events to the requests that caused them
+func TestEventObjectReference(t *testing.T) {
+	resourceObj := common.KubernetesEvent{Kind: "Deployment", KubernetesMetadata: common.KubernetesMetadata{Name: "web", Namespace: "default", ResourceVersion: "42", UID: "uid-1"}}
+	object := getTestManagedFieldsObject(
+		map[string]interface{}{"manager": "helm", "operation": "Update", "time": "2024-01-01T10:00:00Z"},
+		map[string]interface{}{"manager": "kubectl-edit", "operation": "Update", "time": "2024-01-02T10:00:00Z"},
+	)
+	ref := eventObjectReference(common.EventTypeModified, resourceObj, object)
+	expected := common.ObjectReference{Resource: "deployments", Namespace: "default", Name: "web", UID: "uid-1", ResourceVersion: "42", EventType: common.EventTypeModified, ChangeTime: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	if ref != expected {
+		t.Errorf("Expected reference %+v, got %+v", expected, ref)
+	}
+	if ref = eventObjectReference(common.EventTypeDeleted, resourceObj, object); ref.ResourceVersion != "" || !ref.ChangeTime.IsZero() {
+		t.Errorf("Expected no resource version and change time for deleted events without a deletion timestamp, got %+v", ref)
+	}
+	if !IsWatchedResource("apps", "deployments") || IsWatchedResource("", "deployments") {
+		t.Error("Expected only apps deployments to be watched")
+	}
+}
+
+// TestServedResources tests resolving the served versions of optional custom resources
+func TestServedResources(t *testing.T) {
+	discoveryClient := &fakeDiscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "argoproj.io/v1alpha1", APIResources: []metav1.APIResource{{Name: "applications"}, {Name: "appprojects"}}},
+		{GroupVersion: "kustomize.toolkit.fluxcd.io/v1beta2", APIResources: []metav1.APIResource{{Name: "kustomizations"}}},
+	}}}
+
+	served := servedResources(discoveryClient, optionalResourceList)
+	expected := []schema.GroupVersionResource{
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Resource: "kustomizations"},
+	}
+	if !reflect.DeepEqual(served, expected) {
+		t.Errorf("Expected served resources %v, got %v", expected, served)
+	}
+}
@@ -41,6 +41,7 @@ func SecretRelatedWorkloads(secretName string) (relatedWorkloads common.RelatedC
 	var daemonsets []Workload
 	var deployments []Workload
 	var statefulsets []Workload
+	var rollouts []Workload
 	for _, pod := range GetPods() {
 		pods = append(pods, Pod(pod))
 	}
@@ -53,13 +54,17 @@ func SecretRelatedWorkloads(secretName string) (relatedWorkloads common.RelatedC
 	for _, statefulset := range GetStatefulSets() {
 		statefulsets = append(statefulsets, StatefulSet(statefulset))
 	}
+	for _, rollout := range GetRollouts() {
+		rollouts = append(rollouts, rollout)
+	}
 	relatedPods := GetSecretRelatedWorkloads(secretName, pods)
 	relatedDaemonsets := GetSecretRelatedWorkloads(secretName, daemonsets)
 	relatedDeployments := GetSecretRelatedWorkloads(secretName, deployments)
 	relatedStatefulSets := GetSecretRelatedWorkloads(secretName, statefulsets)
+	relatedRollouts := GetSecretRelatedWorkloads(secretName, rollouts)
 	// Similarly, call getRelatedWorkloads for other workload types...
 
-	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Pods: relatedPods}
+	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Rollouts: relatedRollouts, Pods: relatedPods}
 
 	return relatedWorkloads
 }
@@ -91,6 +96,7 @@ func ConfigMapRelatedWorkloads(configMapName string) (relatedWorkloads common.Re
 	var daemonsets []Workload
 	var deployments []Workload
 	var statefulsets []Workload
+	var rollouts []Workload
 	for _, pod := range GetPods() {
 		pods = append(pods, Pod(pod))
 	}
@@ -103,12 +109,16 @@ func ConfigMapRelatedWorkloads(configMapName string) (relatedWorkloads common.Re
 	for _, statefulset := range GetStatefulSets() {
 		statefulsets = append(statefulsets, StatefulSet(statefulset))
 	}
+	for _, rollout := range GetRollouts() {
+		rollouts = append(rollouts, rollout)
+	}
 	relatedPods := GetConfigMapRelatedWorkloads(configMapName, pods)
 	relatedDaemonsets := GetConfigMapRelatedWorkloads(configMapName, daemonsets)
 	relatedDeployments := GetConfigMapRelatedWorkloads(configMapName, deployments)
 	relatedStatefulSets := GetConfigMapRelatedWorkloads(configMapName, statefulsets)
+	relatedRollouts := GetConfigMapRelatedWorkloads(configMapName, rollouts)
 
-	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Pods: relatedPods}
+	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Rollouts: relatedRollouts, Pods: relatedPods}
 
 	return relatedWorkloads
 }
@@ -129,6 +139,7 @@ func ServiceAccountRelatedWorkloads(serviceAccountName string) (relatedWorkloads
 	var daemonsets []Workload
 	var deployments []Workload
 	var statefulsets []Workload
+	var rollouts []Workload
 	for _, pod := range GetPods() {
 		pods = append(pods, Pod(pod))
 	}
@@ -141,12 +152,16 @@ func ServiceAccountRelatedWorkloads(serviceAccountName string) (relatedWorkloads
 	for _, statefulset := range GetStatefulSets() {
 		statefulsets = append(statefulsets, StatefulSet(statefulset))
 	}
+	for _, rollout := range GetRollouts() {
+		rollouts = append(rollouts, rollout)
+	}
 	relatedPods := GetServiceAccountRelatedWorkloads(serviceAccountName, pods)
 	relatedDaemonsets := GetServiceAccountRelatedWorkloads(serviceAccountName, daemonsets)
 	relatedDeployments := GetServiceAccountRelatedWorkloads(serviceAccountName, deployments)
 	relatedStatefulSets := GetServiceAccountRelatedWorkloads(serviceAccountName, statefulsets)
+	relatedRollouts := GetServiceAccountRelatedWorkloads(serviceAccountName, rollouts)
 
-	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Pods: relatedPods}
+	relatedWorkloads = common.RelatedClusterServices{Deployments: relatedDeployments, DaemonSets: relatedDaemonsets, StatefulSets: relatedStatefulSets, Rollouts: relatedRollouts, Pods: relatedPods}
 
 	return relatedWorkloads
 }
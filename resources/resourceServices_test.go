@@ -1,6 +1,9 @@
 package resources
 
 import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
 	"main.go/common"
 	"reflect"
 	"testing"
@@ -168,3 +171,24 @@ func TestClusterRoleRelatedWorkloads(t *testing.T) {
 		t.Logf("Cluster role: %s related workloads:\n%v", clusterRoleName, relatedWorkloads)
 	}
 }
+
+// TestRolloutRelatedWorkloads tests that Argo Rollouts rollouts are identified as related workloads.
+func TestRolloutRelatedWorkloads(t *testing.T) {
+	client := fakeDynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{rolloutGVR: "RolloutList"}, GetTestRollout())
+
+	var rollouts []Workload
+	for _, rollout := range listRollouts(client) {
+		rollouts = append(rollouts, rollout)
+	}
+
+	expected := []string{"test-rollout"}
+	if relatedRollouts := GetSecretRelatedWorkloads("test-secret", rollouts); !reflect.DeepEqual(relatedRollouts, expected) {
+		t.Errorf("Expected secret related rollouts %v, got %v", expected, relatedRollouts)
+	}
+	if relatedRollouts := GetConfigMapRelatedWorkloads("test-configmap", rollouts); !reflect.DeepEqual(relatedRollouts, expected) {
+		t.Errorf("Expected config map related rollouts %v, got %v", expected, relatedRollouts)
+	}
+	if relatedRollouts := GetServiceAccountRelatedWorkloads("test-serviceaccount", rollouts); !reflect.DeepEqual(relatedRollouts, expected) {
+		t.Errorf("Expected service account related rollouts %v, got %v", expected, relatedRollouts)
+	}
+}
@@ -0,0 +1,106 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/strings/slices"
+	"main.go/common"
+)
+
+// statusTransitionRule returns the status transition rule of a resource kind, if the kind opted in
+func statusTransitionRule(kind string) (rule common.StatusTransitionRule, ok bool) {
+	for _, rule = range common.Config.StatusTransitions {
+		if rule.Kind == kind {
+			return rule, true
+		}
+	}
+	return rule, false
+}
+
+// objectConditions returns the status conditions of an object by condition type
+func objectConditions(obj *unstructured.Unstructured) (conditions map[string]map[string]interface{}) {
+	conditions = map[string]map[string]interface{}{}
+	conditionsList, _, _ := unstructured.NestedSlice(obj.Object, common.Status, common.Conditions)
+	for _, conditionI := range conditionsList {
+		if condition, ok := conditionI.(map[string]interface{}); ok {
+			if conditionType, ok := condition["type"].(string); ok && conditionType != "" {
+				conditions[conditionType] = condition
+			}
+		}
+	}
+	return conditions
+}
+
+// conditionField returns a string field of a status condition
+func conditionField(condition map[string]interface{}, field string) string {
+	value, _ := condition[field].(string)
+	return value
+}
+
+// StatusTransitions returns the status condition transitions between the old and new versions of a resource.
+// Only kinds set in the statusTransitions configuration report transitions, and only changes of a condition
+// status or reason are reported, so noisy status counters and heartbeat timestamps are ignored.
+// Conditions removed from the resource transition to the Unknown status.
+func StatusTransitions(oldObj, newObj interface{}) (transitions []common.StatusCondition) {
+	oldUnst, ok1 := oldObj.(*unstructured.Unstructured)
+	newUnst, ok2 := newObj.(*unstructured.Unstructured)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	rule, ok := statusTransitionRule(newUnst.GetKind())
+	if !ok {
+		return nil
+	}
+
+	oldConditions := objectConditions(oldUnst)
+	newConditions := objectConditions(newUnst)
+	types := conditionTypes(newUnst)
+	for _, conditionType := range conditionTypes(oldUnst) {
+		if _, ok := newConditions[conditionType]; !ok {
+			types = append(types, conditionType)
+		}
+	}
+	for _, conditionType := range types {
+		if len(rule.ConditionTypes) > 0 && !slices.Contains(rule.ConditionTypes, conditionType) {
+			continue
+		}
+		newCondition, isPresent := newConditions[conditionType]
+		oldCondition := oldConditions[conditionType]
+		status, previousStatus := conditionField(newCondition, "status"), conditionField(oldCondition, "status")
+		if !isPresent {
+			status = common.ConditionStatusUnknown
+		}
+		reason, previousReason := conditionField(newCondition, "reason"), conditionField(oldCondition, "reason")
+		if status == previousStatus && reason == previousReason {
+			continue
+		}
+
+		transitionTime := conditionField(newCondition, "lastTransitionTime")
+		if transitionTime == "" {
+			// Some conditions, such as the Deployment Progressing condition, only update lastUpdateTime
+			transitionTime = conditionField(newCondition, "lastUpdateTime")
+		}
+		transitions = append(transitions, common.StatusCondition{
+			Type:               conditionType,
+			Status:             status,
+			PreviousStatus:     previousStatus,
+			Reason:             reason,
+			PreviousReason:     previousReason,
+			Message:            conditionField(newCondition, "message"),
+			LastTransitionTime: transitionTime,
+		})
+	}
+	return transitions
+}
+
+// conditionTypes returns the condition types of an object in their status order
+func conditionTypes(obj *unstructured.Unstructured) (types []string) {
+	conditionsList, _, _ := unstructured.NestedSlice(obj.Object, common.Status, common.Conditions)
+	for _, conditionI := range conditionsList {
+		if condition, ok := conditionI.(map[string]interface{}); ok {
+			if conditionType := conditionField(condition, "type"); conditionType != "" && !slices.Contains(types, conditionType) {
+				types = append(types, conditionType)
+			}
+		}
+	}
+	return types
+}
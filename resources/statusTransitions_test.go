@@ -0,0 +1,113 @@
+package resources
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"main.go/common"
+	"reflect"
+	"testing"
+)
+
+// getTestDeploymentWithConditions returns an unstructured deployment with the given status conditions
+func getTestDeploymentWithConditions(readyReplicas int64, conditions ...map[string]interface{}) *unstructured.Unstructured {
+	conditionsList := []interface{}{}
+	for _, condition := range conditions {
+		conditionsList = append(conditionsList, condition)
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind": "Deployment",
+			common.Metadata: map[string]interface{}{
+				"name":      "test-deployment",
+				"namespace": "default",
+			},
+			common.Status: map[string]interface{}{
+				"readyReplicas":   readyReplicas,
+				common.Conditions: conditionsList,
+			},
+		},
+	}
+}
+
+// TestStatusTransitions tests that only condition status and reason changes of opted in kinds are reported
+func TestStatusTransitions(t *testing.T) {
+	defer func() { common.Config.StatusTransitions = nil }()
+
+	oldObj := getTestDeploymentWithConditions(3,
+		map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable", "lastTransitionTime": "2024-01-01T00:00:00Z"},
+		map[string]interface{}{"type": "Progressing", "status": "True", "reason": "NewReplicaSetAvailable", "lastUpdateTime": "2024-01-01T00:00:00Z"},
+	)
+	newObj := getTestDeploymentWithConditions(1,
+		map[string]interface{}{"type": "Available", "status": "False", "reason": "MinimumReplicasUnavailable", "message": "Deployment does not have minimum availability.", "lastTransitionTime": "2024-01-02T00:00:00Z"},
+		map[string]interface{}{"type": "Progressing", "status": "True", "reason": "NewReplicaSetAvailable", "lastUpdateTime": "2024-01-02T00:00:00Z"},
+	)
+
+	if transitions := StatusTransitions(oldObj, newObj); len(transitions) != 0 {
+		t.Errorf("Expected no transitions for a kind that didn't opt in, got %v", transitions)
+	}
+
+	common.Config.StatusTransitions = []common.StatusTransitionRule{{Kind: "Deployment"}}
+	expected := []common.StatusCondition{{
+		Type:               "Available",
+		Status:             "False",
+		PreviousStatus:     "True",
+		Reason:             "MinimumReplicasUnavailable",
+		PreviousReason:     "MinimumReplicasAvailable",
+		Message:            "Deployment does not have minimum availability.",
+		LastTransitionTime: "2024-01-02T00:00:00Z",
+	}}
+	if transitions := StatusTransitions(oldObj, newObj); !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("Expected transitions %+v, got %+v", expected, transitions)
+	}
+
+	// Status counter changes are not transitions
+	newConditions := newObj.Object[common.Status].(map[string]interface{})[common.Conditions].([]interface{})
+	counterObj := getTestDeploymentWithConditions(2, newConditions[0].(map[string]interface{}), newConditions[1].(map[string]interface{}))
+	if transitions := StatusTransitions(newObj, counterObj); len(transitions) != 0 {
+		t.Errorf("Expected no transitions for status counter changes, got %v", transitions)
+	}
+
+	common.Config.StatusTransitions = []common.StatusTransitionRule{{Kind: "Deployment", ConditionTypes: []string{"Progressing"}}}
+	if transitions := StatusTransitions(oldObj, newObj); len(transitions) != 0 {
+		t.Errorf("Expected no transitions for filtered condition types, got %v", transitions)
+	}
+}
+
+// TestStatusTransitionsRemovedCondition tests that a condition removed from a resource transitions to Unknown
+func TestStatusTransitionsRemovedCondition(t *testing.T) {
+	defer func() { common.Config.StatusTransitions = nil }()
+	common.Config.StatusTransitions = []common.StatusTransitionRule{{Kind: "Deployment"}}
+
+	oldObj := getTestDeploymentWithConditions(1,
+		map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+		map[string]interface{}{"type": "ReplicaFailure", "status": "True", "reason": "FailedCreate", "message": "pods \"web\" is forbidden"},
+	)
+	newObj := getTestDeploymentWithConditions(1,
+		map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+	)
+	expected := []common.StatusCondition{{
+		Type:           "ReplicaFailure",
+		Status:         common.ConditionStatusUnknown,
+		PreviousStatus: "True",
+		PreviousReason: "FailedCreate",
+	}}
+	if transitions := StatusTransitions(oldObj, newObj); !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("Expected transitions %+v, got %+v", expected, transitions)
+	}
+}
+
+// TestStructStatusChangedLog tests the structuring of a status changed event
+func TestStructStatusChangedLog(t *testing.T) {
+	event := map[string]interface{}{
+		"newObject": getTestDeploymentWithConditions(1),
+		"eventType": common.EventTypeStatusChanged,
+		"condition": common.StatusCondition{Type: "Available", Status: "False", PreviousStatus: "True", Reason: "MinimumReplicasUnavailable"},
+	}
+	isStructured, parsedEvent := StructResourceLog(event)
+	if !isStructured {
+		t.Fatalf("Failed to structure status changed log")
+	}
+	condition, ok := parsedEvent["condition"].(map[string]interface{})
+	if !ok || condition["reason"] != "MinimumReplicasUnavailable" || condition["previousStatus"] != "True" {
+		t.Errorf("Expected the condition transition in the parsed event, got %v", parsedEvent["condition"])
+	}
+}
@@ -140,3 +140,18 @@ func StatefulSetRelatedResources(statefulSetName string, namespace string) (rela
 
 	return relatedResources
 }
+
+// RolloutRelatedResources returns a list of all resources related to the rollout
+func RolloutRelatedResources(rolloutName string, namespace string) (relatedResources common.RelatedClusterServices) {
+	rollout := GetRollout(rolloutName, namespace)
+	if rollout.Name != "" {
+		relatedConfigMaps := GetWorkloadRelatedConfigMaps(rollout)
+		relatedSecrets := GetWorkloadRelatedSecrets(rollout)
+		relatedServiceAccounts := GetWorkloadRelatedServiceAccounts(rollout)
+		relatedClusterRoleBindings := GetWorkloadRelatedClusterRoleBindings(rollout)
+		relatedClusterRoles := GetWorkloadRelatedClusterRoles(rollout)
+		relatedResources = common.RelatedClusterServices{ConfigMaps: relatedConfigMaps, Secrets: relatedSecrets, ServiceAccounts: relatedServiceAccounts, ClusterRoleBindings: relatedClusterRoleBindings, ClusterRoles: relatedClusterRoles}
+	}
+
+	return relatedResources
+}
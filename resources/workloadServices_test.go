@@ -1,6 +1,8 @@
 package resources
 
 import (
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
 	"main.go/common"
 	"reflect"
 	"testing"
@@ -109,3 +111,24 @@ func TestStatefulSetRelatedResources(t *testing.T) {
 		t.Logf("Statefulset: %s related resources:\n %v", statefulSet.Name, relatedResources)
 	}
 }
+
+// TestRolloutRelatedResources is used to test getting related resources for a test rollout
+func TestRolloutRelatedResources(t *testing.T) {
+	client := fakeDynamic.NewSimpleDynamicClient(runtime.NewScheme(), GetTestRollout())
+	rollout := getRollout(client, "test-rollout", "default")
+	if rollout.Name != "test-rollout" {
+		t.Fatalf("Expected to get rollout: test-rollout, got %+v", rollout)
+	}
+
+	expectedConfigMaps, expectedSecrets := []string{"test-configmap"}, []string{"test-secret"}
+	if relatedConfigMaps := GetWorkloadRelatedConfigMaps(rollout); !reflect.DeepEqual(relatedConfigMaps, expectedConfigMaps) {
+		t.Errorf("Expected related config maps %v, got %v", expectedConfigMaps, relatedConfigMaps)
+	}
+	if relatedSecrets := GetWorkloadRelatedSecrets(rollout); !reflect.DeepEqual(relatedSecrets, expectedSecrets) {
+		t.Errorf("Expected related secrets %v, got %v", expectedSecrets, relatedSecrets)
+	}
+
+	if missingRollout := getRollout(client, "missing-rollout", "default"); missingRollout.Name != "" {
+		t.Errorf("Expected no rollout, got %+v", missingRollout)
+	}
+}